@@ -0,0 +1,80 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/zgsm-ai/ai-prompt-shell/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Header carrying the trace ID of a request, set on every response
+const HeaderRequestID = "X-Request-Id"
+
+// Trace IDs taken from callers, anything else is replaced
+var validTraceID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+/**
+ * Middleware attaching a trace ID to the request context, recorded in the audit log
+ * @description
+ * - The ID is the X-Request-Id header, else the trace ID of a W3C traceparent header, else a new random ID
+ */
+func traceMiddleware(c *gin.Context) {
+	id := c.GetHeader(HeaderRequestID)
+	if !validTraceID.MatchString(id) {
+		id = ""
+		// traceparent: version-traceid-parentid-flags
+		if parts := strings.Split(c.GetHeader("traceparent"), "-"); len(parts) == 4 && len(parts[1]) == 32 {
+			id = parts[1]
+		}
+	}
+	if id == "" {
+		b := make([]byte, 16)
+		rand.Read(b)
+		id = hex.EncodeToString(b)
+	}
+	c.Header(HeaderRequestID, id)
+	c.Request = c.Request.WithContext(service.WithTraceID(c.Request.Context(), id))
+	c.Next()
+}
+
+// QueryAudit Query the audit log
+// @Summary Query audit log
+// @Description Get audit records of configuration changes and chats, newest first.
+// @Description Filters are exact matches; since and until are RFC 3339 times
+// @Tags Audit
+// @Produce json
+// @Param type query string false "Record type" Enums(change, chat)
+// @Param actor query string false "Caller who made the change or chat"
+// @Param kind query string false "Kind of item changed, e.g. prompts or environs"
+// @Param target query string false "ID of the item changed"
+// @Param prompt query string false "Prompt of the chat"
+// @Param model query string false "Model of the chat"
+// @Param trace_id query string false "Trace ID of the request"
+// @Param session query string false "Session of the chat"
+// @Param since query string false "Earliest time, included"
+// @Param until query string false "Latest time, excluded"
+// @Param limit query int false "Maximum number of records, 100 by default, at most 1000"
+// @Success 200 {array} service.AuditRecord
+// @Failure 400 {object} ResponseData
+// @Failure 500 {object} ResponseData
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/audit [get]
+func QueryAudit(c *gin.Context) {
+	var f service.AuditFilter
+	if err := c.ShouldBindQuery(&f); err != nil {
+		respErrorf(c, http.StatusBadRequest, "invalid query: %v", err)
+		return
+	}
+	records, err := service.QueryAudit(f)
+	if err != nil {
+		respError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respOK(c, records)
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/zgsm-ai/ai-prompt-shell/internal/auth"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/config"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// Authenticator of API requests, nil if authentication is disabled
+var authenticator auth.Authenticator
+
+/**
+ * Enable authentication of API requests as configured
+ * @param c authentication configuration; no modes disables authentication
+ */
+func InitAuth(c *config.AuthConfig) error {
+	a, err := auth.New(c)
+	if err != nil {
+		return err
+	}
+	authenticator = a
+	if a == nil {
+		logrus.Warnf("API authentication is disabled")
+	} else {
+		logrus.Infof("API authentication modes: %v", c.Modes)
+	}
+	return nil
+}
+
+/**
+ * Middleware authenticating the caller and attaching its identity to the request context
+ * @throws 401 error if the request has no valid credentials
+ */
+func authMiddleware(c *gin.Context) {
+	if authenticator == nil {
+		c.Next()
+		return
+	}
+	id, err := authenticator.Authenticate(c.Request)
+	if err != nil {
+		c.Header("WWW-Authenticate", `Bearer realm="ai-prompt-shell"`)
+		respError(c, http.StatusUnauthorized, err)
+		c.Abort()
+		return
+	}
+	c.Request = c.Request.WithContext(auth.WithIdentity(c.Request.Context(), id))
+	c.Next()
+}
+
+/**
+ * Middleware admitting callers having a role, or a higher one
+ * @throws 403 error if the caller lacks the role
+ */
+func requireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := auth.FromContext(c.Request.Context())
+		if id != nil && !id.HasRole(role) {
+			respError(c, http.StatusForbidden, fmt.Errorf("role %s required", role))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"github.com/zgsm-ai/ai-prompt-shell/service"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// ExportBundle export everything published
+// @Summary Export bundle
+// @Description Export extensions, directly-published prompts, tools, partials and environment variables as one bundle.
+// @Description With format=tar the bundle is a tar archive laid out like a file storage directory.
+// @Tags Bundle
+// @Produce json
+// @Produce application/x-tar
+// @Param prefixes query string false "Comma-separated kinds (environs, scopes, secrets, tools, partials, extensions, prompts) or storage prefixes to export, all by default"
+// @Param format query string false "Bundle format" Enums(json, tar) default(json)
+// @Success 200 {object} service.Bundle
+// @Failure 400 {object} ResponseData
+// @Failure 500 {object} ResponseData
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/export [get]
+func ExportBundle(c *gin.Context) {
+	kinds, err := service.ParseBundleKinds(splitQuery(c.Query("prefixes")))
+	if err != nil {
+		respError(c, http.StatusBadRequest, err)
+		return
+	}
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "tar" {
+		respErrorf(c, http.StatusBadRequest, "unknown bundle format: %s", format)
+		return
+	}
+	bundle, err := service.ExportBundle(kinds)
+	if err != nil {
+		respError(c, http.StatusInternalServerError, err)
+		return
+	}
+	if format == "json" {
+		respOK(c, bundle)
+		return
+	}
+	c.Header("Content-Type", "application/x-tar")
+	c.Header("Content-Disposition", `attachment; filename="bundle.tar"`)
+	c.Status(http.StatusOK)
+	if err := service.WriteBundleTar(c.Writer, bundle); err != nil {
+		logrus.Errorf("request: %+v, error: %s", c.Request.RequestURI, err.Error())
+	}
+}
+
+// ImportBundle import bundle
+// @Summary Import bundle
+// @Description Import a bundle produced by export, as JSON or as a tar archive (optionally gzipped).
+// @Description merge creates and updates items; replace also deletes items of the imported kinds that are not in the bundle.
+// @Description Changes are written all at once; with dry_run they are only reported.
+// @Tags Bundle
+// @Accept json
+// @Accept application/x-tar
+// @Produce json
+// @Param bundle body service.Bundle true "Bundle to import"
+// @Param mode query string false "Import mode" Enums(merge, replace) default(merge)
+// @Param dry_run query bool false "Report the changes without writing them"
+// @Param prefixes query string false "Comma-separated kinds or storage prefixes to import, by default those the bundle was exported with"
+// @Success 200 {object} service.ImportResult
+// @Failure 400 {object} ResponseData
+// @Failure 403 {object} ResponseData
+// @Failure 500 {object} ResponseData
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/import [post]
+func ImportBundle(c *gin.Context) {
+	var opts service.ImportOptions
+	opts.Mode = c.DefaultQuery("mode", service.ImportMerge)
+	if opts.Mode != service.ImportMerge && opts.Mode != service.ImportReplace {
+		respErrorf(c, http.StatusBadRequest, "unknown import mode: %s", opts.Mode)
+		return
+	}
+	if v := c.Query("dry_run"); v != "" {
+		dryRun, err := strconv.ParseBool(v)
+		if err != nil {
+			respErrorf(c, http.StatusBadRequest, "invalid dry_run: %s", v)
+			return
+		}
+		opts.DryRun = dryRun
+	}
+	opts.Kinds = splitQuery(c.Query("prefixes"))
+	if _, err := service.ParseBundleKinds(opts.Kinds); err != nil {
+		respError(c, http.StatusBadRequest, err)
+		return
+	}
+	bundle, err := service.ReadBundle(c.Request.Body)
+	if err != nil {
+		respError(c, http.StatusBadRequest, err)
+		return
+	}
+	result, err := service.ImportBundle(c.Request.Context(), bundle, opts)
+	if err != nil {
+		respError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respOK(c, result)
+}
+
+/**
+ * Split a comma-separated query parameter
+ */
+func splitQuery(v string) []string {
+	if v == "" {
+		return nil
+	}
+	return strings.Split(v, ",")
+}
@@ -1,7 +1,12 @@
 package api
 
 import (
+	"github.com/zgsm-ai/ai-prompt-shell/internal/auth"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/logger"
 	"github.com/zgsm-ai/ai-prompt-shell/internal/utils"
+	"github.com/zgsm-ai/ai-prompt-shell/service"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -25,6 +30,13 @@ type ResponseData struct {
  */
 func respOK(c *gin.Context, data any) {
 	logrus.Debugf("request: %+v, response: %+v", c.Request.RequestURI, data)
+	if logger.Redacting() {
+		// Tools may return what they were sent, including secrets from their headers
+		if body, err := json.Marshal(data); err == nil {
+			c.Data(http.StatusOK, "application/json; charset=utf-8", []byte(logger.Redact(string(body))))
+			return
+		}
+	}
 	c.JSON(http.StatusOK, data)
 	// c.JSON(http.StatusOK, ResponseData{
 	// 	Code:    "0",
@@ -39,7 +51,23 @@ func respOK(c *gin.Context, data any) {
  */
 func respError(c *gin.Context, code int, err error) {
 	logrus.Errorf("request: %+v, error: %s", c.Request.RequestURI, err.Error())
-	if httpErr, ok := err.(*utils.HttpError); ok {
+	var renderErr *service.RenderError
+	var guardErr *service.GuardError
+	if errors.As(err, &renderErr) {
+		c.JSON(renderErr.Code(), ResponseData{
+			Code:    strconv.Itoa(renderErr.Code()),
+			Message: renderErr.Error(),
+			Success: false,
+			Data:    renderErr,
+		})
+	} else if errors.As(err, &guardErr) {
+		c.JSON(guardErr.Code(), ResponseData{
+			Code:    strconv.Itoa(guardErr.Code()),
+			Message: guardErr.Error(),
+			Success: false,
+			Data:    guardErr.Verdict,
+		})
+	} else if httpErr, ok := err.(*utils.HttpError); ok {
 		c.JSON(httpErr.Code(), ResponseData{
 			Code:    strconv.Itoa(httpErr.Code()),
 			Message: httpErr.Error(),
@@ -64,3 +92,44 @@ func respErrorf(c *gin.Context, code int, format string, args ...interface{}) {
 		Success: false,
 	})
 }
+
+/**
+ * Parse filter, search and pagination query parameters of list endpoints
+ * @param c gin context
+ * @return list options, whether summaries were requested, and error on invalid parameters
+ */
+func parseListOptions(c *gin.Context) (service.ListOptions, bool, error) {
+	opts := service.ListOptions{
+		Viewer:    auth.FromContext(c.Request.Context()),
+		Supports:  c.Query("supports"),
+		Language:  c.Query("language"),
+		Origin:    c.Query("origin"),
+		Publisher: c.Query("publisher"),
+		Query:     c.Query("q"),
+	}
+	var err error
+	if v := c.Query("page"); v != "" {
+		if opts.Page, err = strconv.Atoi(v); err != nil || opts.Page < 1 {
+			return opts, false, fmt.Errorf("invalid page: %s", v)
+		}
+	}
+	if v := c.Query("page_size"); v != "" {
+		if opts.PageSize, err = strconv.Atoi(v); err != nil || opts.PageSize < 0 {
+			return opts, false, fmt.Errorf("invalid page_size: %s", v)
+		}
+	}
+	summary := false
+	if v := c.Query("summary"); v != "" {
+		if summary, err = strconv.ParseBool(v); err != nil {
+			return opts, false, fmt.Errorf("invalid summary: %s", v)
+		}
+	}
+	return opts, summary, nil
+}
+
+/**
+ * Report total item count of a paginated list in response header
+ */
+func setTotalCount(c *gin.Context, total int) {
+	c.Header("X-Total-Count", strconv.Itoa(total))
+}
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/logger"
+)
+
+func TestRespOKMasksEscapedSecrets(t *testing.T) {
+	const secret = `pa"ss<word>&\1`
+	logger.SetRedactions([]string{secret})
+	t.Cleanup(func() { logger.SetRedactions(nil) })
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/api/prompts/p/chat", nil)
+	respOK(c, map[string]string{"content": "token " + secret + " echoed"})
+
+	body := w.Body.String()
+	for _, leak := range []string{`ss<word`, `ss\u003cword`, `pa\"ss`} {
+		if strings.Contains(body, leak) {
+			t.Errorf("response %s leaks the secret as %s", body, leak)
+		}
+	}
+	if !strings.Contains(body, `"token `+logger.Mask+` echoed"`) {
+		t.Errorf("response %s doesn't mask the secret", body)
+	}
+}
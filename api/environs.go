@@ -1,6 +1,7 @@
 package api
 
 import (
+	"github.com/zgsm-ai/ai-prompt-shell/dao"
 	"github.com/zgsm-ai/ai-prompt-shell/service"
 	"net/http"
 
@@ -9,12 +10,30 @@ import (
 
 // ListEnvirons Get environment variables list
 // @Summary List all environment variables
-// @Description Get all defined environment variables in system
+// @Description Get all defined environment variables in system, as seen by the scope given by the scope parameter or by the X-Tenant-Id, X-Project-Id and X-User-Id headers
 // @Tags Environs
 // @Produce json
+// @Param scope query string false "Scope as comma-separated kind:id pairs, e.g. tenant:acme,project:web,user:alice"
 // @Success 200 {array} string
+// @Failure 400 {object} ResponseData
+// @Failure 403 {object} ResponseData
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /api/environs [get]
 func ListEnvirons(c *gin.Context) {
+	scope, _, err := queryScope(c)
+	if err != nil {
+		respError(c, http.StatusBadRequest, err)
+		return
+	}
+	if len(scope.Layers()) > 0 {
+		envs := []string{}
+		for key := range service.Environments().Resolve(scope) {
+			envs = append(envs, key)
+		}
+		respOK(c, envs)
+		return
+	}
 	envs, err := service.Environments().Keys()
 	if err != nil {
 		respErrorf(c, http.StatusInternalServerError, "Failed to load environment variables")
@@ -25,19 +44,132 @@ func ListEnvirons(c *gin.Context) {
 
 // GetEnviron Get a single environment variable value
 // @Summary Get environment variable
-// @Description Get value of specified environment variable
+// @Description Get value of specified environment variable, as seen by the scope of the X-Tenant-Id, X-Project-Id and X-User-Id headers.
+// @Description With the scope parameter, get the value seen by that scope and where it comes from (service.EnvironValue):
+// @Description the most specific of user, project, tenant overrides setting it, or global
 // @Tags Environs
 // @Produce json
 // @Param environ_id path string true "Environment variable ID"
+// @Param scope query string false "Scope as comma-separated kind:id pairs, e.g. tenant:acme,project:web,user:alice"
 // @Success 200 {object} interface{}
+// @Failure 400 {object} ResponseData
+// @Failure 403 {object} ResponseData
 // @Failure 404 {object} ResponseData
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /api/environs/{environ_id} [get]
 func GetEnviron(c *gin.Context) {
 	environID := c.Param("environ_id")
-	val, ok := service.Environments().Get(environID)
+	scope, detailed, err := queryScope(c)
+	if err != nil {
+		respError(c, http.StatusBadRequest, err)
+		return
+	}
+	val, ok := service.ResolveEnviron(scope, environID)
 	if !ok {
 		respErrorf(c, http.StatusNotFound, "Environment variable not found")
 		return
 	}
-	respOK(c, val)
+	if detailed {
+		respOK(c, val)
+		return
+	}
+	respOK(c, val.Value)
+}
+
+// SetEnviron Set a single environment variable value
+// @Summary Set environment variable
+// @Description Set value of specified environment variable, creating it if it doesn't exist.
+// @Description With the scope parameter naming one scope, e.g. tenant:acme, set the variable's override in that scope instead
+// @Tags Environs
+// @Accept json
+// @Produce json
+// @Param environ_id path string true "Environment variable ID"
+// @Param scope query string false "Scope to set the override in, e.g. project:web"
+// @Param value body interface{} true "Variable value, any JSON value"
+// @Success 200 {object} ResponseData
+// @Failure 400 {object} ResponseData
+// @Failure 403 {object} ResponseData
+// @Failure 500 {object} ResponseData
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/environs/{environ_id} [put]
+func SetEnviron(c *gin.Context) {
+	environID := c.Param("environ_id")
+	scope, err := service.ParseEnvScope(c.Query("scope"))
+	if err == nil {
+		err = checkScopeAccess(c, scope)
+	}
+	if err != nil {
+		respError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	var val interface{}
+	if err := c.ShouldBindJSON(&val); err != nil {
+		respErrorf(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if err := service.SetScopedEnviron(c.Request.Context(), scope, environID, val); err != nil {
+		respError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respOK(c, ResponseData{
+		Code:    "0",
+		Message: "OK",
+		Success: true,
+	})
+}
+
+// DeleteEnviron Delete a single environment variable
+// @Summary Delete environment variable
+// @Description Delete specified environment variable, or with the scope parameter naming one scope, its override in that scope
+// @Tags Environs
+// @Produce json
+// @Param environ_id path string true "Environment variable ID"
+// @Param scope query string false "Scope to delete the override from, e.g. project:web"
+// @Success 200 {object} ResponseData
+// @Failure 400 {object} ResponseData
+// @Failure 403 {object} ResponseData
+// @Failure 404 {object} ResponseData
+// @Failure 500 {object} ResponseData
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/environs/{environ_id} [delete]
+func DeleteEnviron(c *gin.Context) {
+	environID := c.Param("environ_id")
+	scope, err := service.ParseEnvScope(c.Query("scope"))
+	if err == nil {
+		err = checkScopeAccess(c, scope)
+	}
+	if err != nil {
+		respError(c, http.StatusBadRequest, err)
+		return
+	}
+	if err := service.DeleteEnviron(c.Request.Context(), scope, environID); err != nil {
+		respError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respOK(c, ResponseData{
+		Code:    "0",
+		Message: "OK",
+		Success: true,
+	})
+}
+
+/**
+ * Get the scope to read variables with: the scope parameter if given, otherwise the scope of the request headers
+ * @return scope, and whether it was given by the parameter
+ * @throws 403 error if the parameter names a scope of another tenant or user
+ */
+func queryScope(c *gin.Context) (dao.EnvScope, bool, error) {
+	text, ok := c.GetQuery("scope")
+	if !ok {
+		return service.EnvScopeFrom(c.Request.Context()), false, nil
+	}
+	scope, err := service.ParseEnvScope(text)
+	if err == nil {
+		err = checkScopeAccess(c, scope)
+	}
+	return scope, true, err
 }
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"github.com/zgsm-ai/ai-prompt-shell/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RunEval run batch evaluation
+// @Summary Evaluate prompts and models over a dataset
+// @Description Chat with every prompt and model over each dataset sample, with bounded concurrency, and score the outputs.
+// @Description Scorers: exact (output equals expected), schema (output conforms to the prompt's returns) and judge (scored by judge_prompt).
+// @Description The report compares the mean scores of every prompt/model variant and lists per-sample results
+// @Tags Eval
+// @Accept json
+// @Produce json
+// @Param request body service.EvalRequest true "Evaluation parameters"
+// @Success 200 {object} service.EvalReport
+// @Failure 400 {object} ResponseData
+// @Failure 404 {object} ResponseData
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/eval [post]
+func RunEval(c *gin.Context) {
+	var req service.EvalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respErrorf(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	report, err := service.RunEval(c.Request.Context(), req)
+	if err != nil {
+		respError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respOK(c, report)
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"github.com/zgsm-ai/ai-prompt-shell/dao"
 	"github.com/zgsm-ai/ai-prompt-shell/service"
 	"net/http"
 
@@ -9,18 +10,43 @@ import (
 
 // ListExtensions list all prompt extension IDs
 // @Summary List all prompt extension IDs
-// @Description Get all available prompt extension IDs in the system
+// @Description Get available prompt extensions in the system, sorted by ID. Returns IDs by default, or summaries if summary=true
 // @Tags Extensions
 // @Produce json
-// @Success 200 {array} string
+// @Param language query string false "Only extensions supporting this language"
+// @Param publisher query string false "Only extensions of this publisher"
+// @Param q query string false "Free-text search over ID, name, display name and description"
+// @Param page query int false "Page number, starting from 1"
+// @Param page_size query int false "Page size, 0 means no pagination"
+// @Param summary query bool false "Return summaries instead of IDs"
+// @Success 200 {array} string "Extension IDs, or service.ExtensionSummary objects if summary=true"
+// @Header 200 {int} X-Total-Count "Number of matching extensions before pagination"
+// @Failure 400 {object} ResponseData
+// @Failure 500 {object} ResponseData
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /api/extensions [get]
 func ListExtensions(c *gin.Context) {
-	extensions, err := service.ExtensionIDs()
+	opts, summary, err := parseListOptions(c)
+	if err != nil {
+		respError(c, http.StatusBadRequest, err)
+		return
+	}
+	items, total, err := service.ListExtensions(opts)
 	if err != nil {
 		respErrorf(c, http.StatusInternalServerError, "failed to load extensions")
 		return
 	}
-	respOK(c, extensions)
+	setTotalCount(c, total)
+	if summary {
+		respOK(c, items)
+		return
+	}
+	ids := make([]string, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.ID)
+	}
+	respOK(c, ids)
 }
 
 // GetExtensionDetail get prompt extension details
@@ -31,6 +57,8 @@ func ListExtensions(c *gin.Context) {
 // @Param extension_id path string true "Extension ID"
 // @Success 200 {object} dao.PromptExtension
 // @Failure 404 {object} ResponseData
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /api/extensions/{extension_id} [get]
 func GetExtensionDetail(c *gin.Context) {
 	extensionID := c.Param("extension_id")
@@ -41,5 +69,103 @@ func GetExtensionDetail(c *gin.Context) {
 		respErrorf(c, http.StatusNotFound, "extension not found")
 		return
 	}
+	respOK(c, service.VisibleExtension(c.Request.Context(), ext))
+}
+
+// InstallExtension install prompt extension
+// @Summary Install prompt extension
+// @Description Install a prompt extension, or update it if already installed. Its contributed prompts become available immediately
+// @Tags Extensions
+// @Accept json
+// @Produce json
+// @Param extension_id path string true "Extension ID"
+// @Param extension body dao.PromptExtension true "Extension definition (package.json)"
+// @Success 200 {object} dao.PromptExtension
+// @Failure 400 {object} ResponseData
+// @Failure 500 {object} ResponseData
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/extensions/{extension_id} [put]
+func InstallExtension(c *gin.Context) {
+	extensionID := c.Param("extension_id")
+
+	var ext dao.PromptExtension
+	if err := c.ShouldBindJSON(&ext); err != nil {
+		respErrorf(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if err := service.InstallExtension(c.Request.Context(), extensionID, ext); err != nil {
+		respError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respOK(c, ext)
+}
+
+// EnableExtension enable prompt extension
+// @Summary Enable prompt extension
+// @Description Enable an installed prompt extension, so that its contributed prompts become available
+// @Tags Extensions
+// @Produce json
+// @Param extension_id path string true "Extension ID"
+// @Success 200 {object} dao.PromptExtension
+// @Failure 404 {object} ResponseData
+// @Failure 500 {object} ResponseData
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/extensions/{extension_id}/enable [post]
+func EnableExtension(c *gin.Context) {
+	setExtensionEnabled(c, true)
+}
+
+// DisableExtension disable prompt extension
+// @Summary Disable prompt extension
+// @Description Disable an installed prompt extension, so that its contributed prompts are removed until it is enabled again
+// @Tags Extensions
+// @Produce json
+// @Param extension_id path string true "Extension ID"
+// @Success 200 {object} dao.PromptExtension
+// @Failure 404 {object} ResponseData
+// @Failure 500 {object} ResponseData
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/extensions/{extension_id}/disable [post]
+func DisableExtension(c *gin.Context) {
+	setExtensionEnabled(c, false)
+}
+
+func setExtensionEnabled(c *gin.Context, enabled bool) {
+	extensionID := c.Param("extension_id")
+
+	ext, err := service.EnableExtension(c.Request.Context(), extensionID, enabled)
+	if err != nil {
+		respError(c, http.StatusInternalServerError, err)
+		return
+	}
 	respOK(c, ext)
 }
+
+// UninstallExtension uninstall prompt extension
+// @Summary Uninstall prompt extension
+// @Description Remove prompt extension and all prompts contributed by it
+// @Tags Extensions
+// @Produce json
+// @Param extension_id path string true "Extension ID"
+// @Success 200 {object} ResponseData
+// @Failure 404 {object} ResponseData
+// @Failure 500 {object} ResponseData
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/extensions/{extension_id} [delete]
+func UninstallExtension(c *gin.Context) {
+	extensionID := c.Param("extension_id")
+
+	if err := service.UninstallExtension(c.Request.Context(), extensionID); err != nil {
+		respError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respOK(c, ResponseData{
+		Code:    "0",
+		Message: "OK",
+		Success: true,
+	})
+}
@@ -0,0 +1,28 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/zgsm-ai/ai-prompt-shell/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics expose service metrics
+// @Summary Get metrics
+// @Description Get service metrics, such as guard verdicts, in the Prometheus text format
+// @Tags Metrics
+// @Produce plain
+// @Success 200 {string} string "Metrics"
+// @Failure 401 {object} ResponseData
+// @Failure 403 {object} ResponseData
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /metrics [get]
+func Metrics(c *gin.Context) {
+	c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.Status(http.StatusOK)
+	if err := metrics.Write(c.Writer); err != nil {
+		_ = c.Error(err)
+	}
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"github.com/zgsm-ai/ai-prompt-shell/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListPartials list all template partials
+// @Summary List all template partials
+// @Description Get IDs of all shared template partials, which prompts pull in with {{template "partial_id" .}}
+// @Tags Partials
+// @Produce json
+// @Success 200 {array} string
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/partials [get]
+func ListPartials(c *gin.Context) {
+	respOK(c, service.PartialIDs())
+}
+
+// GetPartialDetail get template partial details
+// @Summary Get template partial
+// @Description Get content of specified template partial
+// @Tags Partials
+// @Produce json
+// @Param partial_id path string true "Partial ID"
+// @Success 200 {object} dao.Partial
+// @Failure 404 {object} ResponseData
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/partials/{partial_id} [get]
+func GetPartialDetail(c *gin.Context) {
+	partialID := c.Param("partial_id")
+
+	partial, exists := service.Partial(partialID)
+	if !exists {
+		respErrorf(c, http.StatusNotFound, "partial not found")
+		return
+	}
+	respOK(c, partial)
+}
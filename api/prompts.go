@@ -11,61 +11,136 @@ import (
 
 // ListPrompts list all prompt templates
 // @Summary List all prompt templates
-// @Description Get all available prompt templates in the system
+// @Description Get available prompt templates in the system, sorted by ID. Returns IDs by default, or summaries if summary=true
 // @Tags Prompts
 // @Produce json
-// @Success 200 {array} string
+// @Param supports query string false "Only prompts supporting this scene, e.g. codereview"
+// @Param language query string false "Only prompts whose extension supports this language"
+// @Param origin query string false "Only prompts of this origin (direct/extension)"
+// @Param publisher query string false "Only prompts whose extension has this publisher"
+// @Param q query string false "Free-text search over ID, name and description"
+// @Param page query int false "Page number, starting from 1"
+// @Param page_size query int false "Page size, 0 means no pagination"
+// @Param summary query bool false "Return summaries instead of IDs"
+// @Success 200 {array} string "Prompt IDs, or service.PromptSummary objects if summary=true"
+// @Header 200 {int} X-Total-Count "Number of matching prompts before pagination"
+// @Failure 400 {object} ResponseData
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /api/prompts [get]
 func ListPrompts(c *gin.Context) {
-	templates, err := service.PromptIDs()
+	opts, summary, err := parseListOptions(c)
 	if err != nil {
-		respErrorf(c, http.StatusInternalServerError, "failed to load templates")
+		respError(c, http.StatusBadRequest, err)
+		return
+	}
+	items, total := service.ListPrompts(opts)
+	setTotalCount(c, total)
+	if summary {
+		respOK(c, items)
 		return
 	}
-	respOK(c, templates)
+	ids := make([]string, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.ID)
+	}
+	respOK(c, ids)
 }
 
 // GetPromptDetail get prompt template details
 // @Summary Get specified prompt template details
-// @Description Get detailed information of prompt template by ID
+// @Description Get detailed information of prompt template by ID.
+// @Description If the template fails to compile, valid is false and error holds the template key, line and message.
+// @Description Prompts published from git by sync have version set to the SHA of the commit they were published from
 // @Tags Prompts
 // @Produce json
 // @Param prompt_id path string true "Prompt template ID"
 // @Success 200 {object} dao.Prompt
 // @Failure 404 {object} ResponseData
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /api/prompts/{prompt_id} [get]
 func GetPromptDetail(c *gin.Context) {
 	promptID := c.Param("prompt_id")
 
-	if dao.Client == nil {
-		respErrorf(c, http.StatusInternalServerError, "failed to connect redis")
+	if !dao.Ready() {
+		respErrorf(c, http.StatusInternalServerError, "failed to connect storage")
 		return
 	}
 	prompt, origin := service.Prompt(promptID)
-	if origin == dao.PromptOrigin_Notexist {
+	if origin == dao.PromptOrigin_Notexist || !service.PromptVisible(c.Request.Context(), promptID) {
 		respErrorf(c, http.StatusNotFound, "template not found")
 		return
 	}
 
-	respOK(c, gin.H{
+	resp := gin.H{
 		"origin": string(origin),
 		"prompt": prompt,
-	})
+		"valid":  true,
+	}
+	if promptErr := service.PromptError(promptID); promptErr != nil {
+		resp["valid"] = false
+		resp["error"] = promptErr
+	}
+	if version := service.PromptVersion(promptID); version != "" {
+		resp["version"] = version
+	}
+	respOK(c, resp)
 }
 
 type RenderPromptRequest struct {
-	Args map[string]interface{} `json:"args"`
+	Args      map[string]interface{} `json:"args"`
+	Model     string                 `json:"model,omitempty"`
+	MaxTokens int                    `json:"max_tokens,omitempty"`
+	Mocks     map[string]interface{} `json:"mocks,omitempty"`
+	ToolMode  string                 `json:"tool_mode,omitempty" enums:"record,replay"`
+	Fixture   string                 `json:"fixture,omitempty"`
+	Scope     dao.EnvScope           `json:"scope"`
 }
 
 type RenderPromptResponse struct {
-	Kind     string        `json:"kind"`
-	Prompt   string        `json:"prompt,omitempty"`
-	Messages []dao.Message `json:"messages,omitempty"`
+	Kind     string              `json:"kind"`
+	Prompt   string              `json:"prompt,omitempty"`
+	Messages []dao.Message       `json:"messages,omitempty"`
+	Tokens   service.TokenCounts `json:"tokens"`
+}
+
+// ValidatePrompt validate prompt template without saving it
+// @Summary Validate prompt template
+// @Description Check a prompt template before publishing it. Templates are parsed against the live template functions.
+// @Description Reports syntax errors, unknown functions (e.g. missing tools), unknown message roles, invalid JSON Schemas in parameters/returns
+// @Description and .args references not declared in parameters. If sample_args is given, the prompt is also rendered with all tool calls mocked
+// @Description and the shared variables of scope, over the X-Tenant-Id, X-Project-Id and X-User-Id headers
+// @Tags Prompts
+// @Accept json
+// @Produce json
+// @Param request body service.ValidatePromptRequest true "Prompt template to validate, with optional sample_args"
+// @Success 200 {object} service.ValidatePromptResult
+// @Failure 400 {object} ResponseData
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/prompts/validate [post]
+func ValidatePrompt(c *gin.Context) {
+	var req service.ValidatePromptRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respErrorf(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if err := applyScope(c, req.Scope); err != nil {
+		respError(c, http.StatusBadRequest, err)
+		return
+	}
+	respOK(c, service.ValidatePrompt(c.Request.Context(), req))
 }
 
 // RenderPrompt render prompt template
 // @Summary Render specified prompt template
-// @Description Render the prompt template with given args
+// @Description Render the prompt template with given args, and report token counts of the result.
+// @Description On render failure, data holds the template key, line, column and failing tool (service.RenderError).
+// @Description If model is given, truncatable args are trimmed to fit the prompt budget as in chat.
+// @Description mocks maps tool IDs to canned responses returned instead of calling the tools.
+// @Description tool_mode "record" captures real tool calls to the named fixture file (publisher role), "replay" serves tool calls from it.
+// @Description scope selects the overrides of shared variables, over the X-Tenant-Id, X-Project-Id and X-User-Id headers
 // @Tags Prompts
 // @Accept json
 // @Produce json
@@ -73,8 +148,13 @@ type RenderPromptResponse struct {
 // @Param request body RenderPromptRequest true "Rendering parameters"
 // @Success 200 {object} RenderPromptResponse
 // @Failure 400 {object} ResponseData
+// @Failure 403 {object} ResponseData
 // @Failure 404 {object} ResponseData
+// @Failure 413 {object} ResponseData
 // @Failure 500 {object} ResponseData
+// @Failure 502 {object} ResponseData
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /api/prompts/{prompt_id}/render [post]
 func RenderPrompt(c *gin.Context) {
 	promptID := c.Param("prompt_id")
@@ -85,36 +165,106 @@ func RenderPrompt(c *gin.Context) {
 		return
 	}
 
-	kind, data, err := service.RenderPrompt(promptID, req.Args)
+	if err := applyScope(c, req.Scope); err != nil {
+		respError(c, http.StatusBadRequest, err)
+		return
+	}
+	ctx, session, err := service.WithToolOptions(c.Request.Context(), service.ToolOptions{
+		Mocks:   req.Mocks,
+		Mode:    req.ToolMode,
+		Fixture: req.Fixture,
+	})
 	if err != nil {
-		respErrorf(c, http.StatusInternalServerError, "failed to render template")
+		respError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	var kind string
+	var data interface{}
+	if req.Model != "" {
+		kind, data, err = service.RenderPromptWithBudget(ctx, promptID, req.Args, req.Model, req.MaxTokens)
+	} else {
+		kind, data, err = service.RenderPrompt(ctx, promptID, req.Args)
+	}
+	if err != nil {
+		respError(c, http.StatusInternalServerError, err)
+		return
+	}
+	if err := session.Finish(); err != nil {
+		respError(c, http.StatusInternalServerError, err)
 		return
 	}
 	if kind == "prompt" {
 		respOK(c, RenderPromptResponse{
 			Kind:   kind,
 			Prompt: data.(string),
+			Tokens: service.CountRendered(kind, data),
 		})
 	} else {
 		respOK(c, RenderPromptResponse{
 			Kind:     kind,
 			Messages: data.([]dao.Message),
+			Tokens:   service.CountRendered(kind, data),
 		})
 	}
 }
 
+// TestPrompt run regression tests of prompt template
+// @Summary Run prompt regression tests
+// @Description Render the prompt template once per test case and check the result against the case's expectations:
+// @Description exact prompt/messages (reported with a line diff), contains, not_contains, regex and token_limit.
+// @Description Tool calls are served from each case's mocks, and replayed from its fixture if one is given
+// @Tags Prompts
+// @Accept json
+// @Produce json
+// @Param prompt_id path string true "Prompt template ID"
+// @Param request body service.TestSuite true "Test cases"
+// @Success 200 {object} service.TestReport
+// @Failure 400 {object} ResponseData
+// @Failure 404 {object} ResponseData
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/prompts/{prompt_id}/test [post]
+func TestPrompt(c *gin.Context) {
+	promptID := c.Param("prompt_id")
+
+	var suite service.TestSuite
+	if err := c.ShouldBindJSON(&suite); err != nil {
+		respErrorf(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	report, err := service.RunTestSuite(c.Request.Context(), promptID, suite)
+	if err != nil {
+		respError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respOK(c, report)
+}
+
 // ChatWithPrompt chat with LLM using prompt
 // @Summary Interact with LLM using prompt
-// @Description Chat interaction with LLM using specified prompt template
+// @Description Chat interaction with LLM using specified prompt template.
+// @Description scope selects the overrides of shared variables, over the X-Tenant-Id, X-Project-Id and X-User-Id headers.
+// @Description Chats are limited by the request and token quotas of their user, tenant, prompt and model, reported in X-RateLimit-* headers
 // @Tags Prompts
 // @Accept json
 // @Produce json
 // @Param prompt_id path string true "Prompt template ID"
 // @Param request body service.ChatPromptRequest true "Chat parameters"
 // @Success 200 {object} service.ChatResponse
+// @Header 200,429 {integer} X-RateLimit-Limit-Requests "Request limit of the quota closest to being exhausted"
+// @Header 200,429 {integer} X-RateLimit-Remaining-Requests "Requests remaining in that quota"
+// @Header 200,429 {integer} X-RateLimit-Reset-Requests "Seconds until the window of that quota ends"
+// @Header 200,429 {integer} X-RateLimit-Limit-Tokens "Token limit of the quota closest to being exhausted"
+// @Header 200,429 {integer} X-RateLimit-Remaining-Tokens "Tokens remaining in that quota"
+// @Header 200,429 {integer} X-RateLimit-Reset-Tokens "Seconds until the window of that quota ends"
 // @Failure 400 {object} ResponseData
 // @Failure 404 {object} ResponseData
+// @Failure 429 {object} ResponseData
 // @Failure 500 {object} ResponseData
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /api/prompts/{prompt_id}/chat [post]
 func ChatWithPrompt(c *gin.Context) {
 	promptID := c.Param("prompt_id")
@@ -125,11 +275,17 @@ func ChatWithPrompt(c *gin.Context) {
 		return
 	}
 
-	resp, err := service.ChatWithPrompt(promptID, req)
+	if err := applyScope(c, req.Scope); err != nil {
+		respError(c, http.StatusBadRequest, err)
+		return
+	}
+	ctx, quota := service.WithQuotaReport(c.Request.Context())
+	resp, err := service.ChatWithPrompt(ctx, promptID, req)
+	setQuotaHeaders(c, quota, err)
 	if err != nil {
 		respError(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, resp)
+	respOK(c, resp)
 }
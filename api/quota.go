@@ -0,0 +1,41 @@
+package api
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/zgsm-ai/ai-prompt-shell/internal/utils"
+	"github.com/zgsm-ai/ai-prompt-shell/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+/**
+ * Report the quotas checked for a chat in X-RateLimit-* headers
+ * @description
+ * - X-RateLimit-{Limit,Remaining,Reset}-Requests and -Tokens describe the quota of each kind closest
+ *   to being exhausted; Reset is in seconds
+ * - Retry-After is set when the chat was refused by an exhausted quota
+ */
+func setQuotaHeaders(c *gin.Context, r *service.QuotaReport, err error) {
+	var retry time.Duration
+	for kind, s := range map[string]service.QuotaStatus{"Requests": r.Requests, "Tokens": r.Tokens} {
+		if s.Limit == 0 {
+			continue
+		}
+		reset := time.Until(s.Reset)
+		c.Header("X-RateLimit-Limit-"+kind, strconv.FormatInt(s.Limit, 10))
+		c.Header("X-RateLimit-Remaining-"+kind, strconv.FormatInt(s.Remaining, 10))
+		c.Header("X-RateLimit-Reset-"+kind, strconv.Itoa(int(math.Ceil(reset.Seconds()))))
+		if s.Remaining == 0 && reset > retry {
+			retry = reset
+		}
+	}
+	var httpErr *utils.HttpError
+	if retry > 0 && errors.As(err, &httpErr) && httpErr.Code() == http.StatusTooManyRequests {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retry.Seconds()))))
+	}
+}
@@ -1,6 +1,8 @@
 package api
 
 import (
+	"github.com/zgsm-ai/ai-prompt-shell/internal/auth"
+
 	"github.com/gin-gonic/gin"
 	swaggerfiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
@@ -10,20 +12,60 @@ import (
 func SetupRoutes(r *gin.Engine) {
 	// Add swagger routes
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerfiles.Handler))
+	// Metrics name prompts, so they're for admins when authentication is enabled
+	r.GET("/metrics", authMiddleware, requireRole(auth.RoleAdmin), Metrics)
+
+	// API group, requests get a trace ID, callers are authenticated, then requests are scoped by their X-Tenant-Id, X-Project-Id and X-User-Id headers
+	api := r.Group("/api", traceMiddleware, authMiddleware, scopeMiddleware)
 
-	// API group
-	api := r.Group("/api")
+	// Reading prompts, tools and shared variables
+	reader := api.Group("", requireRole(auth.RoleReader))
+	{
+		reader.GET("/extensions", ListExtensions)
+		reader.GET("/extensions/:extension_id", GetExtensionDetail)
+		reader.GET("/prompts", ListPrompts)
+		reader.GET("/prompts/:prompt_id", GetPromptDetail)
+		reader.GET("/partials", ListPartials)
+		reader.GET("/partials/:partial_id", GetPartialDetail)
+		reader.GET("/tools", ListTools)
+		reader.GET("/tools/:tool_id", GetToolDetail)
+		reader.GET("/environs", ListEnvirons)
+		reader.GET("/environs/:environ_id", GetEnviron)
+		reader.GET("/sync", GetSyncStatus)
+	}
+	// Rendering prompts, chatting and calling tools
+	renderer := api.Group("", requireRole(auth.RoleRenderer))
+	{
+		renderer.POST("/prompts/validate", ValidatePrompt)
+		renderer.POST("/prompts/:prompt_id/render", RenderPrompt)
+		renderer.POST("/prompts/:prompt_id/chat", ChatWithPrompt)
+		renderer.POST("/sessions", CreateSession)
+		renderer.GET("/sessions/:session_id", GetSession)
+		renderer.DELETE("/sessions/:session_id", DeleteSession)
+		renderer.POST("/sessions/:session_id/messages", SendSessionMessage)
+		renderer.POST("/prompts/:prompt_id/test", TestPrompt)
+		renderer.POST("/eval", RunEval)
+		renderer.POST("/tools/:tool_id/call", CallTool)
+	}
+	// Publishing extensions and shared variables
+	publisher := api.Group("", requireRole(auth.RolePublisher))
+	{
+		publisher.PUT("/extensions/:extension_id", InstallExtension)
+		publisher.DELETE("/extensions/:extension_id", UninstallExtension)
+		publisher.POST("/extensions/:extension_id/enable", EnableExtension)
+		publisher.POST("/extensions/:extension_id/disable", DisableExtension)
+		publisher.PUT("/environs/:environ_id", SetEnviron)
+		publisher.DELETE("/environs/:environ_id", DeleteEnviron)
+		publisher.POST("/import", ImportBundle)
+		publisher.POST("/sync", SyncNow)
+	}
+	// Secrets, exports and the audit log, values are write-only but exports reveal every prompt
+	admin := api.Group("", requireRole(auth.RoleAdmin))
 	{
-		api.GET("/extensions", ListExtensions)
-		api.GET("/extensions/:extension_id", GetExtensionDetail)
-		api.GET("/prompts", ListPrompts)
-		api.GET("/prompts/:prompt_id", GetPromptDetail)
-		api.POST("/prompts/:prompt_id/render", RenderPrompt)
-		api.POST("/prompts/:prompt_id/chat", ChatWithPrompt)
-		api.GET("/tools", ListTools)
-		api.GET("/tools/:tool_id", GetToolDetail)
-		// Environment variables routes
-		api.GET("/environs", ListEnvirons)
-		api.GET("/environs/:environ_id", GetEnviron)
+		admin.GET("/secrets", ListSecrets)
+		admin.PUT("/secrets/:secret_id", SetSecret)
+		admin.DELETE("/secrets/:secret_id", DeleteSecret)
+		admin.GET("/export", ExportBundle)
+		admin.GET("/audit", QueryAudit)
 	}
 }
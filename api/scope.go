@@ -0,0 +1,87 @@
+package api
+
+import (
+	"github.com/zgsm-ai/ai-prompt-shell/dao"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/auth"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/utils"
+	"github.com/zgsm-ai/ai-prompt-shell/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Headers selecting the scope of a request
+const (
+	HeaderTenant  = "X-Tenant-Id"
+	HeaderProject = "X-Project-Id"
+	HeaderUser    = "X-User-Id"
+)
+
+/**
+ * Middleware attaching the scope given by the X-Tenant-Id, X-Project-Id and X-User-Id headers to the request context
+ * @description
+ * - An authenticated caller always gets its own user and tenant scopes, X-User-Id and X-Tenant-Id are ignored
+ */
+func scopeMiddleware(c *gin.Context) {
+	scope := dao.EnvScope{
+		Tenant:  c.GetHeader(HeaderTenant),
+		Project: c.GetHeader(HeaderProject),
+		User:    c.GetHeader(HeaderUser),
+	}
+	if id := auth.FromContext(c.Request.Context()); id != nil {
+		scope.User = id.Subject
+		scope.Tenant = id.Tenant
+	}
+	if err := service.CheckEnvScope(scope); err != nil {
+		respError(c, http.StatusBadRequest, err)
+		c.Abort()
+		return
+	}
+	c.Request = c.Request.WithContext(service.WithEnvScope(c.Request.Context(), scope))
+	c.Next()
+}
+
+/**
+ * Override the scope of the request with scope fields of its body
+ * @param fields scope given in the body; each part given overrides the one of the headers,
+ *        except the user and tenant of an authenticated caller
+ * @throws 400 error if an ID is invalid
+ */
+func applyScope(c *gin.Context, fields dao.EnvScope) error {
+	if err := service.CheckEnvScope(fields); err != nil {
+		return err
+	}
+	scope := service.EnvScopeFrom(c.Request.Context())
+	authenticated := auth.FromContext(c.Request.Context()) != nil
+	if fields.Tenant != "" && !authenticated {
+		scope.Tenant = fields.Tenant
+	}
+	if fields.Project != "" {
+		scope.Project = fields.Project
+	}
+	if fields.User != "" && !authenticated {
+		scope.User = fields.User
+	}
+	c.Request = c.Request.WithContext(service.WithEnvScope(c.Request.Context(), scope))
+	return nil
+}
+
+/**
+ * Check that the caller may read or write the overrides of a scope named by the scope parameter
+ * @description
+ * - Admins may name any scope; other authenticated callers only their own tenant and user
+ * @throws 403 error if the scope names another tenant or user
+ */
+func checkScopeAccess(c *gin.Context, scope dao.EnvScope) error {
+	id := auth.FromContext(c.Request.Context())
+	if id == nil || id.HasRole(auth.RoleAdmin) {
+		return nil
+	}
+	if scope.Tenant != "" && scope.Tenant != id.Tenant {
+		return utils.NewHttpError(http.StatusForbidden, "scope names another tenant")
+	}
+	if scope.User != "" && scope.User != id.Subject {
+		return utils.NewHttpError(http.StatusForbidden, "scope names another user")
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"github.com/zgsm-ai/ai-prompt-shell/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListSecrets Get secrets list
+// @Summary List secrets
+// @Description Get the IDs of all secrets. Values of secrets are never returned
+// @Tags Secrets
+// @Produce json
+// @Success 200 {array} string
+// @Failure 500 {object} ResponseData
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/secrets [get]
+func ListSecrets(c *gin.Context) {
+	ids, err := service.ListSecrets()
+	if err != nil {
+		respError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respOK(c, ids)
+}
+
+// SetSecret Set a secret
+// @Summary Set secret
+// @Description Encrypt and store a secret, creating it if it doesn't exist.
+// @Description Tools refer to it as ${secret:secret_id} in their headers
+// @Tags Secrets
+// @Accept json
+// @Produce json
+// @Param secret_id path string true "Secret ID"
+// @Param value body string true "Secret value, a JSON string"
+// @Success 200 {object} ResponseData
+// @Failure 400 {object} ResponseData
+// @Failure 500 {object} ResponseData
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/secrets/{secret_id} [put]
+func SetSecret(c *gin.Context) {
+	secretID := c.Param("secret_id")
+
+	var val string
+	if err := c.ShouldBindJSON(&val); err != nil {
+		respErrorf(c, http.StatusBadRequest, "invalid request body, a JSON string is expected")
+		return
+	}
+	if err := service.SetSecret(c.Request.Context(), secretID, val); err != nil {
+		respError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respOK(c, ResponseData{
+		Code:    "0",
+		Message: "OK",
+		Success: true,
+	})
+}
+
+// DeleteSecret Delete a secret
+// @Summary Delete secret
+// @Description Delete specified secret
+// @Tags Secrets
+// @Produce json
+// @Param secret_id path string true "Secret ID"
+// @Success 200 {object} ResponseData
+// @Failure 404 {object} ResponseData
+// @Failure 500 {object} ResponseData
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/secrets/{secret_id} [delete]
+func DeleteSecret(c *gin.Context) {
+	if err := service.DeleteSecret(c.Request.Context(), c.Param("secret_id")); err != nil {
+		respError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respOK(c, ResponseData{
+		Code:    "0",
+		Message: "OK",
+		Success: true,
+	})
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/zgsm-ai/ai-prompt-shell/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateSession Create a chat session
+// @Summary Create session
+// @Description Render a prompt and start a multi-turn chat whose history begins with the rendered messages.
+// @Description scope selects the overrides of shared variables, as for chats. The model and sampling parameters apply to every turn.
+// @Description Sessions expire after the configured TTL without messages
+// @Tags Sessions
+// @Accept json
+// @Produce json
+// @Param request body service.CreateSessionRequest true "Prompt, args and chat parameters"
+// @Success 200 {object} service.ChatSession
+// @Failure 400 {object} ResponseData
+// @Failure 404 {object} ResponseData
+// @Failure 413 {object} ResponseData
+// @Failure 422 {object} ResponseData
+// @Failure 500 {object} ResponseData
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/sessions [post]
+func CreateSession(c *gin.Context) {
+	var req service.CreateSessionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respErrorf(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if err := applyScope(c, req.Scope); err != nil {
+		respError(c, http.StatusBadRequest, err)
+		return
+	}
+	session, err := service.CreateSession(c.Request.Context(), req)
+	if err != nil {
+		respError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respOK(c, session)
+}
+
+// GetSession Get a chat session
+// @Summary Get session
+// @Description Get a session with its history. Sessions are found by their creator and admins only
+// @Tags Sessions
+// @Produce json
+// @Param session_id path string true "Session ID"
+// @Success 200 {object} service.ChatSession
+// @Failure 404 {object} ResponseData
+// @Failure 500 {object} ResponseData
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/sessions/{session_id} [get]
+func GetSession(c *gin.Context) {
+	session, err := service.GetSession(c.Request.Context(), c.Param("session_id"))
+	if err != nil {
+		respError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respOK(c, session)
+}
+
+// DeleteSession Delete a chat session
+// @Summary Delete session
+// @Description End a session before it expires
+// @Tags Sessions
+// @Produce json
+// @Param session_id path string true "Session ID"
+// @Success 200 {object} ResponseData
+// @Failure 404 {object} ResponseData
+// @Failure 500 {object} ResponseData
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/sessions/{session_id} [delete]
+func DeleteSession(c *gin.Context) {
+	if err := service.DeleteSession(c.Request.Context(), c.Param("session_id")); err != nil {
+		respError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respOK(c, ResponseData{
+		Code:    "0",
+		Message: "OK",
+		Success: true,
+	})
+}
+
+// SendSessionMessage Send a message in a chat session
+// @Summary Send session message
+// @Description Append a user turn to a session and get it answered by the LLM; both are added to the history.
+// @Description An empty content gets the last user message of the history answered, such as that of the prompt.
+// @Description Histories outgrowing the model context are trimmed or summarized. Turns are limited by quotas as chats, reported in X-RateLimit-* headers
+// @Tags Sessions
+// @Accept json
+// @Produce json
+// @Param session_id path string true "Session ID"
+// @Param request body service.SessionMessageRequest true "User message"
+// @Success 200 {object} service.ChatResponse
+// @Header 200,429 {integer} X-RateLimit-Remaining-Requests "Requests remaining in the quota closest to being exhausted"
+// @Header 200,429 {integer} X-RateLimit-Remaining-Tokens "Tokens remaining in the quota closest to being exhausted"
+// @Failure 400 {object} ResponseData
+// @Failure 404 {object} ResponseData
+// @Failure 409 {object} ResponseData
+// @Failure 413 {object} ResponseData
+// @Failure 422 {object} ResponseData
+// @Failure 429 {object} ResponseData
+// @Failure 500 {object} ResponseData
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/sessions/{session_id}/messages [post]
+func SendSessionMessage(c *gin.Context) {
+	var req service.SessionMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respErrorf(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	ctx, quota := service.WithQuotaReport(c.Request.Context())
+	resp, err := service.SendSessionMessage(ctx, c.Param("session_id"), req)
+	setQuotaHeaders(c, quota, err)
+	if err != nil {
+		respError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respOK(c, resp)
+}
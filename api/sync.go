@@ -0,0 +1,54 @@
+package api
+
+import (
+	"github.com/zgsm-ai/ai-prompt-shell/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// GetSyncStatus get git sync status
+// @Summary Get git sync status
+// @Description Get the repository synced, the published commit (snapshot) with the items it published,
+// @Description and the last commit fetched with the problems that made it refused, if any
+// @Tags Sync
+// @Produce json
+// @Success 200 {object} service.SyncStatus
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/sync [get]
+func GetSyncStatus(c *gin.Context) {
+	respOK(c, service.GetSyncStatus())
+}
+
+// SyncNow sync git repository now
+// @Summary Sync git repository now
+// @Description Fetch the latest commit of the configured repository, validate it and publish it, without waiting for the sync interval.
+// @Description A commit with problems is refused and the published items stay as they were; the response data holds the sync status with the problems found
+// @Tags Sync
+// @Produce json
+// @Success 200 {object} service.SyncStatus
+// @Failure 400 {object} ResponseData
+// @Failure 409 {object} ResponseData
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/sync [post]
+func SyncNow(c *gin.Context) {
+	status, err := service.SyncNow(c.Request.Context(), true)
+	if err == service.ErrSyncDisabled {
+		respErrorf(c, http.StatusBadRequest, "%s", err.Error())
+		return
+	}
+	if err != nil {
+		logrus.Errorf("request: %+v, error: %s", c.Request.RequestURI, err.Error())
+		c.JSON(http.StatusConflict, ResponseData{
+			Code:    "409",
+			Message: err.Error(),
+			Success: false,
+			Data:    status,
+		})
+		return
+	}
+	respOK(c, status)
+}
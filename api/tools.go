@@ -9,18 +9,37 @@ import (
 
 // ListTools list all available tools
 // @Summary List all tools
-// @Description Get a list of all available tools in the system
+// @Description Get available tools in the system, sorted by ID. Returns IDs by default, or summaries if summary=true
 // @Tags Tools
 // @Produce json
-// @Success 200 {array} string
+// @Param supports query string false "Only tools supporting this scene, e.g. chat"
+// @Param q query string false "Free-text search over ID, name and description"
+// @Param page query int false "Page number, starting from 1"
+// @Param page_size query int false "Page size, 0 means no pagination"
+// @Param summary query bool false "Return summaries instead of IDs"
+// @Success 200 {array} string "Tool IDs, or service.ToolSummary objects if summary=true"
+// @Header 200 {int} X-Total-Count "Number of matching tools before pagination"
+// @Failure 400 {object} ResponseData
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /api/tools [get]
 func ListTools(c *gin.Context) {
-	tools, err := service.ToolIDs()
+	opts, summary, err := parseListOptions(c)
 	if err != nil {
-		respError(c, http.StatusInternalServerError, err)
+		respError(c, http.StatusBadRequest, err)
+		return
+	}
+	items, total := service.ListTools(opts)
+	setTotalCount(c, total)
+	if summary {
+		respOK(c, items)
 		return
 	}
-	respOK(c, tools)
+	ids := make([]string, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.ID)
+	}
+	respOK(c, ids)
 }
 
 // GetToolDetail get tool details
@@ -31,6 +50,8 @@ func ListTools(c *gin.Context) {
 // @Param tool_id path string true "工具ID"
 // @Success 200 {object} dao.Tool
 // @Failure 404 {object} ResponseData
+// @Security BearerAuth
+// @Security ApiKeyAuth
 // @Router /api/tools/{tool_id} [get]
 func GetToolDetail(c *gin.Context) {
 	toolID := c.Param("tool_id")
@@ -43,3 +64,42 @@ func GetToolDetail(c *gin.Context) {
 
 	respOK(c, toolDetail)
 }
+
+type CallToolRequest struct {
+	Args []interface{} `json:"args"`
+}
+
+type CallToolResponse struct {
+	Result interface{} `json:"result"`
+}
+
+// CallTool call tool
+// @Summary Call tool
+// @Description Call specified tool with given args, as a template would, and return its result
+// @Tags Tools
+// @Accept json
+// @Produce json
+// @Param tool_id path string true "Tool ID"
+// @Param request body CallToolRequest true "Tool arguments"
+// @Success 200 {object} CallToolResponse
+// @Failure 400 {object} ResponseData
+// @Failure 404 {object} ResponseData
+// @Failure 502 {object} ResponseData
+// @Security BearerAuth
+// @Security ApiKeyAuth
+// @Router /api/tools/{tool_id}/call [post]
+func CallTool(c *gin.Context) {
+	toolID := c.Param("tool_id")
+
+	var req CallToolRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respErrorf(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	result, err := service.CallTool(c.Request.Context(), toolID, req.Args)
+	if err != nil {
+		respError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respOK(c, CallToolResponse{Result: result})
+}
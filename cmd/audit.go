@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/zgsm-ai/ai-prompt-shell/service"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	auditFilter service.AuditFilter
+	auditSince  string
+	auditUntil  string
+	auditJSON   bool
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Query the audit log of configuration changes and chats",
+	Long: `Query the audit log of configuration changes and chats, newest first.
+
+--since and --until take RFC 3339 times, or durations back from now such as 24h.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		query := url.Values{}
+		for name, value := range map[string]string{
+			"type":     auditFilter.Type,
+			"actor":    auditFilter.Actor,
+			"kind":     auditFilter.Kind,
+			"target":   auditFilter.Target,
+			"prompt":   auditFilter.Prompt,
+			"model":    auditFilter.Model,
+			"trace_id": auditFilter.TraceID,
+			"session":  auditFilter.Session,
+		} {
+			if value != "" {
+				query.Set(name, value)
+			}
+		}
+		for name, value := range map[string]string{"since": auditSince, "until": auditUntil} {
+			if value == "" {
+				continue
+			}
+			t, err := parseAuditTime(value)
+			if err != nil {
+				return fmt.Errorf("invalid --%s: %v", name, err)
+			}
+			query.Set(name, t.Format(time.RFC3339))
+		}
+		if auditFilter.Limit > 0 {
+			query.Set("limit", strconv.Itoa(auditFilter.Limit))
+		}
+
+		c, err := newClient()
+		if err != nil {
+			return err
+		}
+		var records []service.AuditRecord
+		if err := c.do(http.MethodGet, "/api/audit?"+query.Encode(), nil, &records); err != nil {
+			return err
+		}
+		if auditJSON {
+			return printJSON(records)
+		}
+		for _, r := range records {
+			when := r.Time.Local().Format("2006-01-02 15:04:05")
+			if r.Type == service.AuditChange {
+				fmt.Printf("%s  %-12s %-6s %-10s %s\n", when, r.Actor, r.Action, r.Kind, r.Target)
+				continue
+			}
+			line := fmt.Sprintf("%s  %-12s chat   %s@%s %s", when, r.Actor, r.Prompt, r.Version, r.Model)
+			if r.Usage != nil {
+				line += fmt.Sprintf(" %d tokens", r.Usage.TotalTokens)
+			}
+			if r.Error != "" {
+				line += " error: " + r.Error
+			}
+			fmt.Println(line)
+		}
+		return nil
+	},
+}
+
+/**
+ * Parse an RFC 3339 time, or a duration back from now
+ */
+func parseAuditTime(s string) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+func init() {
+	auditCmd.Flags().StringVar(&auditFilter.Type, "type", "", "record type, change or chat")
+	auditCmd.Flags().StringVar(&auditFilter.Actor, "actor", "", "caller who made the change or chat")
+	auditCmd.Flags().StringVar(&auditFilter.Kind, "kind", "", "kind of item changed, e.g. prompts or environs")
+	auditCmd.Flags().StringVar(&auditFilter.Target, "target", "", "ID of the item changed")
+	auditCmd.Flags().StringVar(&auditFilter.Prompt, "prompt", "", "prompt of the chat")
+	auditCmd.Flags().StringVar(&auditFilter.Model, "model", "", "model of the chat")
+	auditCmd.Flags().StringVar(&auditFilter.TraceID, "trace-id", "", "trace ID of the request")
+	auditCmd.Flags().StringVar(&auditFilter.Session, "session", "", "session of the chat")
+	auditCmd.Flags().StringVar(&auditSince, "since", "", "earliest time")
+	auditCmd.Flags().StringVar(&auditUntil, "until", "", "latest time")
+	auditCmd.Flags().IntVar(&auditFilter.Limit, "limit", 0, "maximum number of records, 100 by default")
+	auditCmd.Flags().BoolVar(&auditJSON, "json", false, "print the records as JSON, with diffs and payloads")
+
+	rootCmd.AddCommand(auditCmd)
+}
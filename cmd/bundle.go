@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/zgsm-ai/ai-prompt-shell/service"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportOutput string
+	exportFormat string
+	exportOnly   []string
+	importMode   string
+	importDryRun bool
+	importOnly   []string
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export extensions, prompts, tools, partials and shared variables as a JSON or tar bundle",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := newClient()
+		if err != nil {
+			return err
+		}
+		query := url.Values{}
+		query.Set("format", exportFormat)
+		if len(exportOnly) > 0 {
+			query.Set("prefixes", strings.Join(exportOnly, ","))
+		}
+		data, err := c.send(http.MethodGet, "/api/export?"+query.Encode(), "", nil)
+		if err != nil {
+			return err
+		}
+		if exportFormat == "json" {
+			var buf bytes.Buffer
+			if err := json.Indent(&buf, data, "", "  "); err != nil {
+				return err
+			}
+			buf.WriteByte('\n')
+			data = buf.Bytes()
+		}
+		if exportOutput == "" {
+			_, err = os.Stdout.Write(data)
+			return err
+		}
+		return os.WriteFile(exportOutput, data, 0644)
+	},
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import BUNDLE_FILE",
+	Short: "Import a JSON or tar bundle written by export",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			return err
+		}
+		c, err := newClient()
+		if err != nil {
+			return err
+		}
+		query := url.Values{}
+		query.Set("mode", importMode)
+		if importDryRun {
+			query.Set("dry_run", "true")
+		}
+		if len(importOnly) > 0 {
+			query.Set("prefixes", strings.Join(importOnly, ","))
+		}
+		contentType := "application/x-tar"
+		if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+			contentType = "application/json"
+		}
+		out, err := c.send(http.MethodPost, "/api/import?"+query.Encode(), contentType, bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		var result service.ImportResult
+		if err := json.Unmarshal(out, &result); err != nil {
+			return fmt.Errorf("invalid response from /api/import: %v", err)
+		}
+		for _, change := range result.Changes {
+			fmt.Printf("%-6s %-10s %s\n", change.Action, change.Kind, change.ID)
+		}
+		verb := "imported"
+		if result.DryRun {
+			verb = "would import"
+		}
+		fmt.Printf("%s: %d created, %d updated, %d deleted, %d unchanged\n",
+			verb, result.Created, result.Updated, result.Deleted, result.Unchanged)
+		return nil
+	},
+}
+
+func init() {
+	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "write the bundle to this file instead of stdout")
+	exportCmd.Flags().StringVar(&exportFormat, "format", "json", "bundle format, json or tar")
+	exportCmd.Flags().StringSliceVar(&exportOnly, "only", nil, "kinds to export: environs, scopes, secrets, tools, partials, extensions, prompts")
+	importCmd.Flags().StringVar(&importMode, "mode", service.ImportMerge, "merge, or replace to also delete items missing from the bundle")
+	importCmd.Flags().BoolVar(&importDryRun, "dry-run", false, "only show the changes the import would make")
+	importCmd.Flags().StringSliceVar(&importOnly, "only", nil, "kinds to import, by default those the bundle was exported with")
+
+	rootCmd.AddCommand(exportCmd, importCmd)
+}
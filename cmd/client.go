@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/zgsm-ai/ai-prompt-shell/api"
+	"github.com/zgsm-ai/ai-prompt-shell/dao"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/config"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/logger"
+	"github.com/zgsm-ai/ai-prompt-shell/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+/**
+ * Client of the prompt shell API
+ * @description
+ * - With --server, requests go to the running server over HTTP
+ * - Otherwise they're served in process by the same routes, on services loaded from storage,
+ *   so both modes behave exactly alike
+ * - Requests carry the --scope setting in the X-Tenant-Id, X-Project-Id and X-User-Id headers,
+ *   and the --token setting as a bearer token
+ */
+type client struct {
+	base  string
+	http  *http.Client
+	scope dao.EnvScope
+	token string
+}
+
+// Whether services were initialized in this process
+var local bool
+
+/**
+ * Create a client for the command's --server setting
+ */
+func newClient() (*client, error) {
+	scope, err := service.ParseEnvScope(scopeFlag)
+	if err != nil {
+		return nil, fmt.Errorf("--scope: %v", err)
+	}
+	if serverAddr != "" {
+		return &client{
+			base:  strings.TrimRight(serverAddr, "/"),
+			http:  &http.Client{Timeout: 10 * time.Minute},
+			scope: scope,
+			token: tokenFlag,
+		}, nil
+	}
+	if err := initCommand(); err != nil {
+		return nil, err
+	}
+	gin.SetMode(gin.ReleaseMode)
+	r := gin.New()
+	api.SetupRoutes(r)
+	return &client{
+		base:  "http://localhost",
+		http:  &http.Client{Transport: handlerTransport{r}},
+		scope: scope,
+	}, nil
+}
+
+/**
+ * Initialize configuration, storage and services for a command-line subcommand
+ * Logging is limited to errors so that it doesn't mix with the command output
+ */
+func initCommand() error {
+	if local {
+		return nil
+	}
+	cfg := config.Load()
+	cfg.Logger.LogLevel = "error"
+	// Publishing from git is left to the server
+	cfg.Sync.Repo = ""
+	logger.Init(&cfg.Logger)
+	if err := initStorage(cfg); err != nil {
+		return err
+	}
+	if err := service.Init(cfg); err != nil {
+		return fmt.Errorf("Service initialization failed: %v", err)
+	}
+	local = true
+	return nil
+}
+
+/**
+ * Round tripper serving requests with an in-process handler
+ */
+type handlerTransport struct {
+	handler http.Handler
+}
+
+func (t handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	w := httptest.NewRecorder()
+	t.handler.ServeHTTP(w, req)
+	return w.Result(), nil
+}
+
+/**
+ * Error response of the API
+ */
+type apiError struct {
+	Status int
+	Body   api.ResponseData
+}
+
+func (e *apiError) Error() string {
+	msg := e.Body.Message
+	if msg == "" {
+		msg = http.StatusText(e.Status)
+	}
+	return fmt.Sprintf("%s (%d)", msg, e.Status)
+}
+
+/**
+ * Send a request to the API and decode its JSON response
+ * @param method HTTP method
+ * @param path request path with query, IDs in it must be escaped with pathID
+ * @param body request body encoded as JSON, nil for none
+ * @param out where to decode a successful response, nil to discard it
+ * @return *apiError if the API answers with an error status
+ */
+func (c *client) do(method, path string, body, out interface{}) error {
+	var reader io.Reader
+	contentType := ""
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+		contentType = "application/json"
+	}
+	data, err := c.send(method, path, contentType, reader)
+	if err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("invalid response from %s: %v", path, err)
+	}
+	return nil
+}
+
+/**
+ * Send a request to the API and read its raw response
+ * @param contentType content type of body, empty for none
+ * @return response body of a successful response
+ * @return *apiError if the API answers with an error status
+ */
+func (c *client) send(method, path, contentType string, body io.Reader) ([]byte, error) {
+	req, err := http.NewRequest(method, c.base+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	for header, id := range map[string]string{
+		api.HeaderTenant:  c.scope.Tenant,
+		api.HeaderProject: c.scope.Project,
+		api.HeaderUser:    c.scope.User,
+	} {
+		if id != "" {
+			req.Header.Set(header, id)
+		}
+	}
+	rsp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+	data, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if rsp.StatusCode/100 != 2 {
+		e := &apiError{Status: rsp.StatusCode}
+		json.Unmarshal(data, &e.Body)
+		return nil, e
+	}
+	return data, nil
+}
+
+/**
+ * Escape an ID for use as a path segment
+ */
+func pathID(id string) string {
+	return url.PathEscape(id)
+}
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/zgsm-ai/ai-prompt-shell/service"
+
+	"github.com/spf13/cobra"
+)
+
+var envCmd = &cobra.Command{
+	Use:   "env",
+	Short: "List, show, set and delete shared variables",
+	Long: `List, show, set and delete shared variables.
+
+With --scope, list and get show the variables seen by that scope, and set and delete work on
+the overrides of one scope, e.g. --scope tenant:acme.`,
+}
+
+var envListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List shared variables",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := newClient()
+		if err != nil {
+			return err
+		}
+		var ids []string
+		if err := c.do(http.MethodGet, "/api/environs", nil, &ids); err != nil {
+			return err
+		}
+		printList(ids)
+		return nil
+	},
+}
+
+var envGetCmd = &cobra.Command{
+	Use:   "get ENVIRON_ID",
+	Short: "Show the value of a shared variable",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := newClient()
+		if err != nil {
+			return err
+		}
+		if scopeFlag == "" {
+			var val interface{}
+			if err := c.do(http.MethodGet, "/api/environs/"+pathID(args[0]), nil, &val); err != nil {
+				return err
+			}
+			return printValue(val)
+		}
+		var val service.EnvironValue
+		if err := c.do(http.MethodGet, "/api/environs/"+pathID(args[0])+scopeQuery(), nil, &val); err != nil {
+			return err
+		}
+		return printJSON(val)
+	},
+}
+
+var envSetString bool
+
+var envSetCmd = &cobra.Command{
+	Use:   "set ENVIRON_ID VALUE",
+	Short: "Set the value of a shared variable",
+	Long: `Set the value of a shared variable, creating it if it doesn't exist.
+
+VALUE is stored as JSON if it parses as JSON, otherwise as a string. Use --string to always
+store it as a string, and @FILE to read it from a file.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		data, err := readArg(args[1])
+		if err != nil {
+			return err
+		}
+		var val interface{}
+		if envSetString || json.Unmarshal(data, &val) != nil {
+			val = string(data)
+		}
+		c, err := newClient()
+		if err != nil {
+			return err
+		}
+		return c.do(http.MethodPut, "/api/environs/"+pathID(args[0])+scopeQuery(), val, nil)
+	},
+}
+
+var envDeleteCmd = &cobra.Command{
+	Use:   "delete ENVIRON_ID",
+	Short: "Delete a shared variable, or its override in the scope given by --scope",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := newClient()
+		if err != nil {
+			return err
+		}
+		return c.do(http.MethodDelete, "/api/environs/"+pathID(args[0])+scopeQuery(), nil, nil)
+	},
+}
+
+/**
+ * Query passing --scope to the environs API, empty if not given
+ */
+func scopeQuery() string {
+	if scopeFlag == "" {
+		return ""
+	}
+	return "?scope=" + url.QueryEscape(scopeFlag)
+}
+
+func init() {
+	envSetCmd.Flags().BoolVar(&envSetString, "string", false, "store VALUE as a string even if it is valid JSON")
+
+	envCmd.AddCommand(envListCmd, envGetCmd, envSetCmd, envDeleteCmd)
+	rootCmd.AddCommand(envCmd)
+}
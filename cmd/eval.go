@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/zgsm-ai/ai-prompt-shell/service"
+
+	"github.com/spf13/cobra"
+)
+
+var evalFlags struct {
+	dataset     string
+	prompts     []string
+	models      []string
+	scorers     []string
+	judgePrompt string
+	judgeModel  string
+	judgeMax    float64
+	concurrency int
+	output      string
+}
+
+var evalCmd = &cobra.Command{
+	Use:   "eval --dataset FILE --prompts IDS --models MODELS",
+	Short: "Evaluate prompts and models over a dataset",
+	Long: `Chat with every prompt and model over each dataset sample and compare their scores.
+
+The dataset is a JSONL file, one {"id", "args", "expected"} per line.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		f, err := os.Open(evalFlags.dataset)
+		if err != nil {
+			return err
+		}
+		samples, err := service.ParseDataset(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %v", evalFlags.dataset, err)
+		}
+
+		c, err := newClient()
+		if err != nil {
+			return err
+		}
+		var report service.EvalReport
+		err = c.do(http.MethodPost, "/api/eval", service.EvalRequest{
+			Prompts:       evalFlags.prompts,
+			Models:        evalFlags.models,
+			Dataset:       samples,
+			Scorers:       evalFlags.scorers,
+			JudgePrompt:   evalFlags.judgePrompt,
+			JudgeModel:    evalFlags.judgeModel,
+			JudgeMaxScore: evalFlags.judgeMax,
+			Concurrency:   evalFlags.concurrency,
+		}, &report)
+		if err != nil {
+			return err
+		}
+
+		printEvalReport(report)
+		if evalFlags.output != "" {
+			data, _ := json.MarshalIndent(report, "", "  ")
+			return os.WriteFile(evalFlags.output, data, 0644)
+		}
+		return nil
+	},
+}
+
+func printEvalReport(report service.EvalReport) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "PROMPT\tMODEL\tSAMPLES\tERRORS\t%s\tLATENCY(ms)\tTOKENS\n", strings.ToUpper(strings.Join(report.Scorers, "\t")))
+	for _, v := range report.Variants {
+		var scores []string
+		for _, s := range report.Scorers {
+			scores = append(scores, fmt.Sprintf("%.3f", v.Scores[s]))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\t%d\t%d\n", v.Prompt, v.Model, v.Samples, v.Errors, strings.Join(scores, "\t"), v.AvgLatencyMs, v.Tokens)
+	}
+	w.Flush()
+
+	scorers := make([]string, 0, len(report.Best))
+	for s := range report.Best {
+		scorers = append(scorers, s)
+	}
+	sort.Strings(scorers)
+	for _, s := range scorers {
+		fmt.Printf("best %s: %s\n", s, report.Best[s])
+	}
+}
+
+func init() {
+	f := evalCmd.Flags()
+	f.StringVar(&evalFlags.dataset, "dataset", "", "dataset file in JSONL format")
+	f.StringSliceVar(&evalFlags.prompts, "prompts", nil, "comma-separated prompt IDs to compare")
+	f.StringSliceVar(&evalFlags.models, "models", nil, "comma-separated models to compare")
+	f.StringSliceVar(&evalFlags.scorers, "scorers", nil, "comma-separated scorers: exact, schema, judge")
+	f.StringVar(&evalFlags.judgePrompt, "judge-prompt", "", "prompt ID of the judge, required by the judge scorer")
+	f.StringVar(&evalFlags.judgeModel, "judge-model", "", "model of the judge, defaults to the model being evaluated")
+	f.Float64Var(&evalFlags.judgeMax, "judge-max-score", 1, "maximum score given by the judge")
+	f.IntVar(&evalFlags.concurrency, "concurrency", 4, "maximum number of concurrent chats")
+	f.StringVarP(&evalFlags.output, "output", "o", "", "write the full JSON report to this file")
+	evalCmd.MarkFlagRequired("dataset")
+	evalCmd.MarkFlagRequired("prompts")
+	evalCmd.MarkFlagRequired("models")
+
+	rootCmd.AddCommand(evalCmd)
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/zgsm-ai/ai-prompt-shell/dao"
+
+	"github.com/spf13/cobra"
+)
+
+var extensionCmd = &cobra.Command{
+	Use:     "extension",
+	Aliases: []string{"ext"},
+	Short:   "Install, list and remove prompt extensions",
+}
+
+var extensionList listFlags
+
+var extensionListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List installed extensions",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return extensionList.run("/api/extensions")
+	},
+}
+
+var extensionInstallID string
+
+var extensionInstallCmd = &cobra.Command{
+	Use:   "install PACKAGE_JSON",
+	Short: "Install an extension from its package.json",
+	Long: `Install an extension from its package.json, or update it if already installed.
+
+The extension is installed under its name, unless --id is given.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			return err
+		}
+		var ext dao.PromptExtension
+		if err := json.Unmarshal(data, &ext); err != nil {
+			return fmt.Errorf("%s: %v", args[0], err)
+		}
+		id := extensionInstallID
+		if id == "" {
+			id = ext.Name
+		}
+		if id == "" {
+			return fmt.Errorf("%s: extension has no name, use --id", args[0])
+		}
+		c, err := newClient()
+		if err != nil {
+			return err
+		}
+		if err := c.do(http.MethodPut, "/api/extensions/"+pathID(id), ext, nil); err != nil {
+			return err
+		}
+		fmt.Printf("installed %s\n", id)
+		return nil
+	},
+}
+
+var extensionRemoveCmd = &cobra.Command{
+	Use:     "remove EXTENSION_ID",
+	Aliases: []string{"uninstall"},
+	Short:   "Remove an installed extension and its prompts",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := newClient()
+		if err != nil {
+			return err
+		}
+		return c.do(http.MethodDelete, "/api/extensions/"+pathID(args[0]), nil, nil)
+	},
+}
+
+func init() {
+	extensionList.bind(extensionListCmd, "language", "publisher")
+	extensionInstallCmd.Flags().StringVar(&extensionInstallID, "id", "", "extension ID, defaults to the extension's name")
+
+	extensionCmd.AddCommand(extensionListCmd, extensionInstallCmd, extensionRemoveCmd)
+	rootCmd.AddCommand(extensionCmd)
+}
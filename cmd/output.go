@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+/**
+ * Print a value as indented JSON, or as-is if it's a plain string
+ */
+func printValue(v interface{}) error {
+	if s, ok := v.(string); ok {
+		fmt.Println(s)
+		return nil
+	}
+	return printJSON(v)
+}
+
+/**
+ * Print a value as indented JSON
+ */
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(v)
+}
+
+/**
+ * Print a list of IDs, one per line
+ */
+func printList(ids []string) {
+	for _, id := range ids {
+		fmt.Println(id)
+	}
+}
+
+/**
+ * Decode a JSON command argument, inline or "@path"
+ * @param name flag or argument name for error messages
+ * @param value argument value, empty leaves out unchanged
+ */
+func decodeArg(name, value string, out interface{}) error {
+	if value == "" {
+		return nil
+	}
+	data, err := readArg(value)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("invalid JSON in %s: %v", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,244 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/zgsm-ai/ai-prompt-shell/api"
+	"github.com/zgsm-ai/ai-prompt-shell/dao"
+	"github.com/zgsm-ai/ai-prompt-shell/service"
+
+	"github.com/spf13/cobra"
+)
+
+var promptCmd = &cobra.Command{
+	Use:   "prompt",
+	Short: "List, show, render and chat with prompt templates",
+}
+
+/**
+ * Filters shared by list subcommands, mapped to list endpoint query parameters
+ */
+type listFlags struct {
+	supports  string
+	language  string
+	origin    string
+	publisher string
+	query     string
+	summary   bool
+}
+
+func (f *listFlags) bind(cmd *cobra.Command, filters ...string) {
+	for _, name := range filters {
+		switch name {
+		case "supports":
+			cmd.Flags().StringVar(&f.supports, "supports", "", "only items supporting this scene")
+		case "language":
+			cmd.Flags().StringVar(&f.language, "language", "", "only items supporting this language")
+		case "origin":
+			cmd.Flags().StringVar(&f.origin, "origin", "", "only prompts of this origin (direct/extension)")
+		case "publisher":
+			cmd.Flags().StringVar(&f.publisher, "publisher", "", "only items of this publisher")
+		}
+	}
+	cmd.Flags().StringVarP(&f.query, "query", "q", "", "free-text search over ID, name and description")
+	cmd.Flags().BoolVar(&f.summary, "summary", false, "print summaries as JSON instead of IDs")
+}
+
+func (f *listFlags) path(base string) string {
+	q := url.Values{}
+	for name, v := range map[string]string{
+		"supports":  f.supports,
+		"language":  f.language,
+		"origin":    f.origin,
+		"publisher": f.publisher,
+		"q":         f.query,
+	} {
+		if v != "" {
+			q.Set(name, v)
+		}
+	}
+	if f.summary {
+		q.Set("summary", "true")
+	}
+	if len(q) == 0 {
+		return base
+	}
+	return base + "?" + q.Encode()
+}
+
+/**
+ * Run a list endpoint and print IDs, or summaries as JSON
+ */
+func (f *listFlags) run(base string) error {
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+	if f.summary {
+		var items []interface{}
+		if err := c.do(http.MethodGet, f.path(base), nil, &items); err != nil {
+			return err
+		}
+		return printJSON(items)
+	}
+	var ids []string
+	if err := c.do(http.MethodGet, f.path(base), nil, &ids); err != nil {
+		return err
+	}
+	printList(ids)
+	return nil
+}
+
+var promptList listFlags
+
+var promptListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List prompt templates",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return promptList.run("/api/prompts")
+	},
+}
+
+var promptGetCmd = &cobra.Command{
+	Use:   "get PROMPT_ID",
+	Short: "Show a prompt template",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := newClient()
+		if err != nil {
+			return err
+		}
+		var detail interface{}
+		if err := c.do(http.MethodGet, "/api/prompts/"+pathID(args[0]), nil, &detail); err != nil {
+			return err
+		}
+		return printJSON(detail)
+	},
+}
+
+var renderFlags struct {
+	args     string
+	model    string
+	tokens   int
+	mocks    string
+	toolMode string
+	fixture  string
+	json     bool
+}
+
+var promptRenderCmd = &cobra.Command{
+	Use:   "render PROMPT_ID",
+	Short: "Render a prompt template",
+	Long: `Render a prompt template and print the prompt, or the messages of a chat template.
+
+Arguments and mocks are JSON objects, given inline or as @FILE.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		req := api.RenderPromptRequest{
+			Model:     renderFlags.model,
+			MaxTokens: renderFlags.tokens,
+			ToolMode:  renderFlags.toolMode,
+			Fixture:   renderFlags.fixture,
+		}
+		if err := decodeArg("--args", renderFlags.args, &req.Args); err != nil {
+			return err
+		}
+		if err := decodeArg("--mocks", renderFlags.mocks, &req.Mocks); err != nil {
+			return err
+		}
+		c, err := newClient()
+		if err != nil {
+			return err
+		}
+		var rsp api.RenderPromptResponse
+		if err := c.do(http.MethodPost, "/api/prompts/"+pathID(args[0])+"/render", req, &rsp); err != nil {
+			return err
+		}
+		if renderFlags.json {
+			return printJSON(rsp)
+		}
+		if rsp.Kind == "prompt" {
+			fmt.Println(rsp.Prompt)
+		} else {
+			printMessages(rsp.Messages)
+		}
+		return nil
+	},
+}
+
+var chatFlags struct {
+	args        string
+	model       string
+	temperature float64
+	tokens      int
+	json        bool
+}
+
+var promptChatCmd = &cobra.Command{
+	Use:   "chat PROMPT_ID",
+	Short: "Render a prompt template and send it to the LLM",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		req := service.ChatPromptRequest{
+			Model:       chatFlags.model,
+			Temperature: chatFlags.temperature,
+			MaxTokens:   chatFlags.tokens,
+		}
+		if err := decodeArg("--args", chatFlags.args, &req.Args); err != nil {
+			return err
+		}
+		c, err := newClient()
+		if err != nil {
+			return err
+		}
+		var rsp service.ChatResponse
+		if err := c.do(http.MethodPost, "/api/prompts/"+pathID(args[0])+"/chat", req, &rsp); err != nil {
+			return err
+		}
+		if chatFlags.json {
+			return printJSON(rsp)
+		}
+		for _, choice := range rsp.Choices {
+			fmt.Println(choice.Message.Content)
+		}
+		return nil
+	},
+}
+
+/**
+ * Print chat messages as role headers followed by their content
+ */
+func printMessages(messages []dao.Message) {
+	for i, m := range messages {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("[%s]\n%s\n", m.Role, m.Content)
+	}
+}
+
+func init() {
+	promptList.bind(promptListCmd, "supports", "language", "origin", "publisher")
+
+	f := promptRenderCmd.Flags()
+	f.StringVarP(&renderFlags.args, "args", "a", "", "template arguments as a JSON object, or @FILE")
+	f.StringVar(&renderFlags.model, "model", "", "model to count tokens for and check the budget against")
+	f.IntVar(&renderFlags.tokens, "max-tokens", 0, "completion tokens to reserve from the --model context when applying the budget")
+	f.StringVar(&renderFlags.mocks, "mocks", "", "canned tool results as a JSON object keyed by tool ID, or @FILE")
+	f.StringVar(&renderFlags.toolMode, "tool-mode", "", "record or replay tool calls with --fixture")
+	f.StringVar(&renderFlags.fixture, "fixture", "", "fixture name for --tool-mode")
+	f.BoolVar(&renderFlags.json, "json", false, "print the full response as JSON, with token counts")
+
+	f = promptChatCmd.Flags()
+	f.StringVarP(&chatFlags.args, "args", "a", "", "template arguments as a JSON object, or @FILE")
+	f.StringVar(&chatFlags.model, "model", "", "model to chat with, defaults to the configured model")
+	f.Float64Var(&chatFlags.temperature, "temperature", 0, "sampling temperature")
+	f.IntVar(&chatFlags.tokens, "max-tokens", 0, "maximum number of tokens to generate")
+	f.BoolVar(&chatFlags.json, "json", false, "print the full response as JSON")
+
+	promptCmd.AddCommand(promptListCmd, promptGetCmd, promptRenderCmd, promptChatCmd)
+	rootCmd.AddCommand(promptCmd)
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+/**
+ * Build information, set by the main package from linker flags
+ */
+type VersionInfo struct {
+	Software  string
+	BuildTime string
+	BuildTag  string
+	CommitId  string
+}
+
+var versions VersionInfo
+
+// Address of a running server; commands work on the storage directly if empty
+var serverAddr string
+
+// Scope of requests, as comma-separated kind:id pairs
+var scopeFlag string
+
+// Bearer token (API key or JWT) sent to the server
+var tokenFlag string
+
+var rootCmd = &cobra.Command{
+	Use:   "ai-prompt-shell",
+	Short: "Prompt template service for LLM applications",
+	Long: `ai-prompt-shell renders prompt templates with shared variables and tools, and sends them to LLMs.
+
+Without a subcommand it starts the HTTP server, like "serve". Other subcommands manage and
+render prompts, working on the storage (Redis or files) directly, or to a running server if --server is given.`,
+	SilenceUsage: true,
+	Args:         cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runServe()
+	},
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&serverAddr, "server", "", "address of a running ai-prompt-shell server, e.g. http://localhost:8080")
+	rootCmd.PersistentFlags().StringVar(&scopeFlag, "scope", "", "scope of shared variables, e.g. tenant:acme,project:web,user:alice")
+	rootCmd.PersistentFlags().StringVar(&tokenFlag, "token", os.Getenv("AI_PROMPT_SHELL_TOKEN"), "API key or JWT sent to the server with --server (env AI_PROMPT_SHELL_TOKEN)")
+}
+
+/**
+ * Run the command line
+ * @param v build information printed by the server and --version
+ * @return process exit code
+ */
+func Execute(v VersionInfo) int {
+	versions = v
+	rootCmd.Version = v.Software
+	if err := rootCmd.Execute(); err != nil {
+		return 1
+	}
+	return 0
+}
+
+/**
+ * Print software version information
+ */
+func printVersions() {
+	fmt.Printf("Version %s\n", versions.Software)
+	fmt.Printf("Build Time: %s\n", versions.BuildTime)
+	fmt.Printf("Build Tag: %s\n", versions.BuildTag)
+	fmt.Printf("Build Commit ID: %s\n", versions.CommitId)
+}
+
+/**
+ * Read a command argument that is either inline text or "@path" of a file holding it
+ */
+func readArg(value string) ([]byte, error) {
+	if len(value) > 1 && value[0] == '@' {
+		return os.ReadFile(value[1:])
+	}
+	return []byte(value), nil
+}
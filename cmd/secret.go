@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"bufio"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var secretCmd = &cobra.Command{
+	Use:   "secret",
+	Short: "List, set and delete secrets used by tools",
+	Long: `List, set and delete secrets used by tools.
+
+Secrets are encrypted in storage with secrets.key and can't be read back. Tools refer to them
+as ${secret:SECRET_ID} in their headers.`,
+}
+
+var secretListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the IDs of secrets",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := newClient()
+		if err != nil {
+			return err
+		}
+		var ids []string
+		if err := c.do(http.MethodGet, "/api/secrets", nil, &ids); err != nil {
+			return err
+		}
+		printList(ids)
+		return nil
+	},
+}
+
+var secretSetCmd = &cobra.Command{
+	Use:   "set SECRET_ID [VALUE]",
+	Short: "Set a secret",
+	Long: `Set a secret, creating it if it doesn't exist.
+
+Without VALUE, the value is read from the first line of standard input, so that it doesn't
+end up in the shell history. Use @FILE to read it from a file.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var value string
+		if len(args) == 2 {
+			data, err := readArg(args[1])
+			if err != nil {
+				return err
+			}
+			value = strings.TrimRight(string(data), "\r\n")
+		} else {
+			line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			if err != nil && line == "" {
+				return err
+			}
+			value = strings.TrimRight(line, "\r\n")
+		}
+		c, err := newClient()
+		if err != nil {
+			return err
+		}
+		return c.do(http.MethodPut, "/api/secrets/"+pathID(args[0]), value, nil)
+	},
+}
+
+var secretDeleteCmd = &cobra.Command{
+	Use:   "delete SECRET_ID",
+	Short: "Delete a secret",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := newClient()
+		if err != nil {
+			return err
+		}
+		return c.do(http.MethodDelete, "/api/secrets/"+pathID(args[0]), nil, nil)
+	},
+}
+
+func init() {
+	secretCmd.AddCommand(secretListCmd, secretSetCmd, secretDeleteCmd)
+	rootCmd.AddCommand(secretCmd)
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/zgsm-ai/ai-prompt-shell/api"
+	"github.com/zgsm-ai/ai-prompt-shell/dao"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/config"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/logger"
+	"github.com/zgsm-ai/ai-prompt-shell/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Start the HTTP server",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runServe()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+}
+
+/**
+ * Load configuration, connect storage, initialize services and serve the API
+ */
+func runServe() error {
+	printVersions()
+
+	cfg := config.Load()
+	logger.Init(&cfg.Logger)
+
+	if err := initStorage(cfg); err != nil {
+		logrus.Fatalf("%v", err)
+	}
+	if err := service.Init(cfg); err != nil {
+		logrus.Fatalf("Service initialization failed: %v", err)
+	}
+	if err := api.InitAuth(&cfg.Auth); err != nil {
+		logrus.Fatalf("Authentication initialization failed: %v", err)
+	}
+	runHttpServer(&cfg.Server)
+	return nil
+}
+
+/**
+ * Connect the storage backend selected by storage.type
+ */
+func initStorage(cfg *config.Config) error {
+	switch cfg.Storage.Type {
+	case "", "redis":
+		if err := dao.InitRedis(cfg.Redis.Addr, cfg.Redis.Password, cfg.Redis.DB); err != nil {
+			return fmt.Errorf("Redis initialization failed: %v", err)
+		}
+	case "file":
+		if err := dao.InitFileStore(cfg.Storage.Dir); err != nil {
+			return fmt.Errorf("File storage initialization failed: %v", err)
+		}
+	default:
+		return fmt.Errorf("unknown storage type: %s", cfg.Storage.Type)
+	}
+	return nil
+}
+
+/**
+ * Start HTTP server and register routes
+ */
+func runHttpServer(c *config.ServerConfig) {
+	if !c.Debug {
+		gin.SetMode(gin.ReleaseMode)
+	}
+	r := gin.Default()
+
+	api.SetupRoutes(r)
+
+	err := r.Run(c.ListenAddr)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/zgsm-ai/ai-prompt-shell/service"
+
+	"github.com/spf13/cobra"
+)
+
+var sessionCmd = &cobra.Command{
+	Use:   "session",
+	Short: "Start, continue, show and end multi-turn chats on top of prompts",
+}
+
+var sessionFlags struct {
+	args        string
+	model       string
+	temperature float64
+	tokens      int
+	json        bool
+}
+
+var sessionStartCmd = &cobra.Command{
+	Use:   "start PROMPT_ID",
+	Short: "Render a prompt and start a session with it, printing the session ID",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		req := service.CreateSessionRequest{PromptID: args[0]}
+		req.Model = sessionFlags.model
+		req.Temperature = sessionFlags.temperature
+		req.MaxTokens = sessionFlags.tokens
+		if err := decodeArg("--args", sessionFlags.args, &req.Args); err != nil {
+			return err
+		}
+		c, err := newClient()
+		if err != nil {
+			return err
+		}
+		var session service.ChatSession
+		if err := c.do(http.MethodPost, "/api/sessions", req, &session); err != nil {
+			return err
+		}
+		if sessionFlags.json {
+			return printJSON(session)
+		}
+		fmt.Println(session.ID)
+		return nil
+	},
+}
+
+var sessionSendCmd = &cobra.Command{
+	Use:   "send SESSION_ID [MESSAGE]",
+	Short: "Send a message in a session and print the answer",
+	Long: `Send a message in a session and print the answer.
+
+Without MESSAGE, the last user message of the history is answered, such as that of the prompt.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var req service.SessionMessageRequest
+		if len(args) > 1 {
+			req.Content = args[1]
+		}
+		c, err := newClient()
+		if err != nil {
+			return err
+		}
+		var rsp service.ChatResponse
+		if err := c.do(http.MethodPost, "/api/sessions/"+pathID(args[0])+"/messages", req, &rsp); err != nil {
+			return err
+		}
+		if sessionFlags.json {
+			return printJSON(rsp)
+		}
+		for _, choice := range rsp.Choices {
+			fmt.Println(choice.Message.Content)
+		}
+		return nil
+	},
+}
+
+var sessionShowCmd = &cobra.Command{
+	Use:   "show SESSION_ID",
+	Short: "Print the history of a session",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := newClient()
+		if err != nil {
+			return err
+		}
+		var session service.ChatSession
+		if err := c.do(http.MethodGet, "/api/sessions/"+pathID(args[0]), nil, &session); err != nil {
+			return err
+		}
+		if sessionFlags.json {
+			return printJSON(session)
+		}
+		printMessages(session.Messages[:session.Pinned])
+		if session.Summary != "" {
+			fmt.Printf("\n[summary of %d messages]\n%s\n", session.Dropped, session.Summary)
+		} else if session.Dropped > 0 {
+			fmt.Printf("\n[%d messages dropped]\n", session.Dropped)
+		}
+		if turns := session.Messages[session.Pinned:]; len(turns) > 0 {
+			fmt.Println()
+			printMessages(turns)
+		}
+		return nil
+	},
+}
+
+var sessionDeleteCmd = &cobra.Command{
+	Use:   "delete SESSION_ID",
+	Short: "End a session",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := newClient()
+		if err != nil {
+			return err
+		}
+		return c.do(http.MethodDelete, "/api/sessions/"+pathID(args[0]), nil, nil)
+	},
+}
+
+func init() {
+	f := sessionStartCmd.Flags()
+	f.StringVarP(&sessionFlags.args, "args", "a", "", "template arguments as a JSON object, or @FILE")
+	f.StringVar(&sessionFlags.model, "model", "", "model to chat with, defaults to the configured model")
+	f.Float64Var(&sessionFlags.temperature, "temperature", 0, "sampling temperature")
+	f.IntVar(&sessionFlags.tokens, "max-tokens", 0, "maximum number of tokens to generate per answer")
+	for _, cmd := range []*cobra.Command{sessionStartCmd, sessionSendCmd, sessionShowCmd} {
+		cmd.Flags().BoolVar(&sessionFlags.json, "json", false, "print the full response as JSON")
+	}
+
+	sessionCmd.AddCommand(sessionStartCmd, sessionSendCmd, sessionShowCmd, sessionDeleteCmd)
+	rootCmd.AddCommand(sessionCmd)
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/zgsm-ai/ai-prompt-shell/service"
+
+	"github.com/spf13/cobra"
+)
+
+var testFlags struct {
+	update  bool
+	verbose bool
+}
+
+var testCmd = &cobra.Command{
+	Use:   "test SUITE_FILE...",
+	Short: "Run prompt regression test suites",
+	Long: `Render the prompt of each suite once per test case and check the output against the case's
+expectations. Fails if any case fails.
+
+With --update the current output of every case is written back to its suite as the expected
+output. --update works on the storage directly and can't be used with --server.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if testFlags.update && serverAddr != "" {
+			return fmt.Errorf("--update can't be used with --server")
+		}
+		c, err := newClient()
+		if err != nil {
+			return err
+		}
+		ctx := context.Background()
+		failed := false
+		for _, path := range args {
+			suite, err := service.LoadTestSuite(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				failed = true
+				continue
+			}
+			if testFlags.update {
+				if err := updateSuite(ctx, path, suite); err != nil {
+					fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+					failed = true
+				}
+				continue
+			}
+			var report service.TestReport
+			if err := c.do(http.MethodPost, "/api/prompts/"+pathID(suite.Prompt)+"/test", suite, &report); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: prompt %s: %v\n", path, suite.Prompt, err)
+				failed = true
+				continue
+			}
+			printReport(path, report, testFlags.verbose)
+			if report.Failed > 0 {
+				failed = true
+			}
+		}
+		if failed {
+			return fmt.Errorf("tests failed")
+		}
+		return nil
+	},
+}
+
+/**
+ * Record the current output of every case as its expected output
+ */
+func updateSuite(ctx context.Context, path string, suite service.TestSuite) error {
+	for i, tc := range suite.Cases {
+		expect, err := service.GoldenExpect(ctx, suite.Prompt, tc)
+		if err != nil {
+			return fmt.Errorf("case %q: %v", tc.Name, err)
+		}
+		suite.Cases[i].Expect = expect
+	}
+	if err := service.SaveTestSuite(path, suite); err != nil {
+		return err
+	}
+	fmt.Printf("UPDATED %s (%d cases)\n", path, len(suite.Cases))
+	return nil
+}
+
+func printReport(path string, report service.TestReport, verbose bool) {
+	for _, c := range report.Cases {
+		if c.Passed {
+			if verbose {
+				fmt.Printf("PASS %s: %s (%d tokens)\n", report.Prompt, c.Name, c.Tokens)
+			}
+			continue
+		}
+		fmt.Printf("FAIL %s: %s\n", report.Prompt, c.Name)
+		for _, f := range c.Failures {
+			fmt.Printf("    %s\n", f)
+		}
+		if c.Diff != "" {
+			fmt.Printf("    %s\n", strings.ReplaceAll(strings.TrimRight(c.Diff, "\n"), "\n", "\n    "))
+		}
+	}
+	fmt.Printf("%s: %d passed, %d failed\n", path, report.Passed, report.Failed)
+}
+
+func init() {
+	testCmd.Flags().BoolVar(&testFlags.update, "update", false, "write the rendered output of every case back to its suite as the expected output")
+	testCmd.Flags().BoolVarP(&testFlags.verbose, "verbose", "v", false, "list passing cases too")
+
+	rootCmd.AddCommand(testCmd)
+}
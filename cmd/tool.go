@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"net/http"
+
+	"github.com/zgsm-ai/ai-prompt-shell/api"
+
+	"github.com/spf13/cobra"
+)
+
+var toolCmd = &cobra.Command{
+	Use:   "tool",
+	Short: "List and call tools",
+}
+
+var toolList listFlags
+
+var toolListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List tools",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return toolList.run("/api/tools")
+	},
+}
+
+var toolCallArgs string
+
+var toolCallCmd = &cobra.Command{
+	Use:   "call TOOL_ID",
+	Short: "Call a tool and print its result",
+	Long: `Call a tool as a template would and print its result.
+
+Arguments are a JSON array, given inline or as @FILE.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		req := api.CallToolRequest{Args: []interface{}{}}
+		if err := decodeArg("--args", toolCallArgs, &req.Args); err != nil {
+			return err
+		}
+		c, err := newClient()
+		if err != nil {
+			return err
+		}
+		var rsp api.CallToolResponse
+		if err := c.do(http.MethodPost, "/api/tools/"+pathID(args[0])+"/call", req, &rsp); err != nil {
+			return err
+		}
+		return printValue(rsp.Result)
+	},
+}
+
+func init() {
+	toolList.bind(toolListCmd, "supports")
+	toolCallCmd.Flags().StringVarP(&toolCallArgs, "args", "a", "", "tool arguments as a JSON array, or @FILE")
+
+	toolCmd.AddCommand(toolListCmd, toolCallCmd)
+	rootCmd.AddCommand(toolCmd)
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/zgsm-ai/ai-prompt-shell/service"
+
+	"github.com/spf13/cobra"
+)
+
+var validateFlags struct {
+	sampleArgs string
+	json       bool
+}
+
+var validateCmd = &cobra.Command{
+	Use:   "validate PROMPT_FILE...",
+	Short: "Check prompt template files before publishing them",
+	Long: `Check prompt templates, each a JSON file holding one prompt, before publishing them.
+
+Reports syntax errors, unknown functions, unknown message roles, invalid JSON Schemas and
+undeclared arguments. With --sample-args every prompt is also rendered with tool calls mocked.
+Fails if any prompt has issues.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var sampleArgs map[string]interface{}
+		if err := decodeArg("--sample-args", validateFlags.sampleArgs, &sampleArgs); err != nil {
+			return err
+		}
+		c, err := newClient()
+		if err != nil {
+			return err
+		}
+		invalid := 0
+		for _, path := range args {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			req := service.ValidatePromptRequest{SampleArgs: sampleArgs}
+			if err := json.Unmarshal(data, &req.Prompt); err != nil {
+				return fmt.Errorf("%s: %v", path, err)
+			}
+			var result service.ValidatePromptResult
+			if err := c.do(http.MethodPost, "/api/prompts/validate", req, &result); err != nil {
+				return fmt.Errorf("%s: %v", path, err)
+			}
+			if !result.Valid {
+				invalid++
+			}
+			if validateFlags.json {
+				if err := printJSON(result); err != nil {
+					return err
+				}
+				continue
+			}
+			printIssues(path, result)
+		}
+		if invalid > 0 {
+			return fmt.Errorf("%d of %d prompts are invalid", invalid, len(args))
+		}
+		return nil
+	},
+}
+
+/**
+ * Print validation issues in file:field:line:column form
+ */
+func printIssues(path string, result service.ValidatePromptResult) {
+	if result.Valid {
+		fmt.Printf("%s: OK\n", path)
+		return
+	}
+	for _, issue := range result.Issues {
+		loc := path
+		if issue.Field != "" {
+			loc += ":" + issue.Field
+		}
+		if issue.Line > 0 {
+			loc += fmt.Sprintf(":%d:%d", issue.Line, issue.Column)
+		}
+		fmt.Printf("%s: %s: %s\n", loc, issue.Kind, issue.Message)
+	}
+}
+
+func init() {
+	validateCmd.Flags().StringVar(&validateFlags.sampleArgs, "sample-args", "", "arguments to render each prompt with, as a JSON object or @FILE")
+	validateCmd.Flags().BoolVar(&validateFlags.json, "json", false, "print the full result of each prompt as JSON")
+
+	rootCmd.AddCommand(validateCmd)
+}
@@ -0,0 +1,192 @@
+package dao
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Redis stream holding audit records
+const AUDIT_STREAM = "shenma:audit"
+
+/**
+ * Append-only log of audit records
+ * @description
+ * - Records are opaque JSON lines to the log; filtering them is up to the caller
+ * - Old records are dropped by the log as it grows, by stream length or by rotated files
+ */
+type AuditLog interface {
+	Append(record []byte) error
+	/**
+	 * Read records newest first
+	 * @param fn called with each record, stops the scan by returning false
+	 */
+	Scan(fn func(record []byte) bool) error
+}
+
+/**
+ * Audit log on a Redis stream, shared by all instances
+ */
+type redisAuditLog struct {
+	client *redis.Client
+	maxLen int64
+}
+
+/**
+ * Create an audit log on the Redis stream AUDIT_STREAM
+ * @param maxLen approximate number of records kept, 0 to keep all
+ * @throws error if Redis isn't the storage
+ */
+func NewRedisAuditLog(maxLen int64) (AuditLog, error) {
+	if Client == nil {
+		return nil, errors.New("redis is not connected")
+	}
+	return &redisAuditLog{client: Client, maxLen: maxLen}, nil
+}
+
+func (l *redisAuditLog) Append(record []byte) error {
+	return l.client.XAdd(Ctx, &redis.XAddArgs{
+		Stream: AUDIT_STREAM,
+		MaxLen: l.maxLen,
+		Approx: l.maxLen > 0,
+		Values: map[string]interface{}{"record": record},
+	}).Err()
+}
+
+func (l *redisAuditLog) Scan(fn func(record []byte) bool) error {
+	const batch = 500
+	end := "+"
+	for {
+		msgs, err := l.client.XRevRangeN(Ctx, AUDIT_STREAM, end, "-", batch).Result()
+		if err != nil {
+			return err
+		}
+		for _, m := range msgs {
+			if s, ok := m.Values["record"].(string); ok && !fn([]byte(s)) {
+				return nil
+			}
+		}
+		if len(msgs) < batch {
+			return nil
+		}
+		if end = previousStreamID(msgs[len(msgs)-1].ID); end == "" {
+			return nil
+		}
+	}
+}
+
+/**
+ * Get the greatest stream ID before an ID, since exclusive ranges need Redis 6.2
+ * @return ID, empty if there's none
+ */
+func previousStreamID(id string) string {
+	ms, seq, ok := strings.Cut(id, "-")
+	if !ok {
+		return ""
+	}
+	m, err1 := strconv.ParseUint(ms, 10, 64)
+	s, err2 := strconv.ParseUint(seq, 10, 64)
+	if err1 != nil || err2 != nil {
+		return ""
+	}
+	if s > 0 {
+		return fmt.Sprintf("%d-%d", m, s-1)
+	}
+	if m == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d-%d", m-1, uint64(1<<64-1))
+}
+
+/**
+ * Audit log in a local JSONL file, rotated by size
+ * @description
+ * - Rotated files are named after the file with .1, .2... suffixes, .1 being the most recent
+ * - The file belongs to one instance; instances sharing a directory need a file each
+ */
+type fileAuditLog struct {
+	path     string
+	maxBytes int64
+	maxFiles int
+
+	mu sync.Mutex
+}
+
+/**
+ * Create an audit log in a local JSONL file
+ * @param maxBytes size at which the file is rotated, 0 to never rotate
+ * @param maxFiles number of rotated files kept
+ */
+func NewFileAuditLog(path string, maxBytes int64, maxFiles int) (AuditLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	return &fileAuditLog{path: path, maxBytes: maxBytes, maxFiles: maxFiles}, nil
+}
+
+func (l *fileAuditLog) Append(record []byte) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.maxBytes > 0 {
+		if info, err := os.Stat(l.path); err == nil && info.Size()+int64(len(record))+1 > l.maxBytes {
+			if err := l.rotate(); err != nil {
+				return err
+			}
+		}
+	}
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(record, '\n'))
+	return err
+}
+
+func (l *fileAuditLog) rotate() error {
+	if l.maxFiles <= 0 {
+		return os.Remove(l.path)
+	}
+	os.Remove(fmt.Sprintf("%s.%d", l.path, l.maxFiles))
+	for i := l.maxFiles - 1; i >= 1; i-- {
+		from := fmt.Sprintf("%s.%d", l.path, i)
+		if _, err := os.Stat(from); err == nil {
+			if err := os.Rename(from, fmt.Sprintf("%s.%d", l.path, i+1)); err != nil {
+				return err
+			}
+		}
+	}
+	return os.Rename(l.path, l.path+".1")
+}
+
+func (l *fileAuditLog) Scan(fn func(record []byte) bool) error {
+	files := []string{l.path}
+	for i := 1; i <= l.maxFiles; i++ {
+		files = append(files, fmt.Sprintf("%s.%d", l.path, i))
+	}
+	for _, file := range files {
+		l.mu.Lock()
+		data, err := os.ReadFile(file)
+		l.mu.Unlock()
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		lines := bytes.Split(bytes.TrimRight(data, "\n"), []byte("\n"))
+		for i := len(lines) - 1; i >= 0; i-- {
+			if len(lines[i]) > 0 && !fn(lines[i]) {
+				return nil
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package dao
+
+import (
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+/**
+ * Get a cached chat response
+ * @return response as stored, nil if it isn't cached or Redis isn't the storage
+ * @description
+ * - Chat responses are kept in Redis only, shared by all instances; with file storage
+ *   only the in-process cache of the service is used
+ */
+func GetCachedChat(key string) ([]byte, error) {
+	if Client == nil {
+		return nil, nil
+	}
+	data, err := Client.Get(Ctx, PREFIX_CHATCACHE+key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	return data, err
+}
+
+/**
+ * Cache a chat response, if Redis is the storage
+ * @param ttl time after which the response expires
+ */
+func SetCachedChat(key string, data []byte, ttl time.Duration) error {
+	if Client == nil {
+		return nil
+	}
+	return Client.Set(Ctx, PREFIX_CHATCACHE+key, data, ttl).Err()
+}
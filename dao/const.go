@@ -5,4 +5,11 @@ const (
 	PREFIX_TOOLS      = "shenma:tools:"
 	PREFIX_EXTENSIONS = "shenma:extensions:"
 	PREFIX_TEMPLATES  = "shenma:templates:"
+	PREFIX_PARTIALS   = "shenma:partials:"
+	PREFIX_SYNC       = "shenma:sync:"
+	PREFIX_SCOPES     = "shenma:scopes:"
+	PREFIX_SECRETS    = "shenma:secrets:"
+	PREFIX_QUOTAS     = "shenma:quotas:"
+	PREFIX_CHATCACHE  = "shenma:chatcache:"
+	PREFIX_SESSIONS   = "shenma:sessions:"
 )
@@ -0,0 +1,134 @@
+package dao
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+/**
+ * Expiring integer counters, such as the windows of quotas
+ * @description
+ * - Counters are kept in Redis when it's the storage, so all instances share them;
+ *   with file storage they're kept in process memory
+ * - Counters are not items: they're neither loaded, exported nor watched
+ */
+type Counters interface {
+	/**
+	 * Add to a counter, creating it if needed
+	 * @param ttl time after which the counter expires, counted from its creation
+	 */
+	IncrBy(key string, amount int64, ttl time.Duration) error
+	/**
+	 * Get counters, 0 for those that don't exist
+	 */
+	Get(keys ...string) ([]int64, error)
+}
+
+// Counters used by the dao functions, replaced by InitRedis
+var counters Counters = newMemCounters()
+
+/**
+ * Add to a counter, as Counters.IncrBy
+ */
+func IncrCounter(key string, amount int64, ttl time.Duration) error {
+	return counters.IncrBy(key, amount, ttl)
+}
+
+/**
+ * Get counters, as Counters.Get
+ */
+func GetCounters(keys ...string) ([]int64, error) {
+	return counters.Get(keys...)
+}
+
+/**
+ * Counters on the global Redis client
+ */
+type redisCounters struct {
+	client *redis.Client
+}
+
+func (c *redisCounters) IncrBy(key string, amount int64, ttl time.Duration) error {
+	v, err := c.client.IncrBy(Ctx, key, amount).Result()
+	if err != nil {
+		return err
+	}
+	if v == amount {
+		// Created by this call
+		return c.client.Expire(Ctx, key, ttl).Err()
+	}
+	return nil
+}
+
+func (c *redisCounters) Get(keys ...string) ([]int64, error) {
+	values := make([]int64, len(keys))
+	if len(keys) == 0 {
+		return values, nil
+	}
+	results, err := c.client.MGet(Ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i, r := range results {
+		if s, ok := r.(string); ok {
+			values[i], _ = strconv.ParseInt(s, 10, 64)
+		}
+	}
+	return values, nil
+}
+
+/**
+ * Counters in process memory
+ */
+type memCounters struct {
+	mu     sync.Mutex
+	values map[string]memCounter
+	swept  time.Time
+}
+
+type memCounter struct {
+	value   int64
+	expires time.Time
+}
+
+func newMemCounters() *memCounters {
+	return &memCounters{values: make(map[string]memCounter), swept: time.Now()}
+}
+
+func (c *memCounters) IncrBy(key string, amount int64, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	v, ok := c.values[key]
+	if !ok || now.After(v.expires) {
+		v = memCounter{expires: now.Add(ttl)}
+	}
+	v.value += amount
+	c.values[key] = v
+	// Drop expired counters once a minute, so the map doesn't grow forever
+	if now.Sub(c.swept) > time.Minute {
+		for k, v := range c.values {
+			if now.After(v.expires) {
+				delete(c.values, k)
+			}
+		}
+		c.swept = now
+	}
+	return nil
+}
+
+func (c *memCounters) Get(keys ...string) ([]int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	values := make([]int64, len(keys))
+	for i, k := range keys {
+		if v, ok := c.values[k]; ok && !now.After(v.expires) {
+			values[i] = v.value
+		}
+	}
+	return values, nil
+}
@@ -2,13 +2,55 @@ package dao
 
 import (
 	"context"
+	"sort"
 	"strings"
 
 	"github.com/sirupsen/logrus"
 )
 
+// Scope kinds, from the least to the most specific
+const (
+	SCOPE_TENANT  = "tenant"
+	SCOPE_PROJECT = "project"
+	SCOPE_USER    = "user"
+)
+
+// Source of shared variables that no scope overrides
+const SOURCE_GLOBAL = "global"
+
+/**
+ * Scope of a request, selecting the overrides of shared variables that apply to it
+ * @description
+ * - Each part is optional; overrides of more specific scopes win: user > project > tenant > global
+ */
+type EnvScope struct {
+	Tenant  string `json:"tenant,omitempty"`
+	Project string `json:"project,omitempty"`
+	User    string `json:"user,omitempty"`
+}
+
+/**
+ * Get the IDs of the scope's layers, from the least to the most specific
+ * @return layer IDs such as "tenant.acme", the key of their overrides under PREFIX_SCOPES
+ */
+func (s EnvScope) Layers() []string {
+	var layers []string
+	if s.Tenant != "" {
+		layers = append(layers, SCOPE_TENANT+"."+s.Tenant)
+	}
+	if s.Project != "" {
+		layers = append(layers, SCOPE_PROJECT+"."+s.Project)
+	}
+	if s.User != "" {
+		layers = append(layers, SCOPE_USER+"."+s.User)
+	}
+	return layers
+}
+
 type Environments struct {
 	environments map[string]interface{}
+	values       map[string]interface{}            // Dot path -> value of global variables as stored
+	scopes       map[string]map[string]interface{} // Layer ID -> dot path -> overriding value
 }
 
 /**
@@ -18,6 +60,8 @@ type Environments struct {
 func NewEnvironments() *Environments {
 	c := &Environments{
 		environments: make(map[string]interface{}),
+		values:       make(map[string]interface{}),
+		scopes:       make(map[string]map[string]interface{}),
 	}
 	return c
 }
@@ -66,20 +110,20 @@ func (c *Environments) Keys() ([]string, error) {
 }
 
 /**
- * Load environments from Redis
+ * Load environments from storage
  * @param c Environments instance
- * @param ctx Context for Redis operations
+ * @param ctx Context for storage operations
  * @return Error if loading fails
  */
-func (c *Environments) LoadFromRedis(ctx context.Context) error {
-	logrus.Info("Loading environments from Redis")
+func (c *Environments) Load(ctx context.Context) error {
+	logrus.Info("Loading environments from storage")
 
 	keys, err := KeysByPrefix(PREFIX_ENVIRONS)
 	if err != nil {
 		return err
 	}
 
-	newEnvs := make(map[string]interface{})
+	newValues := make(map[string]interface{})
 	for _, key := range keys {
 		var val interface{}
 		if err := GetJSON(key, &val); err != nil {
@@ -91,14 +135,152 @@ func (c *Environments) LoadFromRedis(ctx context.Context) error {
 			logrus.Warnf("Environ ID cannot be empty")
 			continue
 		}
-		c.setValueByPath(newEnvs, jsonPath, val)
-		newEnvs[jsonPath] = val
+		newValues[jsonPath] = val
+	}
+
+	keys, err = KeysByPrefix(PREFIX_SCOPES)
+	if err != nil {
+		return err
+	}
+	newScopes := make(map[string]map[string]interface{})
+	for _, key := range keys {
+		var vars map[string]interface{}
+		if err := GetJSON(key, &vars); err != nil {
+			return err
+		}
+		if len(vars) > 0 {
+			newScopes[KeyToID(key, PREFIX_SCOPES)] = vars
+		}
 	}
 
-	c.environments = newEnvs
+	c.environments = c.build(newValues)
+	c.values = newValues
+	c.scopes = newScopes
 	return nil
 }
 
+/**
+ * Build the template view of variables: each variable under its dot path, and nested by its path parts
+ * @param values dot path -> value
+ * @description
+ * - Paths are applied in order, so a variable overrides part of an object set by its parent path
+ */
+func (c *Environments) build(values map[string]interface{}) map[string]interface{} {
+	paths := make([]string, 0, len(values))
+	for path := range values {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	result := make(map[string]interface{})
+	for _, path := range paths {
+		c.setValueByPath(result, path, cloneValue(values[path]))
+		if strings.Contains(path, ".") {
+			result[path] = values[path]
+		}
+	}
+	return result
+}
+
+/**
+ * Copy the maps of a JSON value, so that building nested variables doesn't modify stored values
+ */
+func cloneValue(v interface{}) interface{} {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return v
+	}
+	result := make(map[string]interface{}, len(m))
+	for k, child := range m {
+		result[k] = cloneValue(child)
+	}
+	return result
+}
+
+/**
+ * Get the variables seen by a request of the given scope
+ * @param scope scope of the request
+ * @return global variables overridden by those of the scope's layers, in the form of All()
+ * @description
+ * - An override of a path replaces the variable and everything below it, e.g. overriding "repo" also hides "repo.url"
+ */
+func (c *Environments) Resolve(scope EnvScope) map[string]interface{} {
+	var layers []map[string]interface{}
+	for _, id := range scope.Layers() {
+		if vars, ok := c.scopes[id]; ok {
+			layers = append(layers, vars)
+		}
+	}
+	if len(layers) == 0 {
+		return c.environments
+	}
+	values := make(map[string]interface{}, len(c.values))
+	for path, v := range c.values {
+		values[path] = v
+	}
+	for _, vars := range layers {
+		for path, v := range vars {
+			for p := range values {
+				if strings.HasPrefix(p, path+".") {
+					delete(values, p)
+				}
+			}
+			values[path] = v
+		}
+	}
+	return c.build(values)
+}
+
+/**
+ * Get the value of a variable seen by a request of the given scope, and where it comes from
+ * @param path dot path of the variable
+ * @param scope scope of the request
+ * @return value, and the most specific layer setting the variable, a part of it or an object holding it, or SOURCE_GLOBAL
+ */
+func (c *Environments) Lookup(path string, scope EnvScope) (interface{}, string, bool) {
+	val, ok := lookupPath(c.Resolve(scope), path)
+	if !ok {
+		return nil, "", false
+	}
+	layers := scope.Layers()
+	for i := len(layers) - 1; i >= 0; i-- {
+		for p := range c.scopes[layers[i]] {
+			if p == path || strings.HasPrefix(path, p+".") || strings.HasPrefix(p, path+".") {
+				return val, layers[i], true
+			}
+		}
+	}
+	return val, SOURCE_GLOBAL, true
+}
+
+/**
+ * Get the overrides of a scope layer
+ * @param layer layer ID, e.g. "tenant.acme"
+ * @return dot path -> value, nil if the layer has no override
+ */
+func (c *Environments) Overrides(layer string) map[string]interface{} {
+	return c.scopes[layer]
+}
+
+/**
+ * Find a variable by its dot path, looking into objects if it isn't stored under the full path
+ */
+func lookupPath(data map[string]interface{}, path string) (interface{}, bool) {
+	if val, ok := data[path]; ok {
+		return val, true
+	}
+	var current interface{} = data
+	for _, part := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
 /**
  * Set nested value in map by dot-path
  * @param c Environments instance
@@ -12,6 +12,15 @@ type PromptExtension struct {
 	License       string      `json:"license" description:"扩展的许可协议"`
 	Engines       Engines     `json:"engines" description:"引擎配置"`
 	Contributes   Contributes `json:"contributes" description:"扩展功能"`
+	Enabled       *bool       `json:"enabled,omitempty" description:"是否启用,缺省为启用"`
+}
+
+/**
+ * Check whether the extension is enabled
+ * @return true unless the extension has been explicitly disabled
+ */
+func (e *PromptExtension) IsEnabled() bool {
+	return e.Enabled == nil || *e.Enabled
 }
 
 // Engines defines the engine requirements
@@ -36,6 +45,44 @@ type Prompt struct {
 	Supports    []string               `json:"supports" description:"支持的场景"`
 	Parameters  map[string]interface{} `json:"parameters" description:"参数定义(JSON Schema)"`
 	Returns     map[string]interface{} `json:"returns" description:"返回值定义(JSON Schema)"`
+	Budget      *Budget                `json:"budget,omitempty" description:"token预算"`
+	Strict      bool                   `json:"strict,omitempty" description:"严格模式,引用未定义的参数时渲染失败"`
+	ACL         *ACL                   `json:"acl,omitempty" description:"访问控制,未设置时所有人可访问"`
+	Guard       *GuardPolicy           `json:"guard,omitempty" description:"提示注入防护策略,未设置时使用全局默认策略"`
+	Redact      *RedactPolicy          `json:"redact,omitempty" description:"敏感数据脱敏策略,未设置时使用全局默认策略"`
+	Cache       *CachePolicy           `json:"cache,omitempty" description:"对话响应缓存策略,未设置时不缓存"`
+}
+
+// CachePolicy opts a prompt in to caching the LLM responses of its chats
+type CachePolicy struct {
+	TTL        int     `json:"ttl,omitempty" description:"缓存时间(秒),为0时使用全局默认值"`
+	Force      bool    `json:"force,omitempty" description:"temperature大于0时也缓存"`
+	Similarity float64 `json:"similarity,omitempty" description:"语义缓存的相似度阈值(0-1],为0时只精确匹配"`
+}
+
+// RedactPolicy decides how sensitive data in the messages sent to the LLM is redacted
+type RedactPolicy struct {
+	Mode      string   `json:"mode" description:"脱敏方式(placeholder/mask/off)"`
+	Detectors []string `json:"detectors,omitempty" description:"使用的检测器,为空时使用全部"`
+}
+
+// GuardPolicy decides what happens to chats whose untrusted content is flagged
+type GuardPolicy struct {
+	Action    string   `json:"action" description:"处理方式(block/warn/sanitize/off)"`
+	Detectors []string `json:"detectors,omitempty" description:"使用的检测器,为空时使用全部"`
+}
+
+// ACL restricts a prompt to the listed users, groups and roles; matching any entry grants access
+type ACL struct {
+	Users  []string `json:"users,omitempty" description:"允许访问的用户"`
+	Groups []string `json:"groups,omitempty" description:"允许访问的用户组"`
+	Roles  []string `json:"roles,omitempty" description:"允许访问的角色"`
+}
+
+// Budget declares how a prompt is fitted into the model context window
+type Budget struct {
+	MaxInputTokens int               `json:"maxInputTokens,omitempty" description:"渲染结果的最大token数,0表示仅受模型上下文限制"`
+	Truncatable    map[string]string `json:"truncatable,omitempty" description:"可截断的参数及保留策略(head/tail/middle)"`
 }
 
 // Message defines a role-message pair
@@ -55,12 +102,18 @@ type Dependence struct {
 const (
 	ExtensionTypePrompt = "prompt"
 
-	MessageRoleSystem = "system"
-	MessageRoleUser   = "user"
+	MessageRoleSystem    = "system"
+	MessageRoleUser      = "user"
+	MessageRoleAssistant = "assistant"
+	MessageRoleTool      = "tool"
 
 	SupportChat       = "chat"
 	SupportCodeReview = "codereview"
 
 	FailStrategyAbort  = "abort"
 	FailStrategyIgnore = "ignore"
+
+	TruncateKeepHead   = "head"
+	TruncateKeepTail   = "tail"
+	TruncateKeepMiddle = "middle"
 )
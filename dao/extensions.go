@@ -2,12 +2,18 @@ package dao
 
 import (
 	"context"
+	"sync"
 
 	"github.com/sirupsen/logrus"
 )
 
+/**
+ * Cache of extensions, safe for concurrent use
+ */
 type ExtensionCache struct {
+	mu         sync.RWMutex
 	extensions map[string]PromptExtension
+	keys       map[string]string
 }
 
 /**
@@ -17,6 +23,7 @@ type ExtensionCache struct {
 func NewExtensionCache() *ExtensionCache {
 	c := &ExtensionCache{
 		extensions: make(map[string]PromptExtension),
+		keys:       make(map[string]string),
 	}
 	return c
 }
@@ -28,6 +35,8 @@ func NewExtensionCache() *ExtensionCache {
  * @param value Extension details
  */
 func (c *ExtensionCache) Set(extension_id string, value PromptExtension) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.extensions[extension_id] = value
 }
 
@@ -38,6 +47,8 @@ func (c *ExtensionCache) Set(extension_id string, value PromptExtension) {
  * @return Extension details and exists flag
  */
 func (c *ExtensionCache) Get(extension_id string) (PromptExtension, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	val, ok := c.extensions[extension_id]
 	return val, ok
 }
@@ -45,20 +56,77 @@ func (c *ExtensionCache) Get(extension_id string) (PromptExtension, bool) {
 /**
  * Get all extensions from cache
  * @param c ExtensionCache instance
- * @return Map of all extensions
+ * @return copy of the map of all extensions
  */
 func (c *ExtensionCache) All() map[string]PromptExtension {
-	return c.extensions
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	all := make(map[string]PromptExtension, len(c.extensions))
+	for k, v := range c.extensions {
+		all[k] = v
+	}
+	return all
+}
+
+/**
+ * Get the storage key an extension was loaded from
+ * @param c ExtensionCache instance
+ * @param extension_id Extension ID
+ * @return storage key, derived from the ID if the extension was never loaded
+ */
+func (c *ExtensionCache) Key(extension_id string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if key, ok := c.keys[extension_id]; ok {
+		return key
+	}
+	return IDToKey(extension_id, PREFIX_EXTENSIONS)
+}
+
+/**
+ * Save extension to storage and cache
+ * @param c ExtensionCache instance
+ * @param extension_id Extension ID
+ * @param value Extension details
+ * @return Error if writing to storage fails
+ */
+func (c *ExtensionCache) Save(extension_id string, value PromptExtension) error {
+	key := c.Key(extension_id)
+	if err := SetJSON(key, value, 0); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.extensions[extension_id] = value
+	c.keys[extension_id] = key
+	return nil
+}
+
+/**
+ * Remove extension from storage and cache
+ * @param c ExtensionCache instance
+ * @param extension_id Extension ID
+ * @return Error if deleting from storage fails
+ */
+func (c *ExtensionCache) Remove(extension_id string) error {
+	if err := Del(c.Key(extension_id)); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.extensions, extension_id)
+	delete(c.keys, extension_id)
+	return nil
 }
 
 /**
- * Load extensions from Redis into cache
+ * Load extensions from storage into cache
  * @param c ExtensionCache instance
- * @param ctx Context for Redis operations
+ * @param ctx Context for storage operations
  * @return Error if loading fails
  */
-func (c *ExtensionCache) LoadFromRedis(ctx context.Context) error {
-	logrus.Info("Loading extensions from Redis")
+func (c *ExtensionCache) Load(ctx context.Context) error {
+	logrus.Info("Loading extensions from storage")
 
 	keys, err := KeysByPrefix(PREFIX_EXTENSIONS)
 	if err != nil {
@@ -66,6 +134,7 @@ func (c *ExtensionCache) LoadFromRedis(ctx context.Context) error {
 	}
 
 	newExts := make(map[string]PromptExtension)
+	newKeys := make(map[string]string)
 	for _, key := range keys {
 		var val PromptExtension
 		if err := GetJSON(key, &val); err != nil {
@@ -78,8 +147,12 @@ func (c *ExtensionCache) LoadFromRedis(ctx context.Context) error {
 			continue
 		}
 		newExts[extension_id] = val
+		newKeys[extension_id] = key
 	}
 
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.extensions = newExts
+	c.keys = newKeys
 	return nil
 }
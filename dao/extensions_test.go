@@ -0,0 +1,58 @@
+package dao
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestExtensionCacheConcurrentSave(t *testing.T) {
+	if err := InitFileStore(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	c := NewExtensionCache()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				id := fmt.Sprintf("pub.ext%d-%d", i, j)
+				if err := c.Save(id, PromptExtension{Name: id}); err != nil {
+					t.Error(err)
+					return
+				}
+				if j%10 == 0 {
+					if err := c.Remove(id); err != nil {
+						t.Error(err)
+						return
+					}
+				}
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				for id, ext := range c.All() {
+					if ext.Name != id {
+						t.Errorf("extension %s is named %s", id, ext.Name)
+					}
+					c.Get(id)
+				}
+				if j%25 == 0 {
+					if err := c.Load(context.Background()); err != nil {
+						t.Error(err)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if err := c.Load(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(c.All()); n != 4*45 {
+		t.Errorf("cache holds %d extensions, want %d", n, 4*45)
+	}
+}
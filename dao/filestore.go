@@ -0,0 +1,369 @@
+package dao
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+)
+
+/**
+ * Directory of each item kind in file storage
+ * @description
+ * - Items are JSON files named by their ID, e.g. tools/codebase.lookup_reference.json;
+ *   subdirectories also separate ID parts, so tools/codebase/lookup_reference.json has the same ID
+ * - Extensions are directories holding a package.json, e.g. extensions/translator/package.json
+ * - Prompts, tools, partials and extensions are also read from the singular directories of examples/
+ *   and git sync repositories (prompt/, tool/, partial/, extension/); new items go to the plural ones
+ */
+var fileLayouts = []struct {
+	prefix    string
+	dir       string
+	isPackage bool
+}{
+	{PREFIX_TEMPLATES, "prompts", false},
+	{PREFIX_TOOLS, "tools", false},
+	{PREFIX_ENVIRONS, "environs", false},
+	{PREFIX_PARTIALS, "partials", false},
+	{PREFIX_EXTENSIONS, "extensions", true},
+	{PREFIX_SYNC, "sync", false},
+	{PREFIX_SCOPES, "scopes", false},
+	{PREFIX_SECRETS, "secrets", false},
+	{PREFIX_TEMPLATES, "prompt", false},
+	{PREFIX_TOOLS, "tool", false},
+	{PREFIX_PARTIALS, "partial", false},
+	{PREFIX_EXTENSIONS, "extension", true},
+}
+
+// Delay before reporting changes, so that a burst of file events triggers one reload
+const watchDebounce = 300 * time.Millisecond
+
+/**
+ * Store on a directory tree, laid out as fileLayouts
+ * @description
+ * - Expirations are ignored, items stay until deleted
+ * - Items are written to the file they were read from, or to <dir>/<ID>.json if new
+ */
+type fileStore struct {
+	dir   string
+	mu    sync.Mutex
+	paths map[string]string // Key -> file of items found by the last scan
+}
+
+/**
+ * Use a directory tree as storage instead of Redis
+ * @param dir root directory, created if it doesn't exist
+ * @return Error if the directory can't be created
+ */
+func InitFileStore(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create storage directory: %v", err)
+	}
+	store = &fileStore{
+		dir:   dir,
+		paths: make(map[string]string),
+	}
+	return nil
+}
+
+/**
+ * Map a file of the storage layout to the key of the item it holds
+ * @param rel path of the file relative to the storage directory, with forward slashes
+ * @return key and prefix of the item, empty if the file isn't an item
+ */
+func LayoutKey(rel string) (string, string) {
+	dir, rest, ok := strings.Cut(rel, "/")
+	if !ok {
+		return "", ""
+	}
+	for _, l := range fileLayouts {
+		if l.dir != dir {
+			continue
+		}
+		var id string
+		if l.isPackage {
+			id, ok = strings.CutSuffix(rest, "/package.json")
+		} else {
+			id, ok = strings.CutSuffix(rest, ".json")
+		}
+		if !ok || id == "" || strings.HasPrefix(filepath.Base(id), ".") {
+			return "", ""
+		}
+		return IDToKey(strings.ReplaceAll(id, "/", "."), l.prefix), l.prefix
+	}
+	return "", ""
+}
+
+/**
+ * Map the key of an item to its file in the storage layout
+ * @param key key of the item
+ * @return path relative to the storage directory, with forward slashes
+ * @return false if the key belongs to no item kind
+ */
+func LayoutPath(key string) (string, bool) {
+	for _, l := range fileLayouts {
+		if !strings.HasPrefix(key, l.prefix) {
+			continue
+		}
+		id := KeyToID(key, l.prefix)
+		if id == "" || strings.ContainsAny(id, `/\`) || strings.HasPrefix(id, ".") {
+			break
+		}
+		if l.isPackage {
+			return l.dir + "/" + id + "/package.json", true
+		}
+		return l.dir + "/" + id + ".json", true
+	}
+	return "", false
+}
+
+/**
+ * Find the file of an item
+ * @param key key of the item
+ * @return file path, error if the key belongs to no item kind
+ */
+func (s *fileStore) pathOf(key string) (string, error) {
+	s.mu.Lock()
+	path, ok := s.paths[key]
+	s.mu.Unlock()
+	if ok {
+		return path, nil
+	}
+	if rel, ok := LayoutPath(key); ok {
+		return filepath.Join(s.dir, filepath.FromSlash(rel)), nil
+	}
+	return "", fmt.Errorf("key %s can't be stored in file storage", key)
+}
+
+func (s *fileStore) Get(key string) ([]byte, error) {
+	path, err := s.pathOf(key)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (s *fileStore) Set(key string, data []byte, expiration time.Duration) error {
+	path, err := s.pathOf(key)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, data, "", "    "); err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	s.mu.Lock()
+	s.paths[key] = path
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *fileStore) Del(key string) error {
+	path, err := s.pathOf(key)
+	if err != nil {
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if filepath.Base(path) == "package.json" {
+		// Leave the extension directory if it holds other files
+		os.Remove(filepath.Dir(path))
+	}
+	s.mu.Lock()
+	delete(s.paths, key)
+	s.mu.Unlock()
+	return nil
+}
+
+/**
+ * Write and delete keys one by one
+ * @description
+ * - Files can't be changed atomically as a set; every file is replaced atomically, and a watcher
+ *   reloads once after the whole burst of changes
+ */
+func (s *fileStore) Apply(sets map[string][]byte, dels []string) error {
+	for key, data := range sets {
+		if err := s.Set(key, data, 0); err != nil {
+			return err
+		}
+	}
+	for _, key := range dels {
+		if err := s.Del(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *fileStore) Exists(key string) (bool, error) {
+	path, err := s.pathOf(key)
+	if err != nil {
+		return false, nil
+	}
+	_, err = os.Stat(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *fileStore) Keys(prefix string) ([]string, error) {
+	var keys []string
+	found := make(map[string]string)
+	err := filepath.WalkDir(s.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(s.dir, path)
+		if err != nil {
+			return err
+		}
+		key, _ := LayoutKey(filepath.ToSlash(rel))
+		if key == "" {
+			return nil
+		}
+		if other, ok := found[key]; ok {
+			logrus.Warnf("Files %s and %s hold the same item, using the first one", other, path)
+			return nil
+		}
+		found[key] = path
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.paths = found
+	s.mu.Unlock()
+	return keys, nil
+}
+
+/**
+ * Watch the directory tree with fsnotify
+ * @description
+ * - Directories created later are watched too
+ * - Changes are reported per item kind, at most once per watchDebounce
+ */
+func (s *fileStore) Watch(onChange func(prefix string)) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err := s.watchTree(w, s.dir); err != nil {
+		w.Close()
+		return err
+	}
+	go func() {
+		defer w.Close()
+		var mu sync.Mutex
+		pending := make(map[string]bool)
+		var timer *time.Timer
+		flush := func() {
+			mu.Lock()
+			prefixes := pending
+			pending = make(map[string]bool)
+			mu.Unlock()
+			for _, l := range fileLayouts {
+				if prefixes[l.prefix] {
+					logrus.Infof("Storage changed: %s", l.dir)
+					onChange(l.prefix)
+					delete(prefixes, l.prefix)
+				}
+			}
+		}
+		for {
+			select {
+			case ev, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				if ev.Has(fsnotify.Create) {
+					if fi, err := os.Stat(ev.Name); err == nil && fi.IsDir() {
+						s.watchTree(w, ev.Name)
+					}
+				}
+				prefix := s.prefixOf(ev.Name)
+				if prefix == "" {
+					continue
+				}
+				mu.Lock()
+				pending[prefix] = true
+				if timer == nil {
+					timer = time.AfterFunc(watchDebounce, flush)
+				} else {
+					timer.Reset(watchDebounce)
+				}
+				mu.Unlock()
+			case err, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+				logrus.Errorf("Storage watch error: %v", err)
+			}
+		}
+	}()
+	return nil
+}
+
+/**
+ * Add a directory and all its subdirectories to the watcher
+ */
+func (s *fileStore) watchTree(w *fsnotify.Watcher, root string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return w.Add(path)
+		}
+		return nil
+	})
+}
+
+/**
+ * Get the prefix of the item kind a changed path belongs to
+ */
+func (s *fileStore) prefixOf(path string) string {
+	rel, err := filepath.Rel(s.dir, path)
+	if err != nil || strings.HasSuffix(rel, ".tmp") {
+		return ""
+	}
+	dir, _, _ := strings.Cut(filepath.ToSlash(rel), "/")
+	for _, l := range fileLayouts {
+		if l.dir == dir {
+			return l.prefix
+		}
+	}
+	return ""
+}
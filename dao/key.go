@@ -9,3 +9,7 @@ func KeyToPath(key, prefix string) string {
 func KeyToID(key, prefix string) string {
 	return strings.ReplaceAll(strings.TrimPrefix(key, prefix), ":", ".")
 }
+
+func IDToKey(id, prefix string) string {
+	return prefix + strings.ReplaceAll(id, ".", ":")
+}
@@ -0,0 +1,7 @@
+package dao
+
+// Partial defines a shared template fragment, which prompts pull in with {{template "partial_id" .}}
+type Partial struct {
+	Description string `json:"description,omitempty" description:"描述信息"`
+	Content     string `json:"content" description:"模板片段内容"`
+}
@@ -0,0 +1,90 @@
+package dao
+
+import (
+	"context"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+/**
+ * Cache of partials, safe for concurrent use
+ */
+type PartialCache struct {
+	mu       sync.RWMutex
+	partials map[string]Partial
+}
+
+/**
+ * Create new PartialCache instance
+ * @return Pointer to initialized PartialCache
+ */
+func NewPartialCache() *PartialCache {
+	c := &PartialCache{
+		partials: make(map[string]Partial),
+	}
+	return c
+}
+
+/**
+ * Get partial from cache
+ * @param c PartialCache instance
+ * @param partial_id Partial ID
+ * @return Partial content and exists flag
+ */
+func (c *PartialCache) Get(partial_id string) (Partial, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	val, ok := c.partials[partial_id]
+	return val, ok
+}
+
+/**
+ * Get all partials from cache
+ * @param c PartialCache instance
+ * @return copy of the map of all partials
+ */
+func (c *PartialCache) All() map[string]Partial {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	all := make(map[string]Partial, len(c.partials))
+	for k, v := range c.partials {
+		all[k] = v
+	}
+	return all
+}
+
+/**
+ * Load partials from storage into cache
+ * @param c PartialCache instance
+ * @param ctx Context for storage operations
+ * @return Error if loading fails
+ */
+func (c *PartialCache) Load(ctx context.Context) error {
+	logrus.Info("Loading partials from storage")
+
+	keys, err := KeysByPrefix(PREFIX_PARTIALS)
+	if err != nil {
+		return err
+	}
+
+	newPartials := make(map[string]Partial)
+	for _, key := range keys {
+		var val Partial
+		if err := GetJSON(key, &val); err != nil {
+			return err
+		}
+
+		partial_id := KeyToID(key, PREFIX_PARTIALS)
+		if partial_id == "" {
+			logrus.Warnf("Partial ID cannot be empty")
+			continue
+		}
+		newPartials[partial_id] = val
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.partials = newPartials
+	return nil
+}
@@ -2,6 +2,7 @@ package dao
 
 import (
 	"context"
+	"sync"
 
 	"github.com/sirupsen/logrus"
 )
@@ -16,13 +17,15 @@ const (
 
 type PromptLoaded struct {
 	Prompt
-	Origin PromptOrigin
+	Origin    PromptOrigin
+	Extension string
 }
 
 /**
- * Cache for storing prompt templates with origins
+ * Cache for storing prompt templates with origins, safe for concurrent use
  */
 type PromptCache struct {
+	mu        sync.RWMutex
 	templates map[string]PromptLoaded
 }
 
@@ -48,6 +51,8 @@ func (c *PromptCache) Set(prompt_id string, value Prompt, origin PromptOrigin) {
 	if origin != PromptOrigin_Direct && origin != PromptOrigin_Extension {
 		panic("Invalid origin")
 	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.templates[prompt_id] = PromptLoaded{
 		Prompt: value,
 		Origin: origin,
@@ -61,6 +66,8 @@ func (c *PromptCache) Set(prompt_id string, value Prompt, origin PromptOrigin) {
  * @return Prompt template and its origin
  */
 func (c *PromptCache) Get(prompt_id string) (Prompt, PromptOrigin) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	val, ok := c.templates[prompt_id]
 	if !ok {
 		return Prompt{}, PromptOrigin_Notexist
@@ -68,23 +75,69 @@ func (c *PromptCache) Get(prompt_id string) (Prompt, PromptOrigin) {
 	return val.Prompt, val.Origin
 }
 
+/**
+ * Get prompt template from cache with where it was loaded from
+ * @param c PromptCache instance
+ * @param prompt_id ID of the prompt template
+ * @return loaded prompt template and exists flag
+ */
+func (c *PromptCache) Loaded(prompt_id string) (PromptLoaded, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	val, ok := c.templates[prompt_id]
+	return val, ok
+}
+
 /**
  * Get all prompt templates from cache
  * @param c PromptCache instance
  * @return Map of all prompt templates
  */
 func (c *PromptCache) All() map[string]PromptLoaded {
-	return c.templates
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	all := make(map[string]PromptLoaded, len(c.templates))
+	for k, v := range c.templates {
+		all[k] = v
+	}
+	return all
+}
+
+/**
+ * Replace all extension-registered prompt templates
+ * @param c PromptCache instance
+ * @param contributed Prompt templates contributed by the current extension set, keyed by prompt ID
+ * @description
+ * - Extension-origin prompts missing from contributed are dropped, so uninstalled or disabled extensions leave nothing behind
+ * - Directly-registered prompts keep precedence over contributed ones with the same ID
+ */
+func (c *PromptCache) ReplaceExtensionPrompts(contributed map[string]PromptLoaded) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	newPrompts := make(map[string]PromptLoaded)
+	for k, t := range c.templates {
+		if t.Origin == PromptOrigin_Direct {
+			newPrompts[k] = t
+		}
+	}
+	for k, t := range contributed {
+		if _, ok := newPrompts[k]; ok {
+			continue
+		}
+		t.Origin = PromptOrigin_Extension
+		newPrompts[k] = t
+	}
+	c.templates = newPrompts
 }
 
 /**
- * Load prompt templates from Redis into cache
+ * Load prompt templates from storage into cache
  * @param c PromptCache instance
- * @param ctx Context for Redis operations
+ * @param ctx Context for storage operations
  * @return Error if loading fails
  */
-func (c *PromptCache) LoadFromRedis(ctx context.Context) error {
-	logrus.Info("Loading templates from Redis")
+func (c *PromptCache) Load(ctx context.Context) error {
+	logrus.Info("Loading templates from storage")
 
 	keys, err := KeysByPrefix(PREFIX_TEMPLATES)
 	if err != nil {
@@ -92,13 +145,7 @@ func (c *PromptCache) LoadFromRedis(ctx context.Context) error {
 	}
 
 	newPrompts := make(map[string]PromptLoaded)
-	//	Migrate extension-registered prompt templates to newPrompts
-	for k, t := range c.templates {
-		if t.Origin == PromptOrigin_Extension {
-			newPrompts[k] = t
-		}
-	}
-	//	Load directly-registered prompt templates from Redis, which may override extension-registered ones
+	//	Load directly-registered prompt templates from storage, which may override extension-registered ones
 	for _, key := range keys {
 		var val Prompt
 		if err := GetJSON(key, &val); err != nil {
@@ -116,6 +163,14 @@ func (c *PromptCache) LoadFromRedis(ctx context.Context) error {
 		}
 	}
 
+	//	Migrate extension-registered prompt templates to newPrompts
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, t := range c.templates {
+		if _, ok := newPrompts[k]; !ok && t.Origin == PromptOrigin_Extension {
+			newPrompts[k] = t
+		}
+	}
 	c.templates = newPrompts
 	return nil
 }
@@ -36,14 +36,17 @@ func InitRedis(addr, password string, db int) error {
 		Client = nil
 		return errors.Wrap(err, "failed to connect to redis")
 	}
+	store = &redisStore{client: Client}
+	counters = &redisCounters{client: Client}
+	sessions = &redisSessions{client: Client}
 	return nil
 }
 
 /**
- * Set JSON encoded value in Redis
- * @param key Redis key
+ * Set JSON encoded value in storage
+ * @param key storage key
  * @param value Value to be stored
- * @param expiration Key expiration duration
+ * @param expiration Key expiration duration, ignored by file storage
  * @return Error if operation fails
  */
 func SetJSON(key string, value any, expiration time.Duration) error {
@@ -51,19 +54,19 @@ func SetJSON(key string, value any, expiration time.Duration) error {
 	if err != nil {
 		return errors.Wrap(err, "failed to marshal value")
 	}
-	return Client.Set(Ctx, key, data, expiration).Err()
+	return store.Set(key, data, expiration)
 }
 
 /**
- * Get JSON decoded value from Redis
- * @param key Redis key
- * @param dest Destination object to store data
+ * Get JSON decoded value from storage
+ * @param key storage key
+ * @param dest Destination object to store data, left unchanged if the key doesn't exist
  * @return Error if operation fails
  */
 func GetJSON(key string, dest any) error {
-	data, err := Client.Get(Ctx, key).Bytes()
+	data, err := store.Get(key)
 	if err != nil {
-		if err == redis.Nil {
+		if err == ErrNotFound {
 			return nil
 		}
 		return errors.Wrap(err, "failed to get value")
@@ -72,23 +75,22 @@ func GetJSON(key string, dest any) error {
 }
 
 /**
- * Delete key from Redis
- * @param key Redis key to delete
+ * Delete key from storage
+ * @param key storage key to delete
  * @return Error if operation fails
  */
 func Del(key string) error {
-	return Client.Del(Ctx, key).Err()
+	return store.Del(key)
 }
 
 /**
- * Check if key exists in Redis
- * @param key Redis key to check
+ * Check if key exists in storage
+ * @param key storage key to check
  * @return exists Whether key exists
  * @return Error if operation fails
  */
 func Exists(key string) (bool, error) {
-	n, err := Client.Exists(Ctx, key).Result()
-	return n > 0, err
+	return store.Exists(key)
 }
 
 /**
@@ -98,30 +100,35 @@ func Exists(key string) (bool, error) {
  * @return Error if operation fails
  */
 func KeysByPrefix(prefix string) ([]string, error) {
-	var keys []string
-	var cursor uint64
-	var err error
+	keys, err := store.Keys(prefix)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to scan keys")
+	}
+	return keys, nil
+}
 
-	for {
-		// Safely iterate keys using SCAN command
-		var partialKeys []string
-		partialKeys, cursor, err = Client.Scan(Ctx, cursor, prefix+"*", 100).Result()
+/**
+ * Write and delete a set of keys at once
+ * @param values values to be stored as JSON, by key
+ * @param dels keys to delete
+ * @return Error if operation fails
+ * @description
+ * - Redis applies all changes in one transaction; file storage replaces files one by one
+ */
+func Apply(values map[string]any, dels []string) error {
+	sets := make(map[string][]byte, len(values))
+	for key, value := range values {
+		data, err := json.Marshal(value)
 		if err != nil {
-			return nil, errors.Wrap(err, "failed to scan keys")
-		}
-
-		keys = append(keys, partialKeys...)
-
-		if cursor == 0 { // Iteration completed
-			break
+			return errors.Wrap(err, "failed to marshal value")
 		}
+		sets[key] = data
 	}
-
-	return keys, nil
+	return store.Apply(sets, dels)
 }
 
 /**
- * Load all JSON values under prefix from Redis
+ * Load all JSON values under prefix from storage
  * @param prefix Key prefix pattern
  * @return Map of key-value pairs
  * @return Error if operation fails
@@ -0,0 +1,191 @@
+package dao
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Returned by SwapSession if the session was changed by another request meanwhile
+var ErrConflict = errors.New("changed concurrently")
+
+/**
+ * Expiring chat sessions, stored as JSON documents by ID
+ * @description
+ * - Sessions are kept in Redis when it's the storage, so all instances share them;
+ *   with file storage they're kept in process memory
+ * - Sessions are not items: they're neither loaded, exported nor watched
+ */
+type Sessions interface {
+	/**
+	 * Get a session
+	 * @throws ErrNotFound if it doesn't exist or expired
+	 */
+	Get(id string) ([]byte, error)
+	/**
+	 * Create or replace a session
+	 * @param ttl time after which the session expires
+	 */
+	Set(id string, data []byte, ttl time.Duration) error
+	Del(id string) error
+	/**
+	 * Replace a session if it still holds the value it was read with
+	 * @param old value the session was read with
+	 * @param ttl time after which the session expires, counted from the swap
+	 * @throws ErrNotFound if it doesn't exist, ErrConflict if it holds another value
+	 */
+	Swap(id string, old, data []byte, ttl time.Duration) error
+}
+
+// Sessions used by the dao functions, replaced by InitRedis
+var sessions Sessions = newMemSessions()
+
+/**
+ * Get a session, as Sessions.Get
+ */
+func GetSession(id string) ([]byte, error) {
+	return sessions.Get(id)
+}
+
+/**
+ * Create or replace a session, as Sessions.Set
+ */
+func SetSession(id string, data []byte, ttl time.Duration) error {
+	return sessions.Set(id, data, ttl)
+}
+
+/**
+ * Delete a session, as Sessions.Del
+ */
+func DelSession(id string) error {
+	return sessions.Del(id)
+}
+
+/**
+ * Replace a session unless it was changed, as Sessions.Swap
+ */
+func SwapSession(id string, old, data []byte, ttl time.Duration) error {
+	return sessions.Swap(id, old, data, ttl)
+}
+
+/**
+ * Sessions on the global Redis client
+ */
+type redisSessions struct {
+	client *redis.Client
+}
+
+func (s *redisSessions) Get(id string) ([]byte, error) {
+	data, err := s.client.Get(Ctx, PREFIX_SESSIONS+id).Bytes()
+	if err == redis.Nil {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (s *redisSessions) Set(id string, data []byte, ttl time.Duration) error {
+	return s.client.Set(Ctx, PREFIX_SESSIONS+id, data, ttl).Err()
+}
+
+func (s *redisSessions) Del(id string) error {
+	return s.client.Del(Ctx, PREFIX_SESSIONS+id).Err()
+}
+
+func (s *redisSessions) Swap(id string, old, data []byte, ttl time.Duration) error {
+	key := PREFIX_SESSIONS + id
+	err := s.client.Watch(Ctx, func(tx *redis.Tx) error {
+		cur, err := tx.Get(Ctx, key).Bytes()
+		if err == redis.Nil {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(cur, old) {
+			return ErrConflict
+		}
+		_, err = tx.TxPipelined(Ctx, func(p redis.Pipeliner) error {
+			p.Set(Ctx, key, data, ttl)
+			return nil
+		})
+		return err
+	}, key)
+	if err == redis.TxFailedErr {
+		return ErrConflict
+	}
+	return err
+}
+
+/**
+ * Sessions in process memory
+ */
+type memSessions struct {
+	mu     sync.Mutex
+	values map[string]memSession
+	swept  time.Time
+}
+
+type memSession struct {
+	data    []byte
+	expires time.Time
+}
+
+func newMemSessions() *memSessions {
+	return &memSessions{values: make(map[string]memSession), swept: time.Now()}
+}
+
+func (s *memSessions) Get(id string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.values[id]
+	if !ok || time.Now().After(v.expires) {
+		return nil, ErrNotFound
+	}
+	return v.data, nil
+}
+
+func (s *memSessions) Set(id string, data []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.put(id, data, ttl)
+	return nil
+}
+
+func (s *memSessions) Del(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.values, id)
+	return nil
+}
+
+func (s *memSessions) Swap(id string, old, data []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.values[id]
+	if !ok || time.Now().After(v.expires) {
+		return ErrNotFound
+	}
+	if !bytes.Equal(v.data, old) {
+		return ErrConflict
+	}
+	s.put(id, data, ttl)
+	return nil
+}
+
+// Store a session, the lock being held
+func (s *memSessions) put(id string, data []byte, ttl time.Duration) {
+	now := time.Now()
+	s.values[id] = memSession{data: data, expires: now.Add(ttl)}
+	// Drop expired sessions once a minute, so the map doesn't grow forever
+	if now.Sub(s.swept) > time.Minute {
+		for k, v := range s.values {
+			if now.After(v.expires) {
+				delete(s.values, k)
+			}
+		}
+		s.swept = now
+	}
+}
@@ -0,0 +1,125 @@
+package dao
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Returned by Store.Get for a key that doesn't exist
+var ErrNotFound = errors.New("key not found")
+
+/**
+ * Storage of the JSON values behind the caches, keyed by Redis-style keys (e.g. "shenma:tools:codebase:lookup_reference")
+ * @description
+ * - Redis is the default backend; a directory tree can be used instead for local development and tests
+ * - Values are JSON documents, stored as given by SetJSON
+ */
+type Store interface {
+	Get(key string) ([]byte, error)
+	Set(key string, data []byte, expiration time.Duration) error
+	Del(key string) error
+	Exists(key string) (bool, error)
+	Keys(prefix string) ([]string, error)
+	Apply(sets map[string][]byte, dels []string) error
+}
+
+/**
+ * Store whose changes made outside this process can be watched
+ */
+type Watcher interface {
+	/**
+	 * Call onChange with the key prefix (one of PREFIX_*) of every changed item kind, until the process exits
+	 */
+	Watch(onChange func(prefix string)) error
+}
+
+// Storage used by the dao functions, set by InitRedis or InitFileStore
+var store Store
+
+/**
+ * Check whether the storage backend has been initialized
+ */
+func Ready() bool {
+	return store != nil
+}
+
+/**
+ * Watch the storage for changes made outside this process
+ * @param onChange called with the key prefix of every changed item kind
+ * @return error if watching fails; storages that can't be watched are left alone
+ */
+func Watch(onChange func(prefix string)) error {
+	if w, ok := store.(Watcher); ok {
+		return w.Watch(onChange)
+	}
+	return nil
+}
+
+/**
+ * Store on the global Redis client
+ */
+type redisStore struct {
+	client *redis.Client
+}
+
+func (s *redisStore) Get(key string) ([]byte, error) {
+	data, err := s.client.Get(Ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (s *redisStore) Set(key string, data []byte, expiration time.Duration) error {
+	return s.client.Set(Ctx, key, data, expiration).Err()
+}
+
+func (s *redisStore) Del(key string) error {
+	return s.client.Del(Ctx, key).Err()
+}
+
+func (s *redisStore) Exists(key string) (bool, error) {
+	n, err := s.client.Exists(Ctx, key).Result()
+	return n > 0, err
+}
+
+/**
+ * Write and delete keys in one MULTI/EXEC transaction, so readers see all changes or none
+ */
+func (s *redisStore) Apply(sets map[string][]byte, dels []string) error {
+	_, err := s.client.TxPipelined(Ctx, func(pipe redis.Pipeliner) error {
+		for key, data := range sets {
+			pipe.Set(Ctx, key, data, 0)
+		}
+		if len(dels) > 0 {
+			pipe.Del(Ctx, dels...)
+		}
+		return nil
+	})
+	return err
+}
+
+func (s *redisStore) Keys(prefix string) ([]string, error) {
+	var keys []string
+	var cursor uint64
+	var err error
+
+	for {
+		// Safely iterate keys using SCAN command
+		var partialKeys []string
+		partialKeys, cursor, err = s.client.Scan(Ctx, cursor, prefix+"*", 100).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, partialKeys...)
+
+		if cursor == 0 { // Iteration completed
+			break
+		}
+	}
+
+	return keys, nil
+}
@@ -14,14 +14,17 @@ type Tool struct {
 	Grpc        *Grpc                  `json:"grpc,omitempty"`
 }
 
+// Headers of restful tools and metadata of gRPC tools may refer to secrets as ${secret:secret_id}
 type Restful struct {
-	Url    string `json:"url"`
-	Method string `json:"method"`
+	Url     string            `json:"url"`
+	Method  string            `json:"method"`
+	Headers map[string]string `json:"headers,omitempty"`
 }
 
 type Grpc struct {
-	Url    string `json:"url"`
-	Method string `json:"method"`
+	Url     string            `json:"url"`
+	Method  string            `json:"method"`
+	Headers map[string]string `json:"headers,omitempty"`
 }
 
 // ValidToolTypes defines valid tool type enums
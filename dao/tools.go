@@ -2,11 +2,16 @@ package dao
 
 import (
 	"context"
+	"sync"
 
 	"github.com/sirupsen/logrus"
 )
 
+/**
+ * Cache of tools, safe for concurrent use
+ */
 type ToolCache struct {
+	mu    sync.RWMutex
 	tools map[string]Tool
 }
 
@@ -24,10 +29,16 @@ func NewToolCache() *ToolCache {
 /**
  * Get all tools from cache
  * @param c ToolCache instance
- * @return Map of all tools
+ * @return copy of the map of all tools
  */
 func (c *ToolCache) All() map[string]Tool {
-	return c.tools
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	all := make(map[string]Tool, len(c.tools))
+	for k, v := range c.tools {
+		all[k] = v
+	}
+	return all
 }
 
 /**
@@ -41,6 +52,8 @@ func (c *ToolCache) Register(toolId string, tool Tool) {
 		logrus.Errorf("Tool ID cannot be empty")
 		return
 	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.tools[toolId] = tool
 }
 
@@ -51,18 +64,20 @@ func (c *ToolCache) Register(toolId string, tool Tool) {
  * @return Tool details and exists flag
  */
 func (c *ToolCache) Get(toolId string) (Tool, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	tool, ok := c.tools[toolId]
 	return tool, ok
 }
 
 /**
- * Load tools from Redis into cache
+ * Load tools from storage into cache
  * @param c ToolCache instance
- * @param ctx Context for Redis operations
+ * @param ctx Context for storage operations
  * @return Error if loading fails
  */
-func (c *ToolCache) LoadFromRedis(ctx context.Context) error {
-	logrus.Info("Loading tools from Redis")
+func (c *ToolCache) Load(ctx context.Context) error {
+	logrus.Info("Loading tools from storage")
 
 	keys, err := KeysByPrefix(PREFIX_TOOLS)
 	if err != nil {
@@ -83,6 +98,8 @@ func (c *ToolCache) LoadFromRedis(ctx context.Context) error {
 		}
 		newTools[toolId] = tool
 	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.tools = newTools
 	return nil
 }
@@ -15,72 +15,147 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/api/environs": {
+        "/api/audit": {
             "get": {
-                "description": "Get all defined environment variables in system",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get audit records of configuration changes and chats, newest first.\nFilters are exact matches; since and until are RFC 3339 times",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Environs"
+                    "Audit"
+                ],
+                "summary": "Query audit log",
+                "parameters": [
+                    {
+                        "enum": [
+                            "change",
+                            "chat"
+                        ],
+                        "type": "string",
+                        "description": "Record type",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Caller who made the change or chat",
+                        "name": "actor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Kind of item changed, e.g. prompts or environs",
+                        "name": "kind",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ID of the item changed",
+                        "name": "target",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Prompt of the chat",
+                        "name": "prompt",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Model of the chat",
+                        "name": "model",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Trace ID of the request",
+                        "name": "trace_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Session of the chat",
+                        "name": "session",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Earliest time, included",
+                        "name": "since",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Latest time, excluded",
+                        "name": "until",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of records, 100 by default, at most 1000",
+                        "name": "limit",
+                        "in": "query"
+                    }
                 ],
-                "summary": "List all environment variables",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "type": "string"
+                                "$ref": "#/definitions/service.AuditRecord"
                             }
                         }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
                     }
                 }
             }
         },
-        "/api/environs/{environ_id}": {
+        "/api/environs": {
             "get": {
-                "description": "Get value of specified environment variable",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get all defined environment variables in system, as seen by the scope given by the scope parameter or by the X-Tenant-Id, X-Project-Id and X-User-Id headers",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "Environs"
                 ],
-                "summary": "Get environment variable",
+                "summary": "List all environment variables",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Environment variable ID",
-                        "name": "environ_id",
-                        "in": "path",
-                        "required": true
-                    }
-                ],
-                "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {}
-                    },
-                    "404": {
-                        "description": "Not Found",
-                        "schema": {
-                            "$ref": "#/definitions/api.ResponseData"
-                        }
+                        "description": "Scope as comma-separated kind:id pairs, e.g. tenant:acme,project:web,user:alice",
+                        "name": "scope",
+                        "in": "query"
                     }
-                }
-            }
-        },
-        "/api/extensions": {
-            "get": {
-                "description": "Get all available prompt extension IDs in the system",
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "Extensions"
                 ],
-                "summary": "List all prompt extension IDs",
                 "responses": {
                     "200": {
                         "description": "OK",
@@ -90,34 +165,70 @@ const docTemplate = `{
                                 "type": "string"
                             }
                         }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
                     }
                 }
             }
         },
-        "/api/extensions/{extension_id}": {
+        "/api/environs/{environ_id}": {
             "get": {
-                "description": "Get detailed information of prompt extension by ID",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get value of specified environment variable, as seen by the scope of the X-Tenant-Id, X-Project-Id and X-User-Id headers.\nWith the scope parameter, get the value seen by that scope and where it comes from (service.EnvironValue):\nthe most specific of user, project, tenant overrides setting it, or global",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Extensions"
+                    "Environs"
                 ],
-                "summary": "Get specified prompt extension details",
+                "summary": "Get environment variable",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Extension ID",
-                        "name": "extension_id",
+                        "description": "Environment variable ID",
+                        "name": "environ_id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Scope as comma-separated kind:id pairs, e.g. tenant:acme,project:web,user:alice",
+                        "name": "scope",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
+                        "schema": {}
+                    },
+                    "400": {
+                        "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/dao.PromptExtension"
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
                         }
                     },
                     "404": {
@@ -127,102 +238,113 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/api/prompts": {
-            "get": {
-                "description": "Get all available prompt templates in the system",
-                "produces": [
-                    "application/json"
+            },
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
                 ],
-                "tags": [
-                    "Prompts"
+                "description": "Set value of specified environment variable, creating it if it doesn't exist.\nWith the scope parameter naming one scope, e.g. tenant:acme, set the variable's override in that scope instead",
+                "consumes": [
+                    "application/json"
                 ],
-                "summary": "List all prompt templates",
-                "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "type": "array",
-                            "items": {
-                                "type": "string"
-                            }
-                        }
-                    }
-                }
-            }
-        },
-        "/api/prompts/{prompt_id}": {
-            "get": {
-                "description": "Get detailed information of prompt template by ID",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Prompts"
+                    "Environs"
                 ],
-                "summary": "Get specified prompt template details",
+                "summary": "Set environment variable",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Prompt template ID",
-                        "name": "prompt_id",
+                        "description": "Environment variable ID",
+                        "name": "environ_id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Scope to set the override in, e.g. project:web",
+                        "name": "scope",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Variable value, any JSON value",
+                        "name": "value",
+                        "in": "body",
+                        "required": true,
+                        "schema": {}
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/dao.Prompt"
+                            "$ref": "#/definitions/api.ResponseData"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
                         "schema": {
                             "$ref": "#/definitions/api.ResponseData"
                         }
                     }
                 }
-            }
-        },
-        "/api/prompts/{prompt_id}/chat": {
-            "post": {
-                "description": "Chat interaction with LLM using specified prompt template",
-                "consumes": [
-                    "application/json"
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
                 ],
+                "description": "Delete specified environment variable, or with the scope parameter naming one scope, its override in that scope",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Prompts"
+                    "Environs"
                 ],
-                "summary": "Interact with LLM using prompt",
+                "summary": "Delete environment variable",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Prompt template ID",
-                        "name": "prompt_id",
+                        "description": "Environment variable ID",
+                        "name": "environ_id",
                         "in": "path",
                         "required": true
                     },
                     {
-                        "description": "Chat parameters",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/service.ChatPromptRequest"
-                        }
+                        "type": "string",
+                        "description": "Scope to delete the override from, e.g. project:web",
+                        "name": "scope",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/service.ChatResponse"
+                            "$ref": "#/definitions/api.ResponseData"
                         }
                     },
                     "400": {
@@ -231,6 +353,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/api.ResponseData"
                         }
                     },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    },
                     "404": {
                         "description": "Not Found",
                         "schema": {
@@ -246,9 +374,17 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/prompts/{prompt_id}/render": {
+        "/api/eval": {
             "post": {
-                "description": "Render the prompt template with given args",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Chat with every prompt and model over each dataset sample, with bounded concurrency, and score the outputs.\nScorers: exact (output equals expected), schema (output conforms to the prompt's returns) and judge (scored by judge_prompt).\nThe report compares the mean scores of every prompt/model variant and lists per-sample results",
                 "consumes": [
                     "application/json"
                 ],
@@ -256,24 +392,17 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Prompts"
+                    "Eval"
                 ],
-                "summary": "Render specified prompt template",
+                "summary": "Evaluate prompts and models over a dataset",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Prompt template ID",
-                        "name": "prompt_id",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "description": "Rendering parameters",
+                        "description": "Evaluation parameters",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/api.RenderPromptRequest"
+                            "$ref": "#/definitions/service.EvalRequest"
                         }
                     }
                 ],
@@ -281,7 +410,7 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.RenderPromptResponse"
+                            "$ref": "#/definitions/service.EvalReport"
                         }
                     },
                     "400": {
@@ -295,54 +424,180 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/api.ResponseData"
                         }
-                    },
-                    "500": {
-                        "description": "Internal Server Error",
-                        "schema": {
-                            "$ref": "#/definitions/api.ResponseData"
-                        }
                     }
                 }
             }
         },
-        "/api/tools": {
+        "/api/export": {
             "get": {
-                "description": "Get a list of all available tools in the system",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Export extensions, directly-published prompts, tools, partials and environment variables as one bundle.\nWith format=tar the bundle is a tar archive laid out like a file storage directory.",
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "application/x-tar"
                 ],
                 "tags": [
-                    "Tools"
+                    "Bundle"
                 ],
-                "summary": "List all tools",
-                "responses": {
+                "summary": "Export bundle",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Comma-separated kinds (environs, scopes, secrets, tools, partials, extensions, prompts) or storage prefixes to export, all by default",
+                        "name": "prefixes",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "json",
+                            "tar"
+                        ],
+                        "type": "string",
+                        "default": "json",
+                        "description": "Bundle format",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
                     "200": {
                         "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/service.Bundle"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/extensions": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get available prompt extensions in the system, sorted by ID. Returns IDs by default, or summaries if summary=true",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Extensions"
+                ],
+                "summary": "List all prompt extension IDs",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only extensions supporting this language",
+                        "name": "language",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only extensions of this publisher",
+                        "name": "publisher",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Free-text search over ID, name, display name and description",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number, starting from 1",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size, 0 means no pagination",
+                        "name": "page_size",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Return summaries instead of IDs",
+                        "name": "summary",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Extension IDs, or service.ExtensionSummary objects if summary=true",
                         "schema": {
                             "type": "array",
                             "items": {
                                 "type": "string"
                             }
+                        },
+                        "headers": {
+                            "X-Total-Count": {
+                                "type": "int",
+                                "description": "Number of matching extensions before pagination"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
                         }
                     }
                 }
             }
         },
-        "/api/tools/{tool_id}": {
+        "/api/extensions/{extension_id}": {
             "get": {
-                "description": "Get detailed information about specified tool",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get detailed information of prompt extension by ID",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Tools"
+                    "Extensions"
                 ],
-                "summary": "Get tool details",
+                "summary": "Get specified prompt extension details",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "工具ID",
-                        "name": "tool_id",
+                        "description": "Extension ID",
+                        "name": "extension_id",
                         "in": "path",
                         "required": true
                     }
@@ -351,7 +606,7 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/dao.Tool"
+                            "$ref": "#/definitions/dao.PromptExtension"
                         }
                     },
                     "404": {
@@ -361,61 +616,2750 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        }
-    },
-    "definitions": {
-        "api.RenderPromptRequest": {
-            "type": "object",
-            "properties": {
-                "args": {
-                    "type": "object",
-                    "additionalProperties": true
+            },
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Install a prompt extension, or update it if already installed. Its contributed prompts become available immediately",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Extensions"
+                ],
+                "summary": "Install prompt extension",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Extension ID",
+                        "name": "extension_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Extension definition (package.json)",
+                        "name": "extension",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dao.PromptExtension"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dao.PromptExtension"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Remove prompt extension and all prompts contributed by it",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Extensions"
+                ],
+                "summary": "Uninstall prompt extension",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Extension ID",
+                        "name": "extension_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    }
                 }
             }
         },
-        "api.RenderPromptResponse": {
-            "type": "object",
-            "properties": {
-                "kind": {
-                    "type": "string"
-                },
-                "messages": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/dao.Message"
+        "/api/extensions/{extension_id}/disable": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Disable an installed prompt extension, so that its contributed prompts are removed until it is enabled again",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Extensions"
+                ],
+                "summary": "Disable prompt extension",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Extension ID",
+                        "name": "extension_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dao.PromptExtension"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
                     }
-                },
-                "prompt": {
-                    "type": "string"
                 }
             }
         },
-        "api.ResponseData": {
-            "type": "object",
-            "properties": {
-                "code": {
-                    "type": "string"
-                },
-                "data": {},
-                "message": {
-                    "type": "string"
-                },
-                "success": {
-                    "type": "boolean"
+        "/api/extensions/{extension_id}/enable": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Enable an installed prompt extension, so that its contributed prompts become available",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Extensions"
+                ],
+                "summary": "Enable prompt extension",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Extension ID",
+                        "name": "extension_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dao.PromptExtension"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/import": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Import a bundle produced by export, as JSON or as a tar archive (optionally gzipped).\nmerge creates and updates items; replace also deletes items of the imported kinds that are not in the bundle.\nChanges are written all at once; with dry_run they are only reported.",
+                "consumes": [
+                    "application/json",
+                    "application/x-tar"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Bundle"
+                ],
+                "summary": "Import bundle",
+                "parameters": [
+                    {
+                        "description": "Bundle to import",
+                        "name": "bundle",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/service.Bundle"
+                        }
+                    },
+                    {
+                        "enum": [
+                            "merge",
+                            "replace"
+                        ],
+                        "type": "string",
+                        "default": "merge",
+                        "description": "Import mode",
+                        "name": "mode",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Report the changes without writing them",
+                        "name": "dry_run",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma-separated kinds or storage prefixes to import, by default those the bundle was exported with",
+                        "name": "prefixes",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/service.ImportResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/partials": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get IDs of all shared template partials, which prompts pull in with {{template \"partial_id\" .}}",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Partials"
+                ],
+                "summary": "List all template partials",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/partials/{partial_id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get content of specified template partial",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Partials"
+                ],
+                "summary": "Get template partial",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Partial ID",
+                        "name": "partial_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dao.Partial"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/prompts": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get available prompt templates in the system, sorted by ID. Returns IDs by default, or summaries if summary=true",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Prompts"
+                ],
+                "summary": "List all prompt templates",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only prompts supporting this scene, e.g. codereview",
+                        "name": "supports",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only prompts whose extension supports this language",
+                        "name": "language",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only prompts of this origin (direct/extension)",
+                        "name": "origin",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only prompts whose extension has this publisher",
+                        "name": "publisher",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Free-text search over ID, name and description",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number, starting from 1",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size, 0 means no pagination",
+                        "name": "page_size",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Return summaries instead of IDs",
+                        "name": "summary",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Prompt IDs, or service.PromptSummary objects if summary=true",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "string"
+                            }
+                        },
+                        "headers": {
+                            "X-Total-Count": {
+                                "type": "int",
+                                "description": "Number of matching prompts before pagination"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/prompts/validate": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Check a prompt template before publishing it. Templates are parsed against the live template functions.\nReports syntax errors, unknown functions (e.g. missing tools), unknown message roles, invalid JSON Schemas in parameters/returns\nand .args references not declared in parameters. If sample_args is given, the prompt is also rendered with all tool calls mocked\nand the shared variables of scope, over the X-Tenant-Id, X-Project-Id and X-User-Id headers",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Prompts"
+                ],
+                "summary": "Validate prompt template",
+                "parameters": [
+                    {
+                        "description": "Prompt template to validate, with optional sample_args",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/service.ValidatePromptRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/service.ValidatePromptResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/prompts/{prompt_id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get detailed information of prompt template by ID.\nIf the template fails to compile, valid is false and error holds the template key, line and message.\nPrompts published from git by sync have version set to the SHA of the commit they were published from",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Prompts"
+                ],
+                "summary": "Get specified prompt template details",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Prompt template ID",
+                        "name": "prompt_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dao.Prompt"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/prompts/{prompt_id}/chat": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Chat interaction with LLM using specified prompt template.\nscope selects the overrides of shared variables, over the X-Tenant-Id, X-Project-Id and X-User-Id headers.\nChats are limited by the request and token quotas of their user, tenant, prompt and model, reported in X-RateLimit-* headers",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Prompts"
+                ],
+                "summary": "Interact with LLM using prompt",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Prompt template ID",
+                        "name": "prompt_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Chat parameters",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/service.ChatPromptRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/service.ChatResponse"
+                        },
+                        "headers": {
+                            "X-RateLimit-Limit-Requests": {
+                                "type": "integer",
+                                "description": "Request limit of the quota closest to being exhausted"
+                            },
+                            "X-RateLimit-Limit-Tokens": {
+                                "type": "integer",
+                                "description": "Token limit of the quota closest to being exhausted"
+                            },
+                            "X-RateLimit-Remaining-Requests": {
+                                "type": "integer",
+                                "description": "Requests remaining in that quota"
+                            },
+                            "X-RateLimit-Remaining-Tokens": {
+                                "type": "integer",
+                                "description": "Tokens remaining in that quota"
+                            },
+                            "X-RateLimit-Reset-Requests": {
+                                "type": "integer",
+                                "description": "Seconds until the window of that quota ends"
+                            },
+                            "X-RateLimit-Reset-Tokens": {
+                                "type": "integer",
+                                "description": "Seconds until the window of that quota ends"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/prompts/{prompt_id}/render": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Render the prompt template with given args, and report token counts of the result.\nOn render failure, data holds the template key, line, column and failing tool (service.RenderError).\nIf model is given, truncatable args are trimmed to fit the prompt budget as in chat.\nmocks maps tool IDs to canned responses returned instead of calling the tools.\ntool_mode \"record\" captures real tool calls to the named fixture file (publisher role), \"replay\" serves tool calls from it.\nscope selects the overrides of shared variables, over the X-Tenant-Id, X-Project-Id and X-User-Id headers",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Prompts"
+                ],
+                "summary": "Render specified prompt template",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Prompt template ID",
+                        "name": "prompt_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Rendering parameters",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.RenderPromptRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.RenderPromptResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/prompts/{prompt_id}/test": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Render the prompt template once per test case and check the result against the case's expectations:\nexact prompt/messages (reported with a line diff), contains, not_contains, regex and token_limit.\nTool calls are served from each case's mocks, and replayed from its fixture if one is given",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Prompts"
+                ],
+                "summary": "Run prompt regression tests",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Prompt template ID",
+                        "name": "prompt_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Test cases",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/service.TestSuite"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/service.TestReport"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/secrets": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get the IDs of all secrets. Values of secrets are never returned",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Secrets"
+                ],
+                "summary": "List secrets",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/secrets/{secret_id}": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Encrypt and store a secret, creating it if it doesn't exist.\nTools refer to it as ${secret:secret_id} in their headers",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Secrets"
+                ],
+                "summary": "Set secret",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Secret ID",
+                        "name": "secret_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Secret value, a JSON string",
+                        "name": "value",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Delete specified secret",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Secrets"
+                ],
+                "summary": "Delete secret",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Secret ID",
+                        "name": "secret_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/sessions": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Render a prompt and start a multi-turn chat whose history begins with the rendered messages.\nscope selects the overrides of shared variables, as for chats. The model and sampling parameters apply to every turn.\nSessions expire after the configured TTL without messages",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Sessions"
+                ],
+                "summary": "Create session",
+                "parameters": [
+                    {
+                        "description": "Prompt, args and chat parameters",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/service.CreateSessionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/service.ChatSession"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/sessions/{session_id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get a session with its history. Sessions are found by their creator and admins only",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Sessions"
+                ],
+                "summary": "Get session",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Session ID",
+                        "name": "session_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/service.ChatSession"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "End a session before it expires",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Sessions"
+                ],
+                "summary": "Delete session",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Session ID",
+                        "name": "session_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/sessions/{session_id}/messages": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Append a user turn to a session and get it answered by the LLM; both are added to the history.\nAn empty content gets the last user message of the history answered, such as that of the prompt.\nHistories outgrowing the model context are trimmed or summarized. Turns are limited by quotas as chats, reported in X-RateLimit-* headers",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Sessions"
+                ],
+                "summary": "Send session message",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Session ID",
+                        "name": "session_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "User message",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/service.SessionMessageRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/service.ChatResponse"
+                        },
+                        "headers": {
+                            "X-RateLimit-Remaining-Requests": {
+                                "type": "integer",
+                                "description": "Requests remaining in the quota closest to being exhausted"
+                            },
+                            "X-RateLimit-Remaining-Tokens": {
+                                "type": "integer",
+                                "description": "Tokens remaining in the quota closest to being exhausted"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/sync": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get the repository synced, the published commit (snapshot) with the items it published,\nand the last commit fetched with the problems that made it refused, if any",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Sync"
+                ],
+                "summary": "Get git sync status",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/service.SyncStatus"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Fetch the latest commit of the configured repository, validate it and publish it, without waiting for the sync interval.\nA commit with problems is refused and the published items stay as they were; the response data holds the sync status with the problems found",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Sync"
+                ],
+                "summary": "Sync git repository now",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/service.SyncStatus"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/tools": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get available tools in the system, sorted by ID. Returns IDs by default, or summaries if summary=true",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Tools"
+                ],
+                "summary": "List all tools",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only tools supporting this scene, e.g. chat",
+                        "name": "supports",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Free-text search over ID, name and description",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number, starting from 1",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size, 0 means no pagination",
+                        "name": "page_size",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Return summaries instead of IDs",
+                        "name": "summary",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Tool IDs, or service.ToolSummary objects if summary=true",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "string"
+                            }
+                        },
+                        "headers": {
+                            "X-Total-Count": {
+                                "type": "int",
+                                "description": "Number of matching tools before pagination"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/tools/{tool_id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get detailed information about specified tool",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Tools"
+                ],
+                "summary": "Get tool details",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "工具ID",
+                        "name": "tool_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dao.Tool"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/tools/{tool_id}/call": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Call specified tool with given args, as a template would, and return its result",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Tools"
+                ],
+                "summary": "Call tool",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Tool ID",
+                        "name": "tool_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Tool arguments",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.CallToolRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.CallToolResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    }
+                }
+            }
+        },
+        "/metrics": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get service metrics, such as guard verdicts, in the Prometheus text format",
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "Metrics"
+                ],
+                "summary": "Get metrics",
+                "responses": {
+                    "200": {
+                        "description": "Metrics",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.ResponseData"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "api.CallToolRequest": {
+            "type": "object",
+            "properties": {
+                "args": {
+                    "type": "array",
+                    "items": {}
+                }
+            }
+        },
+        "api.CallToolResponse": {
+            "type": "object",
+            "properties": {
+                "result": {}
+            }
+        },
+        "api.RenderPromptRequest": {
+            "type": "object",
+            "properties": {
+                "args": {
+                    "type": "object",
+                    "additionalProperties": true
+                },
+                "fixture": {
+                    "type": "string"
+                },
+                "max_tokens": {
+                    "type": "integer"
+                },
+                "mocks": {
+                    "type": "object",
+                    "additionalProperties": true
+                },
+                "model": {
+                    "type": "string"
+                },
+                "scope": {
+                    "$ref": "#/definitions/dao.EnvScope"
+                },
+                "tool_mode": {
+                    "type": "string",
+                    "enum": [
+                        "record",
+                        "replay"
+                    ]
+                }
+            }
+        },
+        "api.RenderPromptResponse": {
+            "type": "object",
+            "properties": {
+                "kind": {
+                    "type": "string"
+                },
+                "messages": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dao.Message"
+                    }
+                },
+                "prompt": {
+                    "type": "string"
+                },
+                "tokens": {
+                    "$ref": "#/definitions/service.TokenCounts"
+                }
+            }
+        },
+        "api.ResponseData": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "data": {},
+                "message": {
+                    "type": "string"
+                },
+                "success": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "dao.ACL": {
+            "type": "object",
+            "properties": {
+                "groups": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "roles": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "users": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "dao.Budget": {
+            "type": "object",
+            "properties": {
+                "maxInputTokens": {
+                    "type": "integer"
+                },
+                "truncatable": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "dao.CachePolicy": {
+            "type": "object",
+            "properties": {
+                "force": {
+                    "type": "boolean"
+                },
+                "similarity": {
+                    "type": "number"
+                },
+                "ttl": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dao.Contributes": {
+            "type": "object",
+            "properties": {
+                "dependences": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dao.Dependence"
+                    }
+                },
+                "languages": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "prompts": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dao.Prompt"
+                    }
+                }
+            }
+        },
+        "dao.Dependence": {
+            "type": "object",
+            "properties": {
+                "failStrategy": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "string"
+                }
+            }
+        },
+        "dao.Engines": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "string"
+                }
+            }
+        },
+        "dao.EnvScope": {
+            "type": "object",
+            "properties": {
+                "project": {
+                    "type": "string"
+                },
+                "tenant": {
+                    "type": "string"
+                },
+                "user": {
+                    "type": "string"
+                }
+            }
+        },
+        "dao.Grpc": {
+            "type": "object",
+            "properties": {
+                "headers": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "method": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "dao.GuardPolicy": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string"
+                },
+                "detectors": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "dao.Message": {
+            "type": "object",
+            "properties": {
+                "content": {
+                    "type": "string"
+                },
+                "role": {
+                    "type": "string"
+                }
+            }
+        },
+        "dao.Partial": {
+            "type": "object",
+            "properties": {
+                "content": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                }
+            }
+        },
+        "dao.Prompt": {
+            "type": "object",
+            "properties": {
+                "acl": {
+                    "$ref": "#/definitions/dao.ACL"
+                },
+                "budget": {
+                    "$ref": "#/definitions/dao.Budget"
+                },
+                "cache": {
+                    "$ref": "#/definitions/dao.CachePolicy"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "guard": {
+                    "$ref": "#/definitions/dao.GuardPolicy"
+                },
+                "messages": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dao.Message"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                },
+                "parameters": {
+                    "type": "object",
+                    "additionalProperties": true
+                },
+                "prompt": {
+                    "type": "string"
+                },
+                "redact": {
+                    "$ref": "#/definitions/dao.RedactPolicy"
+                },
+                "returns": {
+                    "type": "object",
+                    "additionalProperties": true
+                },
+                "strict": {
+                    "type": "boolean"
+                },
+                "supports": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "dao.PromptExtension": {
+            "type": "object",
+            "properties": {
+                "contributes": {
+                    "$ref": "#/definitions/dao.Contributes"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "displayName": {
+                    "type": "string"
+                },
+                "enabled": {
+                    "type": "boolean"
+                },
+                "engines": {
+                    "$ref": "#/definitions/dao.Engines"
+                },
+                "extensionType": {
+                    "type": "string"
+                },
+                "icon": {
+                    "type": "string"
+                },
+                "license": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "publisher": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "string"
+                }
+            }
+        },
+        "dao.RedactPolicy": {
+            "type": "object",
+            "properties": {
+                "detectors": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "mode": {
+                    "type": "string"
+                }
+            }
+        },
+        "dao.Restful": {
+            "type": "object",
+            "properties": {
+                "headers": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "method": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "dao.Tool": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "examples": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "grpc": {
+                    "$ref": "#/definitions/dao.Grpc"
+                },
+                "module": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "parameters": {
+                    "type": "object",
+                    "additionalProperties": true
+                },
+                "restful": {
+                    "$ref": "#/definitions/dao.Restful"
+                },
+                "returns": {
+                    "type": "object",
+                    "additionalProperties": true
+                },
+                "supports": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "guard.Finding": {
+            "type": "object",
+            "properties": {
+                "detector": {
+                    "type": "string"
+                },
+                "excerpt": {
+                    "type": "string"
+                },
+                "rule": {
+                    "type": "string"
+                },
+                "source": {
+                    "type": "string"
+                }
+            }
+        },
+        "service.AuditPayload": {
+            "type": "object",
+            "properties": {
+                "answers": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "messages": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dao.Message"
+                    }
+                }
+            }
+        },
+        "service.AuditRecord": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string",
+                    "enum": [
+                        "create",
+                        "update",
+                        "delete"
+                    ]
+                },
+                "actor": {
+                    "type": "string"
+                },
+                "cached": {
+                    "type": "boolean"
+                },
+                "diff": {
+                    "type": "string"
+                },
+                "duration_ms": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "kind": {
+                    "type": "string"
+                },
+                "model": {
+                    "type": "string"
+                },
+                "payload": {
+                    "$ref": "#/definitions/service.AuditPayload"
+                },
+                "prompt": {
+                    "type": "string"
+                },
+                "scope": {
+                    "$ref": "#/definitions/dao.EnvScope"
+                },
+                "session": {
+                    "type": "string"
+                },
+                "target": {
+                    "type": "string"
+                },
+                "time": {
+                    "type": "string"
+                },
+                "trace_id": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string",
+                    "enum": [
+                        "change",
+                        "chat"
+                    ]
+                },
+                "usage": {
+                    "$ref": "#/definitions/service.AuditUsage"
+                },
+                "version": {
+                    "type": "string"
+                }
+            }
+        },
+        "service.AuditUsage": {
+            "type": "object",
+            "properties": {
+                "completion_tokens": {
+                    "type": "integer"
+                },
+                "prompt_tokens": {
+                    "type": "integer"
+                },
+                "total_tokens": {
+                    "type": "integer"
+                }
+            }
+        },
+        "service.Bundle": {
+            "type": "object",
+            "properties": {
+                "environs": {
+                    "type": "object",
+                    "additionalProperties": true
+                },
+                "extensions": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/dao.PromptExtension"
+                    }
+                },
+                "kinds": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "partials": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/dao.Partial"
+                    }
+                },
+                "prompts": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/dao.Prompt"
+                    }
+                },
+                "scopes": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "object",
+                        "additionalProperties": {}
+                    }
+                },
+                "secrets": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "tools": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/dao.Tool"
+                    }
+                },
+                "version": {
+                    "type": "integer"
+                }
+            }
+        },
+        "service.BundleChange": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string",
+                    "enum": [
+                        "create",
+                        "update",
+                        "delete"
+                    ]
+                },
+                "id": {
+                    "type": "string"
+                },
+                "kind": {
+                    "type": "string"
+                }
+            }
+        },
+        "service.CacheStats": {
+            "type": "object",
+            "properties": {
+                "l1_entries": {
+                    "type": "integer"
+                },
+                "l1_hits": {
+                    "type": "integer"
+                },
+                "l2_hits": {
+                    "type": "integer"
+                },
+                "misses": {
+                    "type": "integer"
+                },
+                "semantic_entries": {
+                    "type": "integer"
+                },
+                "semantic_hits": {
+                    "type": "integer"
+                },
+                "stores": {
+                    "type": "integer"
+                }
+            }
+        },
+        "service.CacheStatus": {
+            "type": "object",
+            "properties": {
+                "age": {
+                    "description": "seconds since the response was cached",
+                    "type": "integer"
+                },
+                "key": {
+                    "type": "string"
+                },
+                "level": {
+                    "type": "string",
+                    "enum": [
+                        "l1",
+                        "l2",
+                        "semantic"
+                    ]
+                },
+                "similarity": {
+                    "description": "of the chat answered, for semantic hits",
+                    "type": "number"
+                },
+                "stats": {
+                    "$ref": "#/definitions/service.CacheStats"
+                },
+                "ttl": {
+                    "description": "seconds until it expires",
+                    "type": "integer"
+                }
+            }
+        },
+        "service.ChatPromptRequest": {
+            "type": "object",
+            "properties": {
+                "args": {
+                    "type": "object",
+                    "additionalProperties": true
+                },
+                "frequency_penalty": {
+                    "type": "number"
+                },
+                "max_tokens": {
+                    "type": "integer"
+                },
+                "model": {
+                    "type": "string"
+                },
+                "n": {
+                    "type": "integer"
+                },
+                "no_cache": {
+                    "description": "skip the response cache lookup; the response still refreshes it",
+                    "type": "boolean"
+                },
+                "presence_penalty": {
+                    "type": "number"
+                },
+                "scope": {
+                    "$ref": "#/definitions/dao.EnvScope"
+                },
+                "stop": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "stream": {
+                    "type": "boolean"
+                },
+                "temperature": {
+                    "type": "number"
+                },
+                "top_p": {
+                    "type": "number"
+                },
+                "user": {
+                    "type": "string"
+                }
+            }
+        },
+        "service.ChatResponse": {
+            "type": "object",
+            "properties": {
+                "cache": {
+                    "description": "set by ChatWithPrompt for prompts opting in to the cache",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/service.CacheStatus"
+                        }
+                    ]
+                },
+                "cached": {
+                    "description": "set by ChatWithPrompt for responses from the cache",
+                    "type": "boolean"
+                },
+                "choices": {
+                    "type": "array",
+                    "items": {
+                        "type": "object",
+                        "properties": {
+                            "finish_reason": {
+                                "type": "string"
+                            },
+                            "index": {
+                                "type": "integer"
+                            },
+                            "logprobs": {
+                                "type": "object"
+                            },
+                            "message": {
+                                "type": "object",
+                                "properties": {
+                                    "content": {
+                                        "type": "string"
+                                    },
+                                    "role": {
+                                        "type": "string"
+                                    }
+                                }
+                            }
+                        }
+                    }
+                },
+                "created": {
+                    "type": "integer"
+                },
+                "guard": {
+                    "description": "set by ChatWithPrompt for guarded prompts",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/service.GuardVerdict"
+                        }
+                    ]
+                },
+                "id": {
+                    "type": "string"
+                },
+                "model": {
+                    "type": "string"
+                },
+                "object": {
+                    "type": "string"
+                },
+                "redaction": {
+                    "description": "set by ChatWithPrompt for redacted prompts",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/service.RedactReport"
+                        }
+                    ]
+                },
+                "session": {
+                    "description": "set by SendSessionMessage",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/service.SessionTurn"
+                        }
+                    ]
+                },
+                "usage": {
+                    "type": "object",
+                    "properties": {
+                        "completion_tokens": {
+                            "type": "integer"
+                        },
+                        "prompt_tokens": {
+                            "type": "integer"
+                        },
+                        "total_tokens": {
+                            "type": "integer"
+                        }
+                    }
+                }
+            }
+        },
+        "service.ChatSession": {
+            "type": "object",
+            "properties": {
+                "busy_until": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "dropped": {
+                    "type": "integer"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "frequency_penalty": {
+                    "type": "number"
+                },
+                "guard": {
+                    "description": "of the rendered prompt, reported when the session is created",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/service.GuardVerdict"
+                        }
+                    ]
+                },
+                "id": {
+                    "type": "string"
+                },
+                "max_tokens": {
+                    "type": "integer"
+                },
+                "messages": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dao.Message"
+                    }
+                },
+                "model": {
+                    "type": "string"
+                },
+                "owner": {
+                    "type": "string"
+                },
+                "pinned": {
+                    "type": "integer"
+                },
+                "presence_penalty": {
+                    "type": "number"
+                },
+                "prompt_id": {
+                    "type": "string"
+                },
+                "stop": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "summary": {
+                    "type": "string"
+                },
+                "temperature": {
+                    "type": "number"
+                },
+                "top_p": {
+                    "type": "number"
+                },
+                "total_tokens": {
+                    "type": "integer"
+                },
+                "turns": {
+                    "type": "integer"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "user": {
+                    "type": "string"
+                },
+                "version": {
+                    "description": "of the prompt when the session was created, as audited",
+                    "type": "string"
                 }
             }
         },
-        "dao.Contributes": {
+        "service.CreateSessionRequest": {
             "type": "object",
+            "required": [
+                "prompt_id"
+            ],
             "properties": {
-                "dependences": {
+                "args": {
+                    "type": "object",
+                    "additionalProperties": true
+                },
+                "frequency_penalty": {
+                    "type": "number"
+                },
+                "max_tokens": {
+                    "type": "integer"
+                },
+                "model": {
+                    "type": "string"
+                },
+                "presence_penalty": {
+                    "type": "number"
+                },
+                "prompt_id": {
+                    "type": "string"
+                },
+                "scope": {
+                    "$ref": "#/definitions/dao.EnvScope"
+                },
+                "stop": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "temperature": {
+                    "type": "number"
+                },
+                "top_p": {
+                    "type": "number"
+                },
+                "user": {
+                    "type": "string"
+                }
+            }
+        },
+        "service.EvalReport": {
+            "type": "object",
+            "properties": {
+                "best": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/service.EvalResult"
+                    }
+                },
+                "scorers": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "variants": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/service.EvalVariant"
+                    }
+                }
+            }
+        },
+        "service.EvalRequest": {
+            "type": "object",
+            "properties": {
+                "concurrency": {
+                    "type": "integer"
+                },
+                "dataset": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/service.EvalSample"
+                    }
+                },
+                "judge_max_score": {
+                    "type": "number"
+                },
+                "judge_model": {
+                    "type": "string"
+                },
+                "judge_prompt": {
+                    "type": "string"
+                },
+                "max_tokens": {
+                    "type": "integer"
+                },
+                "models": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "prompts": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "scorers": {
+                    "type": "array",
+                    "items": {
+                        "type": "string",
+                        "enum": [
+                            "exact",
+                            "schema",
+                            "judge"
+                        ]
+                    }
+                },
+                "temperature": {
+                    "type": "number"
+                }
+            }
+        },
+        "service.EvalResult": {
+            "type": "object",
+            "properties": {
+                "details": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "error": {
+                    "type": "string"
+                },
+                "latency_ms": {
+                    "type": "integer"
+                },
+                "model": {
+                    "type": "string"
+                },
+                "output": {
+                    "type": "string"
+                },
+                "prompt": {
+                    "type": "string"
+                },
+                "sample": {
+                    "type": "string"
+                },
+                "scores": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "number"
+                    }
+                },
+                "tokens": {
+                    "type": "integer"
+                }
+            }
+        },
+        "service.EvalSample": {
+            "type": "object",
+            "properties": {
+                "args": {
+                    "type": "object",
+                    "additionalProperties": true
+                },
+                "expected": {},
+                "id": {
+                    "type": "string"
+                }
+            }
+        },
+        "service.EvalVariant": {
+            "type": "object",
+            "properties": {
+                "avg_latency_ms": {
+                    "type": "integer"
+                },
+                "errors": {
+                    "type": "integer"
+                },
+                "model": {
+                    "type": "string"
+                },
+                "prompt": {
+                    "type": "string"
+                },
+                "samples": {
+                    "type": "integer"
+                },
+                "scores": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "number"
+                    }
+                },
+                "tokens": {
+                    "type": "integer"
+                }
+            }
+        },
+        "service.GuardVerdict": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string"
+                },
+                "errors": {
+                    "description": "failed detectors; the content they checked isn't flagged by them",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "findings": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/guard.Finding"
+                    }
+                },
+                "outcome": {
+                    "type": "string",
+                    "enum": [
+                        "passed",
+                        "flagged",
+                        "sanitized",
+                        "blocked"
+                    ]
+                }
+            }
+        },
+        "service.ImportResult": {
+            "type": "object",
+            "properties": {
+                "changes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/service.BundleChange"
+                    }
+                },
+                "created": {
+                    "type": "integer"
+                },
+                "deleted": {
+                    "type": "integer"
+                },
+                "dry_run": {
+                    "type": "boolean"
+                },
+                "kinds": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "mode": {
+                    "type": "string"
+                },
+                "unchanged": {
+                    "type": "integer"
+                },
+                "updated": {
+                    "type": "integer"
+                }
+            }
+        },
+        "service.RedactReport": {
+            "type": "object",
+            "properties": {
+                "counts": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "mode": {
+                    "type": "string",
+                    "enum": [
+                        "placeholder",
+                        "mask"
+                    ]
+                }
+            }
+        },
+        "service.SessionMessageRequest": {
+            "type": "object",
+            "properties": {
+                "content": {
+                    "type": "string"
+                }
+            }
+        },
+        "service.SessionTurn": {
+            "type": "object",
+            "properties": {
+                "dropped": {
+                    "description": "messages dropped from the history so far",
+                    "type": "integer"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "summarized": {
+                    "description": "the history was summarized by this turn",
+                    "type": "boolean"
+                },
+                "turns": {
+                    "type": "integer"
+                }
+            }
+        },
+        "service.SyncIssue": {
+            "type": "object",
+            "properties": {
+                "column": {
+                    "type": "integer"
+                },
+                "field": {
+                    "type": "string"
+                },
+                "file": {
+                    "type": "string"
+                },
+                "kind": {
+                    "type": "string"
+                },
+                "line": {
+                    "type": "integer"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "service.SyncSnapshot": {
+            "type": "object",
+            "properties": {
+                "branch": {
+                    "type": "string"
+                },
+                "extensions": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/dao.Dependence"
+                        "type": "string"
                     }
                 },
-                "languages": {
+                "partials": {
                     "type": "array",
                     "items": {
                         "type": "string"
@@ -424,63 +3368,111 @@ const docTemplate = `{
                 "prompts": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/dao.Prompt"
+                        "type": "string"
                     }
-                }
-            }
-        },
-        "dao.Dependence": {
-            "type": "object",
-            "properties": {
-                "failStrategy": {
+                },
+                "published_at": {
                     "type": "string"
                 },
-                "name": {
+                "repo": {
                     "type": "string"
                 },
+                "tools": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
                 "version": {
                     "type": "string"
                 }
             }
         },
-        "dao.Engines": {
+        "service.SyncStatus": {
             "type": "object",
             "properties": {
-                "name": {
+                "branch": {
                     "type": "string"
                 },
-                "version": {
+                "enabled": {
+                    "type": "boolean"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "issues": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/service.SyncIssue"
+                    }
+                },
+                "last_commit": {
                     "type": "string"
+                },
+                "last_sync_at": {
+                    "type": "string"
+                },
+                "repo": {
+                    "type": "string"
+                },
+                "snapshot": {
+                    "$ref": "#/definitions/service.SyncSnapshot"
                 }
             }
         },
-        "dao.Grpc": {
+        "service.TestCase": {
             "type": "object",
             "properties": {
-                "method": {
+                "args": {
+                    "type": "object",
+                    "additionalProperties": true
+                },
+                "expect": {
+                    "$ref": "#/definitions/service.TestExpect"
+                },
+                "fixture": {
                     "type": "string"
                 },
-                "url": {
+                "mocks": {
+                    "type": "object",
+                    "additionalProperties": true
+                },
+                "name": {
                     "type": "string"
                 }
             }
         },
-        "dao.Message": {
+        "service.TestCaseResult": {
             "type": "object",
             "properties": {
-                "content": {
+                "diff": {
                     "type": "string"
                 },
-                "role": {
+                "failures": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "name": {
                     "type": "string"
+                },
+                "passed": {
+                    "type": "boolean"
+                },
+                "tokens": {
+                    "type": "integer"
                 }
             }
         },
-        "dao.Prompt": {
+        "service.TestExpect": {
             "type": "object",
             "properties": {
-                "description": {
-                    "type": "string"
+                "contains": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
                 },
                 "messages": {
                     "type": "array",
@@ -488,92 +3480,98 @@ const docTemplate = `{
                         "$ref": "#/definitions/dao.Message"
                     }
                 },
-                "name": {
-                    "type": "string"
-                },
-                "parameters": {
-                    "type": "object",
-                    "additionalProperties": true
+                "not_contains": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
                 },
                 "prompt": {
                     "type": "string"
                 },
-                "returns": {
-                    "type": "object",
-                    "additionalProperties": true
-                },
-                "supports": {
+                "regex": {
                     "type": "array",
                     "items": {
                         "type": "string"
                     }
+                },
+                "token_limit": {
+                    "type": "integer"
                 }
             }
         },
-        "dao.PromptExtension": {
+        "service.TestReport": {
             "type": "object",
             "properties": {
-                "contributes": {
-                    "$ref": "#/definitions/dao.Contributes"
-                },
-                "description": {
-                    "type": "string"
-                },
-                "displayName": {
-                    "type": "string"
-                },
-                "engines": {
-                    "$ref": "#/definitions/dao.Engines"
-                },
-                "extensionType": {
-                    "type": "string"
-                },
-                "icon": {
-                    "type": "string"
+                "cases": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/service.TestCaseResult"
+                    }
                 },
-                "license": {
-                    "type": "string"
+                "failed": {
+                    "type": "integer"
                 },
-                "name": {
-                    "type": "string"
+                "passed": {
+                    "type": "integer"
                 },
-                "publisher": {
+                "prompt": {
                     "type": "string"
+                }
+            }
+        },
+        "service.TestSuite": {
+            "type": "object",
+            "properties": {
+                "cases": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/service.TestCase"
+                    }
                 },
-                "version": {
+                "prompt": {
                     "type": "string"
                 }
             }
         },
-        "dao.Restful": {
+        "service.TokenCounts": {
             "type": "object",
             "properties": {
-                "method": {
-                    "type": "string"
+                "messages": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
                 },
-                "url": {
-                    "type": "string"
+                "total": {
+                    "type": "integer"
                 }
             }
         },
-        "dao.Tool": {
+        "service.ValidatePromptRequest": {
             "type": "object",
             "properties": {
+                "acl": {
+                    "$ref": "#/definitions/dao.ACL"
+                },
+                "budget": {
+                    "$ref": "#/definitions/dao.Budget"
+                },
+                "cache": {
+                    "$ref": "#/definitions/dao.CachePolicy"
+                },
                 "description": {
                     "type": "string"
                 },
-                "examples": {
+                "guard": {
+                    "$ref": "#/definitions/dao.GuardPolicy"
+                },
+                "messages": {
                     "type": "array",
                     "items": {
-                        "type": "string"
+                        "$ref": "#/definitions/dao.Message"
                     }
                 },
-                "grpc": {
-                    "$ref": "#/definitions/dao.Grpc"
-                },
-                "module": {
-                    "type": "string"
-                },
                 "name": {
                     "type": "string"
                 },
@@ -581,125 +3579,92 @@ const docTemplate = `{
                     "type": "object",
                     "additionalProperties": true
                 },
-                "restful": {
-                    "$ref": "#/definitions/dao.Restful"
+                "prompt": {
+                    "type": "string"
+                },
+                "redact": {
+                    "$ref": "#/definitions/dao.RedactPolicy"
                 },
                 "returns": {
                     "type": "object",
                     "additionalProperties": true
                 },
+                "sample_args": {
+                    "type": "object",
+                    "additionalProperties": true
+                },
+                "scope": {
+                    "$ref": "#/definitions/dao.EnvScope"
+                },
+                "strict": {
+                    "type": "boolean"
+                },
                 "supports": {
                     "type": "array",
                     "items": {
                         "type": "string"
                     }
-                },
-                "type": {
-                    "type": "string"
                 }
             }
         },
-        "service.ChatPromptRequest": {
+        "service.ValidatePromptResult": {
             "type": "object",
             "properties": {
-                "args": {
-                    "type": "object",
-                    "additionalProperties": true
-                },
-                "frequency_penalty": {
-                    "type": "number"
-                },
-                "max_tokens": {
-                    "type": "integer"
+                "issues": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/service.ValidationIssue"
+                    }
                 },
-                "model": {
+                "kind": {
                     "type": "string"
                 },
-                "n": {
-                    "type": "integer"
-                },
-                "presence_penalty": {
-                    "type": "number"
-                },
-                "stop": {
+                "messages": {
                     "type": "array",
                     "items": {
-                        "type": "string"
+                        "$ref": "#/definitions/dao.Message"
                     }
                 },
-                "stream": {
-                    "type": "boolean"
-                },
-                "temperature": {
-                    "type": "number"
-                },
-                "top_p": {
-                    "type": "number"
-                },
-                "user": {
+                "prompt": {
                     "type": "string"
+                },
+                "valid": {
+                    "type": "boolean"
                 }
             }
         },
-        "service.ChatResponse": {
+        "service.ValidationIssue": {
             "type": "object",
             "properties": {
-                "choices": {
-                    "type": "array",
-                    "items": {
-                        "type": "object",
-                        "properties": {
-                            "finish_reason": {
-                                "type": "string"
-                            },
-                            "index": {
-                                "type": "integer"
-                            },
-                            "logprobs": {
-                                "type": "object"
-                            },
-                            "message": {
-                                "type": "object",
-                                "properties": {
-                                    "content": {
-                                        "type": "string"
-                                    },
-                                    "role": {
-                                        "type": "string"
-                                    }
-                                }
-                            }
-                        }
-                    }
-                },
-                "created": {
+                "column": {
                     "type": "integer"
                 },
-                "id": {
+                "field": {
                     "type": "string"
                 },
-                "model": {
+                "kind": {
                     "type": "string"
                 },
-                "object": {
-                    "type": "string"
+                "line": {
+                    "type": "integer"
                 },
-                "usage": {
-                    "type": "object",
-                    "properties": {
-                        "completion_tokens": {
-                            "type": "integer"
-                        },
-                        "prompt_tokens": {
-                            "type": "integer"
-                        },
-                        "total_tokens": {
-                            "type": "integer"
-                        }
-                    }
+                "message": {
+                    "type": "string"
                 }
             }
         }
+    },
+    "securityDefinitions": {
+        "ApiKeyAuth": {
+            "type": "apiKey",
+            "name": "X-API-Key",
+            "in": "header"
+        },
+        "BearerAuth": {
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
+        }
     }
 }`
 
@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/zgsm-ai/ai-prompt-shell/internal/config"
+)
+
+/**
+ * Authenticates requests by static API keys, given as a bearer token or in X-API-Key
+ */
+type apiKeyAuth struct {
+	keys []apiKey
+}
+
+type apiKey struct {
+	hash     [sha256.Size]byte
+	identity Identity
+}
+
+func newAPIKeyAuth(c *config.AuthConfig) (*apiKeyAuth, error) {
+	a := &apiKeyAuth{}
+	for i, k := range c.APIKeys {
+		var key apiKey
+		if hexHash, ok := strings.CutPrefix(k.Key, "sha256:"); ok {
+			data, err := hex.DecodeString(hexHash)
+			if err != nil || len(data) != sha256.Size {
+				return nil, fmt.Errorf("auth.api_keys[%d]: invalid sha256 hash", i)
+			}
+			copy(key.hash[:], data)
+		} else if k.Key != "" {
+			key.hash = sha256.Sum256([]byte(k.Key))
+		} else {
+			return nil, fmt.Errorf("auth.api_keys[%d]: key is empty", i)
+		}
+		if k.Subject == "" {
+			return nil, fmt.Errorf("auth.api_keys[%d]: subject is empty", i)
+		}
+		key.identity = Identity{
+			Subject: k.Subject,
+			Roles:   withDefaultRole(k.Roles, c.DefaultRole),
+			Groups:  k.Groups,
+			Tenant:  k.Tenant,
+			Method:  "apikey",
+		}
+		a.keys = append(a.keys, key)
+	}
+	if len(a.keys) == 0 {
+		return nil, fmt.Errorf("auth mode apikey needs auth.api_keys")
+	}
+	return a, nil
+}
+
+func (a *apiKeyAuth) Authenticate(r *http.Request) (*Identity, error) {
+	token := r.Header.Get("X-API-Key")
+	bearer := token == ""
+	if bearer {
+		token = bearerToken(r)
+	}
+	if token == "" {
+		return nil, ErrNoCredentials
+	}
+	hash := sha256.Sum256([]byte(token))
+	for _, k := range a.keys {
+		if subtle.ConstantTimeCompare(hash[:], k.hash[:]) == 1 {
+			id := k.identity
+			return &id, nil
+		}
+	}
+	if bearer && strings.Count(token, ".") == 2 {
+		// Looks like a JWT, leave the error to the jwt authenticator
+		return nil, ErrNoCredentials
+	}
+	return nil, errors.New("invalid API key")
+}
@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/zgsm-ai/ai-prompt-shell/internal/config"
+)
+
+// The request carries no credentials for an authenticator
+var ErrNoCredentials = errors.New("authentication required")
+
+/**
+ * Authenticates API requests
+ */
+type Authenticator interface {
+	/**
+	 * Get the caller of a request
+	 * @return identity, ErrNoCredentials if the request carries no credentials of this kind,
+	 *         another error if they are invalid
+	 */
+	Authenticate(r *http.Request) (*Identity, error)
+}
+
+/**
+ * Create the authenticators enabled by the configuration
+ * @return authenticator trying each enabled mode in turn, nil if auth is disabled
+ * @return error if a mode is unknown or its configuration is invalid
+ */
+func New(c *config.AuthConfig) (Authenticator, error) {
+	if len(c.Modes) == 0 {
+		return nil, nil
+	}
+	if !ValidRole(c.DefaultRole) {
+		return nil, fmt.Errorf("auth.default_role: unknown role %s", c.DefaultRole)
+	}
+	var authenticators chain
+	for _, mode := range c.Modes {
+		var a Authenticator
+		var err error
+		switch mode {
+		case "apikey":
+			a, err = newAPIKeyAuth(c)
+		case "jwt":
+			a, err = newJWTAuth(c)
+		case "gateway":
+			a, err = newGatewayAuth(c)
+		default:
+			err = fmt.Errorf("unknown auth mode: %s", mode)
+		}
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, a)
+	}
+	return authenticators, nil
+}
+
+/**
+ * Authenticators tried in turn; the first to accept the request wins
+ */
+type chain []Authenticator
+
+func (c chain) Authenticate(r *http.Request) (*Identity, error) {
+	var failure error
+	for _, a := range c {
+		id, err := a.Authenticate(r)
+		if err == nil {
+			return id, nil
+		}
+		if failure == nil && err != ErrNoCredentials {
+			failure = err
+		}
+	}
+	if failure != nil {
+		return nil, failure
+	}
+	return nil, ErrNoCredentials
+}
+
+/**
+ * Get the bearer token of a request
+ * @return token from "Authorization: Bearer", empty if none
+ */
+func bearerToken(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+/**
+ * Give callers without roles the default role
+ */
+func withDefaultRole(roles []string, defaultRole string) []string {
+	if len(roles) == 0 {
+		return []string{defaultRole}
+	}
+	return roles
+}
+
+/**
+ * Split a list given as one string, separated by commas or spaces
+ */
+func splitList(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' })
+}
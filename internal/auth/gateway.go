@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/zgsm-ai/ai-prompt-shell/internal/config"
+)
+
+/**
+ * Trusts the caller passed in headers by an upstream gateway that authenticated it
+ */
+type gatewayAuth struct {
+	cfg         config.GatewayConfig
+	defaultRole string
+	trusted     []*net.IPNet
+}
+
+func newGatewayAuth(c *config.AuthConfig) (*gatewayAuth, error) {
+	a := &gatewayAuth{cfg: c.Gateway, defaultRole: c.DefaultRole}
+	for _, p := range c.Gateway.TrustedProxies {
+		if !strings.Contains(p, "/") {
+			if ip := net.ParseIP(p); ip != nil && ip.To4() != nil {
+				p += "/32"
+			} else {
+				p += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(p)
+		if err != nil {
+			return nil, fmt.Errorf("auth.gateway.trusted_proxies: invalid address %s", p)
+		}
+		a.trusted = append(a.trusted, network)
+	}
+	if len(a.trusted) == 0 {
+		// Anyone could claim any user and role otherwise
+		return nil, errors.New("auth.gateway.trusted_proxies is required by the gateway mode")
+	}
+	return a, nil
+}
+
+func (a *gatewayAuth) Authenticate(r *http.Request) (*Identity, error) {
+	user := r.Header.Get(a.cfg.UserHeader)
+	if user == "" {
+		return nil, ErrNoCredentials
+	}
+	if !a.fromTrustedProxy(r) {
+		return nil, errors.New("identity headers from an untrusted client")
+	}
+	return &Identity{
+		Subject: user,
+		Roles:   withDefaultRole(splitList(r.Header.Get(a.cfg.RolesHeader)), a.defaultRole),
+		Groups:  splitList(r.Header.Get(a.cfg.GroupsHeader)),
+		Tenant:  r.Header.Get(a.cfg.TenantHeader),
+		Method:  "gateway",
+	}, nil
+}
+
+/**
+ * Check whether the request comes directly from a trusted proxy
+ */
+func (a *gatewayAuth) fromTrustedProxy(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, network := range a.trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
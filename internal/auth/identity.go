@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"context"
+	"slices"
+)
+
+// Roles, each granting everything the previous ones do
+const (
+	RoleReader    = "reader"    // read prompts, tools, partials, extensions and shared variables
+	RoleRenderer  = "renderer"  // also render prompts, chat, call tools, test and evaluate
+	RolePublisher = "publisher" // also install extensions, set shared variables, import and sync
+	RoleAdmin     = "admin"     // also manage secrets, export and query the audit log; sees every prompt
+)
+
+var roleLevels = map[string]int{
+	RoleReader:    1,
+	RoleRenderer:  2,
+	RolePublisher: 3,
+	RoleAdmin:     4,
+}
+
+/**
+ * Check whether a role name is one of the built-in roles
+ */
+func ValidRole(role string) bool {
+	_, ok := roleLevels[role]
+	return ok
+}
+
+/**
+ * Authenticated caller of the API
+ * @description
+ * - Roles may hold names other than the built-in roles; they grant nothing, but prompt ACLs can refer to them
+ * - Tenant is the tenant scope of the caller, replacing X-Tenant-Id; empty if the caller has none
+ */
+type Identity struct {
+	Subject string   `json:"subject"`
+	Roles   []string `json:"roles"`
+	Groups  []string `json:"groups,omitempty"`
+	Tenant  string   `json:"tenant,omitempty"`
+	Method  string   `json:"method"` // apikey, jwt or gateway
+}
+
+/**
+ * Check whether the identity has a role, or a higher built-in one
+ */
+func (id *Identity) HasRole(role string) bool {
+	level, builtin := roleLevels[role]
+	for _, r := range id.Roles {
+		if r == role || (builtin && roleLevels[r] >= level) {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ * Check whether the identity is a member of a group
+ */
+func (id *Identity) InGroup(group string) bool {
+	return slices.Contains(id.Groups, group)
+}
+
+type identityKey struct{}
+
+/**
+ * Attach the identity of a request to a context
+ */
+func WithIdentity(ctx context.Context, id *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+/**
+ * Get the identity carried by a context
+ * @return identity, nil if authentication is disabled
+ */
+func FromContext(ctx context.Context) *Identity {
+	id, _ := ctx.Value(identityKey{}).(*Identity)
+	return id
+}
@@ -0,0 +1,354 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sirupsen/logrus"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/config"
+)
+
+// Clock skew tolerated when checking exp and nbf
+const jwtLeeway = 60 * time.Second
+
+// Shortest interval between two JWKS fetches triggered by an unknown key ID
+const jwksMinRefetch = 30 * time.Second
+
+/**
+ * Authenticates requests by JWT bearer tokens, verified with a JWKS or a local key
+ */
+type jwtAuth struct {
+	cfg         config.JWTConfig
+	defaultRole string
+	local       crypto.PublicKey // key from key_file; []byte for HMAC secrets
+	jwks        *jwksCache
+}
+
+func newJWTAuth(c *config.AuthConfig) (*jwtAuth, error) {
+	a := &jwtAuth{cfg: c.JWT, defaultRole: c.DefaultRole}
+	if a.cfg.KeyFile != "" {
+		key, err := loadKeyFile(a.cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("auth.jwt.key_file: %w", err)
+		}
+		a.local = key
+	}
+	if a.cfg.JWKSURL != "" {
+		refresh := a.cfg.JWKSRefresh
+		if refresh <= 0 {
+			refresh = 10 * time.Minute
+		}
+		a.jwks = &jwksCache{url: a.cfg.JWKSURL, refresh: refresh}
+		if err := a.jwks.fetch(); err != nil {
+			logrus.Warnf("Fetch JWKS %s failed: %v", a.cfg.JWKSURL, err)
+		}
+	}
+	if a.local == nil && a.jwks == nil {
+		return nil, fmt.Errorf("auth mode jwt needs auth.jwt.jwks_url or auth.jwt.key_file")
+	}
+	return a, nil
+}
+
+// Signing algorithms accepted; "none" is never among them
+var jwtMethods = []string{
+	"RS256", "RS384", "RS512",
+	"PS256", "PS384", "PS512",
+	"ES256", "ES384", "ES512",
+	"HS256", "HS384", "HS512",
+	"EdDSA",
+}
+
+/**
+ * Verify a bearer token and map its claims to an identity
+ * @description
+ * - Signatures and the registered claims are checked by golang-jwt
+ * - The algorithm must match the key type, so a public key can't be used as an HMAC secret
+ * - exp is required, exp and nbf are checked with jwtLeeway; iss and aud when configured
+ */
+func (a *jwtAuth) Authenticate(r *http.Request) (*Identity, error) {
+	token := bearerToken(r)
+	if token == "" || strings.Count(token, ".") != 2 {
+		return nil, ErrNoCredentials
+	}
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(jwtMethods),
+		jwt.WithLeeway(jwtLeeway),
+		jwt.WithExpirationRequired(),
+	}
+	if a.cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(a.cfg.Issuer))
+	}
+	if a.cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(a.cfg.Audience))
+	}
+	claims := jwt.MapClaims{}
+	_, err := jwt.NewParser(opts...).ParseWithClaims(token, claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return a.key(kid)
+	})
+	if err != nil {
+		return nil, err
+	}
+	subject, _ := claimValue(claims, a.cfg.SubjectClaim).(string)
+	if subject == "" {
+		return nil, fmt.Errorf("token has no %s claim", a.cfg.SubjectClaim)
+	}
+	tenant, _ := claimValue(claims, a.cfg.TenantClaim).(string)
+	return &Identity{
+		Subject: subject,
+		Roles:   withDefaultRole(claimList(claimValue(claims, a.cfg.RolesClaim)), a.defaultRole),
+		Groups:  claimList(claimValue(claims, a.cfg.GroupsClaim)),
+		Tenant:  tenant,
+		Method:  "jwt",
+	}, nil
+}
+
+/**
+ * Get the key verifying a token
+ * @param kid key ID from the token header, may be empty
+ */
+func (a *jwtAuth) key(kid string) (crypto.PublicKey, error) {
+	if a.jwks != nil {
+		if key := a.jwks.get(kid); key != nil {
+			return key, nil
+		}
+	}
+	if a.local != nil {
+		return a.local, nil
+	}
+	return nil, fmt.Errorf("no key for token key ID '%s'", kid)
+}
+
+/**
+ * Get a claim by a dotted path, such as "realm_access.roles"
+ */
+func claimValue(claims map[string]any, path string) any {
+	var v any = claims
+	for _, name := range strings.Split(path, ".") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = m[name]
+	}
+	return v
+}
+
+/**
+ * Get a claim holding a list, given as an array or a comma or space separated string
+ */
+func claimList(v any) []string {
+	switch val := v.(type) {
+	case string:
+		return splitList(val)
+	case []any:
+		var list []string
+		for _, item := range val {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+/**
+ * Load a verification key from a file
+ * @return public key from a PEM "PUBLIC KEY", "RSA PUBLIC KEY" or "CERTIFICATE" block;
+ *         otherwise the trimmed file content as an HMAC secret
+ */
+func loadKeyFile(path string) (crypto.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		secret := []byte(strings.TrimSpace(string(data)))
+		if len(secret) == 0 {
+			return nil, errors.New("key file is empty")
+		}
+		return secret, nil
+	}
+	switch block.Type {
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	}
+	return nil, fmt.Errorf("unsupported PEM block %s", block.Type)
+}
+
+/**
+ * Keys of a JWKS endpoint, refreshed periodically and when a token names an unknown key
+ */
+type jwksCache struct {
+	url     string
+	refresh time.Duration
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+/**
+ * Get a key by ID
+ * @param kid key ID, empty picks the only key of the set
+ * @return key, nil if not found
+ * @description
+ * - The set is fetched without holding the lock; callers arriving meanwhile use the keys at hand
+ */
+func (c *jwksCache) get(kid string) crypto.PublicKey {
+	c.mu.Lock()
+	key := c.lookup(kid)
+	stale := time.Since(c.fetchedAt) > c.refresh
+	if (key == nil && time.Since(c.fetchedAt) > jwksMinRefetch) || stale {
+		// Claim the fetch, so concurrent callers don't fetch too
+		c.fetchedAt = time.Now()
+		c.mu.Unlock()
+		if err := c.fetch(); err != nil {
+			logrus.Warnf("Fetch JWKS %s failed: %v", c.url, err)
+		}
+		c.mu.Lock()
+		key = c.lookup(kid)
+	}
+	c.mu.Unlock()
+	return key
+}
+
+func (c *jwksCache) lookup(kid string) crypto.PublicKey {
+	if kid == "" && len(c.keys) == 1 {
+		for _, k := range c.keys {
+			return k
+		}
+	}
+	return c.keys[kid]
+}
+
+/**
+ * Fetch the key set and replace the cached keys; the lock is only held to store them
+ */
+func (c *jwksCache) fetch() error {
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(c.url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return err
+	}
+	keys := make(map[string]crypto.PublicKey)
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			logrus.Warnf("Skip JWKS key '%s': %v", k.Kid, err)
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	c.mu.Lock()
+	c.keys = keys
+	c.fetchedAt = time.Now()
+	c.mu.Unlock()
+	return nil
+}
+
+/**
+ * JSON Web Key, as published in a JWKS
+ */
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+func (k *jwk) publicKey() (crypto.PublicKey, error) {
+	decode := base64.RawURLEncoding.DecodeString
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	case "oct":
+		return decode(k.K)
+	}
+	return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+}
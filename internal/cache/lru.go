@@ -80,3 +80,30 @@ func (c *LRUCache) Put(key string, value interface{}) {
 	elem := c.list.PushFront(newEntry)
 	c.values[key] = elem
 }
+
+/**
+ * Remove value from cache
+ * @param c LRUCache instance
+ * @param key Entry key
+ */
+func (c *LRUCache) Remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.values[key]; ok {
+		delete(c.values, key)
+		c.list.Remove(elem)
+	}
+}
+
+/**
+ * Get number of entries in cache
+ * @param c LRUCache instance
+ * @return Number of entries
+ */
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.list.Len()
+}
@@ -0,0 +1,158 @@
+package cache
+
+import (
+	"container/list"
+	"math"
+	"sync"
+	"time"
+)
+
+// VectorIndex keeps values by embedding vectors, searched by cosine similarity
+type VectorIndex struct {
+	size int
+	list *list.List
+	mu   sync.Mutex
+}
+
+type vectorEntry struct {
+	tag       string
+	group     string
+	vector    []float32
+	value     interface{}
+	expiresAt time.Time
+}
+
+/**
+ * Create new vector index instance
+ * @param size Maximum number of entries in index
+ * @return New VectorIndex instance
+ */
+func NewVectorIndex(size int) *VectorIndex {
+	return &VectorIndex{
+		size: size,
+		list: list.New(),
+	}
+}
+
+/**
+ * Add value to index
+ * @param x VectorIndex instance
+ * @param tag Label of the entry, entries are removed by it
+ * @param group Entries searched together, values are only found in the group they were added to
+ * @param vector Embedding of the value
+ * @param value Entry value
+ * @param expiresAt Time after which the entry isn't found any more
+ * Will evict oldest entry if index is full
+ */
+func (x *VectorIndex) Add(tag, group string, vector []float64, value interface{}, expiresAt time.Time) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if x.list.Len() >= x.size {
+		if oldest := x.list.Back(); oldest != nil {
+			x.list.Remove(oldest)
+		}
+	}
+	x.list.PushFront(&vectorEntry{
+		tag:       tag,
+		group:     group,
+		vector:    normalize(vector),
+		value:     value,
+		expiresAt: expiresAt,
+	})
+}
+
+/**
+ * Find the value most similar to a vector in a group
+ * @param x VectorIndex instance
+ * @param group Entries searched
+ * @param vector Embedding searched for
+ * @param threshold Least cosine similarity of the value found
+ * @return Value, its similarity and existence flag
+ * Expired entries met are removed
+ */
+func (x *VectorIndex) Search(group string, vector []float64, threshold float64) (interface{}, float64, bool) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	query := normalize(vector)
+	now := time.Now()
+	var best *vectorEntry
+	bestScore := threshold
+	for elem := x.list.Front(); elem != nil; {
+		next := elem.Next()
+		entry := elem.Value.(*vectorEntry)
+		if !now.Before(entry.expiresAt) {
+			x.list.Remove(elem)
+		} else if entry.group == group && len(entry.vector) == len(query) {
+			if score := dot(entry.vector, query); score >= bestScore {
+				best, bestScore = entry, score
+			}
+		}
+		elem = next
+	}
+	if best == nil {
+		return nil, 0, false
+	}
+	// Rounding of the vectors may give identical ones a similarity above 1
+	return best.value, math.Min(bestScore, 1), true
+}
+
+/**
+ * Remove all entries with a tag
+ * @param x VectorIndex instance
+ * @param tag Label of the entries
+ * @return Number of entries removed
+ */
+func (x *VectorIndex) RemoveTag(tag string) int {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	removed := 0
+	for elem := x.list.Front(); elem != nil; {
+		next := elem.Next()
+		if elem.Value.(*vectorEntry).tag == tag {
+			x.list.Remove(elem)
+			removed++
+		}
+		elem = next
+	}
+	return removed
+}
+
+/**
+ * Get number of entries in index
+ * @param x VectorIndex instance
+ * @return Number of entries
+ */
+func (x *VectorIndex) Len() int {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	return x.list.Len()
+}
+
+// Scale a vector to unit length, so that cosine similarity is a dot product
+func normalize(v []float64) []float32 {
+	var norm float64
+	for _, f := range v {
+		norm += f * f
+	}
+	norm = math.Sqrt(norm)
+	u := make([]float32, len(v))
+	if norm == 0 {
+		return u
+	}
+	for i, f := range v {
+		u[i] = float32(f / norm)
+	}
+	return u
+}
+
+func dot(a, b []float32) float64 {
+	var s float64
+	for i := range a {
+		s += float64(a[i]) * float64(b[i])
+	}
+	return s
+}
@@ -11,13 +11,26 @@ import (
  * Includes all subsystem configurations
  */
 type Config struct {
-	AppName string        `mapstructure:"app_name"`
-	Env     string        `mapstructure:"env"`
-	Server  ServerConfig  `mapstructure:"server"`
-	Logger  LoggerConfig  `mapstructure:"logger"`
-	Redis   RedisConfig   `mapstructure:"redis"`
-	Refresh RefreshConfig `mapstructure:"refresh"`
-	LLM     LLMConfig     `mapstructure:"llm"`
+	AppName   string          `mapstructure:"app_name"`
+	Env       string          `mapstructure:"env"`
+	Server    ServerConfig    `mapstructure:"server"`
+	Logger    LoggerConfig    `mapstructure:"logger"`
+	Storage   StorageConfig   `mapstructure:"storage"`
+	Redis     RedisConfig     `mapstructure:"redis"`
+	Refresh   RefreshConfig   `mapstructure:"refresh"`
+	LLM       LLMConfig       `mapstructure:"llm"`
+	Tokenizer TokenizerConfig `mapstructure:"tokenizer"`
+	Fixtures  FixturesConfig  `mapstructure:"fixtures"`
+	Sync      SyncConfig      `mapstructure:"sync"`
+	Secrets   SecretsConfig   `mapstructure:"secrets"`
+	Auth      AuthConfig      `mapstructure:"auth"`
+	Quota     QuotaConfig     `mapstructure:"quota"`
+	Egress    EgressConfig    `mapstructure:"egress"`
+	Guard     GuardConfig     `mapstructure:"guard"`
+	Redact    RedactConfig    `mapstructure:"redact"`
+	Audit     AuditConfig     `mapstructure:"audit"`
+	Cache     CacheConfig     `mapstructure:"cache"`
+	Sessions  SessionsConfig  `mapstructure:"sessions"`
 }
 
 type LoggerConfig struct {
@@ -35,6 +48,15 @@ type ServerConfig struct {
 	Debug      bool   `mapstructure:"debug"`
 }
 
+/**
+ * Storage backend configuration
+ * Type is "redis" (default) or "file"; file storage keeps items as JSON files under Dir
+ */
+type StorageConfig struct {
+	Type string `mapstructure:"type"`
+	Dir  string `mapstructure:"dir"`
+}
+
 /**
  * Redis connection configuration
  */
@@ -52,14 +74,236 @@ type RefreshConfig struct {
 	Extension time.Duration `mapstructure:"extension"`
 	Prompt    time.Duration `mapstructure:"prompt"`
 	Environ   time.Duration `mapstructure:"environ"`
+	Partial   time.Duration `mapstructure:"partial"`
 }
 
 /**
  * LLM API configuration
  */
 type LLMConfig struct {
-	ApiKey  string `mapstructure:"api_key"`
-	ApiBase string `mapstructure:"api_base"`
+	ApiKey             string        `mapstructure:"api_key"`
+	ApiBase            string        `mapstructure:"api_base"`
+	DefaultContextSize int           `mapstructure:"default_context_size"`
+	Models             []ModelConfig `mapstructure:"models"`
+}
+
+/**
+ * Per-model settings
+ */
+type ModelConfig struct {
+	Name        string `mapstructure:"name"`
+	ContextSize int    `mapstructure:"context_size"`
+}
+
+/**
+ * Tokenizer configuration
+ * Without a vocabulary file tokens are estimated at about 4 bytes each
+ */
+type TokenizerConfig struct {
+	Encoding  string `mapstructure:"encoding"`
+	VocabFile string `mapstructure:"vocab_file"`
+}
+
+/**
+ * Tool fixture configuration
+ * Fixture files record tool calls for replaying renders offline
+ */
+type FixturesConfig struct {
+	Dir string `mapstructure:"dir"`
+}
+
+/**
+ * Git repository sync configuration
+ * Repo is a URL or local path; if set, prompts, tools, partials and extensions under Path in the
+ * repository are published every Interval. Dir holds the local checkout
+ */
+type SyncConfig struct {
+	Repo     string        `mapstructure:"repo"`
+	Branch   string        `mapstructure:"branch"`
+	Path     string        `mapstructure:"path"`
+	Dir      string        `mapstructure:"dir"`
+	Interval time.Duration `mapstructure:"interval"`
+}
+
+/**
+ * Secret encryption configuration
+ * Key, or else the content of KeyFile, encrypts secrets in storage; without either, secrets can't be set
+ */
+type SecretsConfig struct {
+	Key     string `mapstructure:"key"`
+	KeyFile string `mapstructure:"key_file"`
+}
+
+/**
+ * API authentication configuration
+ * Modes lists the enabled authenticators: apikey, jwt, gateway; auth is disabled if empty.
+ * Callers without roles get DefaultRole
+ */
+type AuthConfig struct {
+	Modes       []string       `mapstructure:"modes"`
+	DefaultRole string         `mapstructure:"default_role"`
+	APIKeys     []APIKeyConfig `mapstructure:"api_keys"`
+	JWT         JWTConfig      `mapstructure:"jwt"`
+	Gateway     GatewayConfig  `mapstructure:"gateway"`
+}
+
+/**
+ * Static API key
+ * Key is the key itself, or "sha256:" followed by the hex SHA-256 of the key
+ */
+type APIKeyConfig struct {
+	Key     string   `mapstructure:"key"`
+	Subject string   `mapstructure:"subject"`
+	Roles   []string `mapstructure:"roles"`
+	Groups  []string `mapstructure:"groups"`
+	Tenant  string   `mapstructure:"tenant"`
+}
+
+/**
+ * JWT verification configuration
+ * Keys come from JWKSURL, refreshed every JWKSRefresh, or from KeyFile holding a PEM public key
+ * or certificate, or an HMAC secret. Claims are dot paths into the token payload
+ */
+type JWTConfig struct {
+	JWKSURL      string        `mapstructure:"jwks_url"`
+	JWKSRefresh  time.Duration `mapstructure:"jwks_refresh"`
+	KeyFile      string        `mapstructure:"key_file"`
+	Issuer       string        `mapstructure:"issuer"`
+	Audience     string        `mapstructure:"audience"`
+	SubjectClaim string        `mapstructure:"subject_claim"`
+	RolesClaim   string        `mapstructure:"roles_claim"`
+	GroupsClaim  string        `mapstructure:"groups_claim"`
+	TenantClaim  string        `mapstructure:"tenant_claim"`
+}
+
+/**
+ * Upstream gateway configuration
+ * The gateway authenticates callers and passes them in headers, which are only trusted from
+ * TrustedProxies (IPs or CIDRs)
+ */
+type GatewayConfig struct {
+	UserHeader     string   `mapstructure:"user_header"`
+	RolesHeader    string   `mapstructure:"roles_header"`
+	GroupsHeader   string   `mapstructure:"groups_header"`
+	TenantHeader   string   `mapstructure:"tenant_header"`
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
+}
+
+/**
+ * Quotas of LLM calls
+ */
+type QuotaConfig struct {
+	Limits []QuotaLimit `mapstructure:"limits"`
+}
+
+/**
+ * Limit of requests and LLM tokens in a sliding window
+ * Scope is user, tenant, prompt or model; ID is the one limited, or "*" for each one separately.
+ * Limits with a specific ID replace the "*" limits of the same scope. Zero Requests or Tokens don't limit
+ */
+type QuotaLimit struct {
+	Scope    string        `mapstructure:"scope"`
+	ID       string        `mapstructure:"id"`
+	Window   time.Duration `mapstructure:"window"`
+	Requests int64         `mapstructure:"requests"`
+	Tokens   int64         `mapstructure:"tokens"`
+}
+
+/**
+ * Policy of outbound tool calls
+ * AllowedHosts (names, "*.domain" wildcards or IPs) and AllowedCIDRs restrict destinations if set;
+ * BlockedCIDRs are refused even if allowed. MaxResponseBytes limits tool responses
+ */
+type EgressConfig struct {
+	AllowedSchemes   []string `mapstructure:"allowed_schemes"`
+	AllowedHosts     []string `mapstructure:"allowed_hosts"`
+	AllowedCIDRs     []string `mapstructure:"allowed_cidrs"`
+	BlockedCIDRs     []string `mapstructure:"blocked_cidrs"`
+	MaxResponseBytes int64    `mapstructure:"max_response_bytes"`
+	MaxRedirects     int      `mapstructure:"max_redirects"`
+}
+
+/**
+ * Prompt injection guard of chats
+ * DefaultAction (block, warn or sanitize) applies to prompts without a guard policy; empty leaves them unchecked.
+ * Without Detectors, the default regex ruleset and the delimiter detector are used
+ */
+type GuardConfig struct {
+	DefaultAction string                `mapstructure:"default_action"`
+	Detectors     []GuardDetectorConfig `mapstructure:"detectors"`
+}
+
+/**
+ * Detector of the guard
+ * Type is regex (Ruleset names a built-in ruleset, Patterns adds rules), delimiter,
+ * or classifier (Prompt is rendered with the content and sent to Model; Threshold is the score flagging it)
+ */
+type GuardDetectorConfig struct {
+	Name      string   `mapstructure:"name"`
+	Type      string   `mapstructure:"type"`
+	Ruleset   string   `mapstructure:"ruleset"`
+	Patterns  []string `mapstructure:"patterns"`
+	Prompt    string   `mapstructure:"prompt"`
+	Model     string   `mapstructure:"model"`
+	Threshold float64  `mapstructure:"threshold"`
+}
+
+/**
+ * Redaction of sensitive data in the messages sent to the LLM
+ * DefaultMode (placeholder or mask) applies to prompts without a redaction policy; empty leaves them unredacted.
+ * Environ is the shared variable holding custom patterns, by name
+ */
+type RedactConfig struct {
+	DefaultMode string `mapstructure:"default_mode"`
+	Environ     string `mapstructure:"environ"`
+}
+
+/**
+ * Audit log of configuration changes and chats
+ * Sink is "redis" (a stream of at most MaxLen records), "file" (File, rotated at MaxBytes, keeping MaxFiles), or empty to disable auditing.
+ * Payload also records the messages sent to the LLM and its answers
+ */
+type AuditConfig struct {
+	Sink     string `mapstructure:"sink"`
+	MaxLen   int64  `mapstructure:"max_len"`
+	File     string `mapstructure:"file"`
+	MaxBytes int64  `mapstructure:"max_bytes"`
+	MaxFiles int    `mapstructure:"max_files"`
+	Payload  bool   `mapstructure:"payload"`
+}
+
+/**
+ * Cache of chat responses, for prompts opting in
+ * L1Size is the number of responses kept in process, in front of Redis; DefaultTTL applies to prompts not setting their own
+ */
+type CacheConfig struct {
+	L1Size     int                 `mapstructure:"l1_size"`
+	DefaultTTL time.Duration       `mapstructure:"default_ttl"`
+	Semantic   SemanticCacheConfig `mapstructure:"semantic"`
+}
+
+/**
+ * Semantic cache, matching chats by the embedding of their final user message
+ * ApiBase and ApiKey of the OpenAI compatible embeddings API default to those of the LLM API;
+ * MaxEntries is the number of embeddings kept in process
+ */
+type SemanticCacheConfig struct {
+	ApiBase    string `mapstructure:"api_base"`
+	ApiKey     string `mapstructure:"api_key"`
+	Model      string `mapstructure:"model"`
+	MaxEntries int    `mapstructure:"max_entries"`
+}
+
+/**
+ * Multi-turn chat sessions on top of prompts
+ * TTL is renewed by each message; histories outgrowing the model context are trimmed (Overflow "trim"),
+ * or summarized (Overflow "summarize") by SummaryModel, the model of the session by default, in at most SummaryTokens
+ */
+type SessionsConfig struct {
+	TTL           time.Duration `mapstructure:"ttl"`
+	Overflow      string        `mapstructure:"overflow"`
+	SummaryModel  string        `mapstructure:"summary_model"`
+	SummaryTokens int           `mapstructure:"summary_tokens"`
 }
 
 var cfg *Config
@@ -77,6 +321,7 @@ func Load() *Config {
 	viper.SetConfigType("yaml")
 	viper.AddConfigPath(".")
 	viper.AddConfigPath("./configs")
+	setDefaults()
 
 	if err := viper.ReadInConfig(); err != nil {
 		panic(err)
@@ -88,3 +333,45 @@ func Load() *Config {
 
 	return cfg
 }
+
+/**
+ * Set default values for options that older config files may not contain
+ */
+func setDefaults() {
+	viper.SetDefault("storage.type", "redis")
+	viper.SetDefault("storage.dir", "data")
+	viper.SetDefault("refresh.partial", "5m")
+	viper.SetDefault("llm.default_context_size", 8192)
+	viper.SetDefault("tokenizer.encoding", "cl100k_base")
+	viper.SetDefault("fixtures.dir", "fixtures")
+	viper.SetDefault("sync.dir", "sync")
+	viper.SetDefault("sync.interval", "1m")
+	viper.SetDefault("auth.default_role", "reader")
+	viper.SetDefault("auth.jwt.jwks_refresh", "10m")
+	viper.SetDefault("auth.jwt.subject_claim", "sub")
+	viper.SetDefault("auth.jwt.roles_claim", "roles")
+	viper.SetDefault("auth.jwt.groups_claim", "groups")
+	viper.SetDefault("auth.jwt.tenant_claim", "tenant")
+	viper.SetDefault("auth.gateway.user_header", "X-Auth-User")
+	viper.SetDefault("auth.gateway.roles_header", "X-Auth-Roles")
+	viper.SetDefault("auth.gateway.groups_header", "X-Auth-Groups")
+	viper.SetDefault("auth.gateway.tenant_header", "X-Auth-Tenant")
+	viper.SetDefault("egress.allowed_schemes", []string{"http", "https"})
+	viper.SetDefault("egress.blocked_cidrs", []string{
+		"0.0.0.0/8", "127.0.0.0/8", "::1/128", "169.254.0.0/16", "100.100.100.200/32", "fe80::/10", "fd00:ec2::254/128",
+	})
+	viper.SetDefault("egress.max_response_bytes", 4<<20)
+	viper.SetDefault("egress.max_redirects", 3)
+	viper.SetDefault("redact.environ", "redact.patterns")
+	viper.SetDefault("audit.max_len", 100000)
+	viper.SetDefault("audit.file", "audit/audit.jsonl")
+	viper.SetDefault("audit.max_bytes", 64<<20)
+	viper.SetDefault("audit.max_files", 5)
+	viper.SetDefault("cache.l1_size", 1000)
+	viper.SetDefault("cache.default_ttl", "1h")
+	viper.SetDefault("cache.semantic.model", "text-embedding-3-small")
+	viper.SetDefault("cache.semantic.max_entries", 10000)
+	viper.SetDefault("sessions.ttl", "24h")
+	viper.SetDefault("sessions.overflow", "summarize")
+	viper.SetDefault("sessions.summary_tokens", 512)
+}
@@ -0,0 +1,220 @@
+package egress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/zgsm-ai/ai-prompt-shell/internal/config"
+)
+
+// Returned, wrapped, for destinations refused by the policy
+var ErrDenied = errors.New("denied by egress policy")
+
+/**
+ * Policy restricting where tools may connect to
+ * @description
+ * - Hosts are checked on the URL, addresses on every connection actually made, after DNS resolution,
+ *   so a name resolving to an allowed address at first and to a blocked one later (DNS rebinding) is still refused
+ * - Connections through proxies aren't made, since the address checked would be the proxy's
+ */
+type Policy struct {
+	schemes      []string
+	hosts        []string
+	allowed      []*net.IPNet
+	blocked      []*net.IPNet
+	maxBytes     int64
+	maxRedirects int
+}
+
+/**
+ * Create a policy from configuration
+ * @throws error if a CIDR is invalid
+ */
+func New(c *config.EgressConfig) (*Policy, error) {
+	p := &Policy{
+		schemes:      c.AllowedSchemes,
+		maxBytes:     c.MaxResponseBytes,
+		maxRedirects: c.MaxRedirects,
+	}
+	for _, h := range c.AllowedHosts {
+		p.hosts = append(p.hosts, strings.ToLower(h))
+	}
+	var err error
+	if p.allowed, err = parseCIDRs(c.AllowedCIDRs); err != nil {
+		return nil, fmt.Errorf("egress.allowed_cidrs: %v", err)
+	}
+	if p.blocked, err = parseCIDRs(c.BlockedCIDRs); err != nil {
+		return nil, fmt.Errorf("egress.blocked_cidrs: %v", err)
+	}
+	return p, nil
+}
+
+/**
+ * Parse CIDRs, taking bare IPs as single addresses
+ */
+func parseCIDRs(list []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, s := range list {
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address %s", s)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %s", s)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+/**
+ * Check a URL before requesting it
+ * @throws ErrDenied if its scheme or host isn't allowed
+ */
+func (p *Policy) CheckURL(u *url.URL) error {
+	if len(p.schemes) > 0 && !containsFold(p.schemes, u.Scheme) {
+		return fmt.Errorf("scheme %s %w", u.Scheme, ErrDenied)
+	}
+	return p.CheckHost(u.Hostname())
+}
+
+/**
+ * Check a host name or IP before connecting to it
+ * @throws ErrDenied if hosts are restricted and it matches none of them
+ */
+func (p *Policy) CheckHost(host string) error {
+	if len(p.hosts) == 0 {
+		return nil
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, pattern := range p.hosts {
+		if pattern == host {
+			return nil
+		}
+		if suffix, ok := strings.CutPrefix(pattern, "*."); ok && strings.HasSuffix(host, "."+suffix) {
+			return nil
+		}
+	}
+	return fmt.Errorf("host %s %w", host, ErrDenied)
+}
+
+/**
+ * Check an address being connected to
+ * @throws ErrDenied if it's blocked, or addresses are restricted and it's in none of them
+ */
+func (p *Policy) CheckIP(ip net.IP) error {
+	for _, n := range p.blocked {
+		if n.Contains(ip) {
+			return fmt.Errorf("address %s %w", ip, ErrDenied)
+		}
+	}
+	if len(p.allowed) == 0 {
+		return nil
+	}
+	for _, n := range p.allowed {
+		if n.Contains(ip) {
+			return nil
+		}
+	}
+	return fmt.Errorf("address %s %w", ip, ErrDenied)
+}
+
+/**
+ * Dial a connection, refusing addresses the policy doesn't allow
+ * @description
+ * - The address is checked right before connecting, after resolution, so it's the one actually used
+ */
+func (p *Policy) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil {
+				return fmt.Errorf("address %s %w", host, ErrDenied)
+			}
+			return p.CheckIP(ip)
+		},
+	}
+	return dialer.DialContext(ctx, network, addr)
+}
+
+/**
+ * Create an HTTP client enforcing the policy on every request and redirect
+ * @param timeout timeout of whole requests
+ */
+func (p *Policy) HTTPClient(timeout time.Duration) *http.Client {
+	transport := &http.Transport{
+		Proxy:                 nil,
+		DialContext:           p.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > p.maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", p.maxRedirects)
+			}
+			return p.CheckURL(req.URL)
+		},
+	}
+}
+
+/**
+ * Read a response body, up to the maximum response size
+ * @throws error if the body is larger
+ */
+func (p *Policy) ReadBody(r io.Reader) ([]byte, error) {
+	if p.maxBytes <= 0 {
+		return io.ReadAll(r)
+	}
+	data, err := io.ReadAll(io.LimitReader(r, p.maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > p.maxBytes {
+		return nil, fmt.Errorf("response exceeds %d bytes", p.maxBytes)
+	}
+	return data, nil
+}
+
+/**
+ * Maximum response size in bytes, 0 if unlimited
+ */
+func (p *Policy) MaxResponseBytes() int64 {
+	return p.maxBytes
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
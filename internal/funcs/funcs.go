@@ -0,0 +1,220 @@
+package funcs
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/zgsm-ai/ai-prompt-shell/internal/tokenizer"
+)
+
+/**
+ * Get the built-in template function library
+ * @return new FuncMap with all built-in helpers
+ * @description
+ * - Arguments are ordered so the processed value comes last and works in pipelines, e.g. {{.args.code | indent 4}}
+ * - A fresh map is returned on every call, callers may add their own functions to it
+ * @example
+ * funcMap := funcs.Builtins()
+ * t := template.New("x").Funcs(funcMap)
+ */
+func Builtins() template.FuncMap {
+	return template.FuncMap{
+		"toJson":         toJson,
+		"fromJson":       fromJson,
+		"indent":         indent,
+		"trunc":          trunc,
+		"default":        defaultValue,
+		"join":           join,
+		"regexReplace":   regexReplace,
+		"lines":          lines,
+		"codeFence":      codeFence,
+		"now":            time.Now,
+		"truncateTokens": truncateTokens,
+	}
+}
+
+/**
+ * Encode value as JSON
+ * @param v value to encode
+ * @return JSON text
+ * @example {{toJson .args}}
+ */
+func toJson(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+/**
+ * Decode JSON text into a value
+ * @param s JSON text
+ * @return decoded value (map, slice, string, float64, bool or nil)
+ * @example {{(fromJson .args.options).level}}
+ */
+func fromJson(s string) (interface{}, error) {
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+/**
+ * Indent every line of text with spaces
+ * @param spaces number of spaces
+ * @param s text to indent
+ * @return indented text
+ * @example {{.args.code | indent 4}}
+ */
+func indent(spaces int, s string) string {
+	pad := strings.Repeat(" ", spaces)
+	return pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+}
+
+/**
+ * Truncate text to n characters
+ * @param n number of characters to keep; negative keeps the last -n characters
+ * @param s text to truncate
+ * @return truncated text
+ * @example {{.args.title | trunc 80}}
+ */
+func trunc(n int, s string) string {
+	runes := []rune(s)
+	if n >= 0 {
+		if n < len(runes) {
+			return string(runes[:n])
+		}
+		return s
+	}
+	if -n < len(runes) {
+		return string(runes[len(runes)+n:])
+	}
+	return s
+}
+
+/**
+ * Use a default when value is empty
+ * @param def default value
+ * @param v value to check; nil, zero numbers, empty strings and empty collections are empty
+ * @return v if not empty, otherwise def
+ * @example {{.args.language | default "go"}}
+ */
+func defaultValue(def interface{}, v ...interface{}) interface{} {
+	if len(v) == 0 || isEmpty(v[0]) {
+		return def
+	}
+	return v[0]
+}
+
+func isEmpty(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return rv.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return rv.IsNil()
+	default:
+		return rv.IsZero()
+	}
+}
+
+/**
+ * Join list elements with separator
+ * @param sep separator
+ * @param list slice or array of any element type
+ * @return joined text
+ * @example {{.args.files | join ", "}}
+ */
+func join(sep string, list interface{}) (string, error) {
+	if list == nil {
+		return "", nil
+	}
+	rv := reflect.ValueOf(list)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return "", fmt.Errorf("join: expected a list, got %T", list)
+	}
+	parts := make([]string, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		parts[i] = fmt.Sprint(rv.Index(i).Interface())
+	}
+	return strings.Join(parts, sep), nil
+}
+
+/**
+ * Replace all matches of a regular expression
+ * @param pattern RE2 regular expression
+ * @param repl replacement, may reference groups as $1 or ${name}
+ * @param s text to process
+ * @return processed text
+ * @example {{.args.code | regexReplace "(?m)^\\s+$" ""}}
+ */
+func regexReplace(pattern, repl, s string) (string, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", err
+	}
+	return re.ReplaceAllString(s, repl), nil
+}
+
+/**
+ * Split text into lines
+ * @param s text to split, both \n and \r\n line endings are accepted
+ * @return lines without line endings
+ * @example {{range lines .args.diff}}...{{end}}
+ */
+func lines(s string) []string {
+	return strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+}
+
+/**
+ * Wrap code in a markdown fence that doesn't collide with the content
+ * @param lang language tag of the fence, may be empty
+ * @param code code to wrap
+ * @return fenced code block
+ * @description
+ * - The fence is one backtick longer than the longest backtick run in code, and at least 3 backticks
+ * @example {{codeFence .args.language .args.code}}
+ */
+func codeFence(lang, code string) string {
+	longest, run := 0, 0
+	for _, r := range code {
+		if r == '`' {
+			run++
+			if run > longest {
+				longest = run
+			}
+		} else {
+			run = 0
+		}
+	}
+	n := 3
+	if longest >= n {
+		n = longest + 1
+	}
+	fence := strings.Repeat("`", n)
+	if !strings.HasSuffix(code, "\n") {
+		code += "\n"
+	}
+	return fence + lang + "\n" + code + fence
+}
+
+/**
+ * Truncate text to a number of tokens of the configured tokenizer
+ * @param maxTokens number of tokens to keep
+ * @param s text to truncate
+ * @return leading part of s within maxTokens tokens
+ * @example {{.args.code | truncateTokens 2000}}
+ */
+func truncateTokens(maxTokens int, s string) string {
+	return tokenizer.Truncate(tokenizer.Default(), s, maxTokens, tokenizer.KeepHead)
+}
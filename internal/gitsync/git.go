@@ -0,0 +1,132 @@
+package gitsync
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// File in the .git directory marking a checkout as made by sync, so only those are reset and cleaned
+const ownerMarker = "ai-prompt-shell-sync"
+
+/**
+ * Local checkout of a git repository, kept up to date by running the git command
+ */
+type Checkout struct {
+	Repo   string // URL or local path of the repository
+	Branch string // Branch to follow, empty for the repository's default branch
+	Dir    string // Directory of the local checkout
+}
+
+/**
+ * Clone the repository, or fetch the latest commit of the branch if already cloned
+ * @param ctx context bounding the git commands
+ * @return SHA of the checked-out commit
+ * @description
+ * - The working tree is reset to the fetched commit, discarding local changes
+ * - Only the latest commit is fetched, history is not needed to publish a snapshot
+ * - Clones only into a missing or empty directory, and only updates checkouts it cloned;
+ *   any other directory is left alone and reported as an error
+ */
+func (c *Checkout) Update(ctx context.Context) (string, error) {
+	if _, err := os.Stat(filepath.Join(c.Dir, ".git")); err != nil {
+		if err := c.clone(ctx); err != nil {
+			return "", err
+		}
+	} else {
+		if _, err := os.Stat(filepath.Join(c.Dir, ".git", ownerMarker)); err != nil {
+			return "", fmt.Errorf("%s is a git checkout not made by sync, refusing to reset it", c.Dir)
+		}
+		ref := c.Branch
+		if ref == "" {
+			ref = "HEAD"
+		}
+		if _, err := c.git(ctx, "remote", "set-url", "origin", c.Repo); err != nil {
+			return "", err
+		}
+		if _, err := c.git(ctx, "fetch", "--depth", "1", "origin", ref); err != nil {
+			return "", err
+		}
+		if _, err := c.git(ctx, "reset", "--hard", "FETCH_HEAD"); err != nil {
+			return "", err
+		}
+		if _, err := c.git(ctx, "clean", "-fdx"); err != nil {
+			return "", err
+		}
+	}
+	return c.Head(ctx)
+}
+
+/**
+ * Get the SHA of the checked-out commit
+ */
+func (c *Checkout) Head(ctx context.Context) (string, error) {
+	out, err := c.git(ctx, "rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+func (c *Checkout) clone(ctx context.Context) error {
+	entries, err := os.ReadDir(c.Dir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(entries) > 0 {
+		return fmt.Errorf("%s is not empty and not a checkout made by sync, refusing to clone into it", c.Dir)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.Dir), 0755); err != nil {
+		return err
+	}
+	repo := c.Repo
+	if abs, err := filepath.Abs(repo); err == nil && isDir(repo) {
+		// --depth is ignored for plain paths
+		repo = "file://" + filepath.ToSlash(abs)
+	}
+	args := []string{"clone", "--depth", "1"}
+	if c.Branch != "" {
+		args = append(args, "--branch", c.Branch)
+	}
+	args = append(args, repo, c.Dir)
+	if _, err := run(ctx, "", args...); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(c.Dir, ".git", ownerMarker), nil, 0644)
+}
+
+func (c *Checkout) git(ctx context.Context, args ...string) (string, error) {
+	return run(ctx, c.Dir, args...)
+}
+
+/**
+ * Run a git command
+ * @param dir working directory, empty for the current one
+ * @return standard output, error with the command's standard error if it fails
+ */
+func run(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	// Never prompt for credentials, sync runs unattended
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("git %s: %s", args[0], msg)
+	}
+	return stdout.String(), nil
+}
+
+func isDir(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.IsDir()
+}
@@ -0,0 +1,225 @@
+package guard
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Text replacing content removed by sanitizing
+const Filtered = "[filtered]"
+
+/**
+ * Suspicious span of untrusted content found by a detector
+ * @description
+ * - Start and End are byte offsets of the span in the content, unless Whole is set because
+ *   the detector judges the content as a whole
+ */
+type Finding struct {
+	Detector string `json:"detector"`
+	Rule     string `json:"rule,omitempty"`
+	Source   string `json:"source"`
+	Excerpt  string `json:"excerpt,omitempty"`
+	Start    int    `json:"-"`
+	End      int    `json:"-"`
+	Whole    bool   `json:"-"`
+
+	escape func(string) string // neutralizes the span when sanitizing; nil removes it
+}
+
+/**
+ * Detector of prompt injections in untrusted content
+ */
+type Detector interface {
+	Name() string
+	/**
+	 * Find suspicious spans of content
+	 * @param source where the content comes from, e.g. "args.code" or "tool:codebase.lookup"
+	 */
+	Detect(content, source string) []Finding
+}
+
+/**
+ * Regex rule of a ruleset
+ */
+type Rule struct {
+	ID      string
+	Pattern *regexp.Regexp
+}
+
+// Built-in rulesets, by name
+var rulesets = map[string][]Rule{
+	"default": {
+		{"ignore-instructions", regexp.MustCompile(`(?i)\b(ignore|disregard|forget|override)\b.{0,40}\b(previous|prior|above|earlier|all|system)\b.{0,20}\b(instructions?|prompts?|rules?|messages?)`)},
+		{"reveal-prompt", regexp.MustCompile(`(?i)\b(reveal|print|show|repeat|output|leak)\b.{0,40}\b(system prompt|hidden prompt|initial instructions|your instructions)`)},
+		{"role-override", regexp.MustCompile(`(?i)\byou are (now|no longer)\b|\bact as (an? )?(unrestricted|jailbroken|dan)\b|\bdeveloper mode\b`)},
+		{"new-instructions", regexp.MustCompile(`(?i)\b(new|updated|real) (system )?instructions\s*:`)},
+		{"ignore-instructions-zh", regexp.MustCompile(`(忽略|无视|忘记).{0,20}(之前|以上|上面|所有|系统).{0,10}(指令|提示|规则)`)},
+	},
+}
+
+/**
+ * Get a built-in ruleset
+ * @return rules, false if there's no such ruleset
+ */
+func Ruleset(name string) ([]Rule, bool) {
+	rules, ok := rulesets[name]
+	return rules, ok
+}
+
+/**
+ * Compile rules from patterns; rule IDs are the pattern indexes
+ */
+func CompileRules(patterns []string) ([]Rule, error) {
+	var rules []Rule
+	for i, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("pattern %d: %v", i, err)
+		}
+		rules = append(rules, Rule{ID: fmt.Sprintf("pattern-%d", i), Pattern: re})
+	}
+	return rules, nil
+}
+
+/**
+ * Detector matching regex rules
+ */
+type RegexDetector struct {
+	name  string
+	rules []Rule
+}
+
+func NewRegexDetector(name string, rules []Rule) *RegexDetector {
+	return &RegexDetector{name: name, rules: rules}
+}
+
+func (d *RegexDetector) Name() string {
+	return d.name
+}
+
+func (d *RegexDetector) Detect(content, source string) []Finding {
+	var findings []Finding
+	for _, r := range d.rules {
+		for _, loc := range r.Pattern.FindAllStringIndex(content, -1) {
+			if loc[0] == loc[1] {
+				continue
+			}
+			findings = append(findings, Finding{
+				Detector: d.name,
+				Rule:     r.ID,
+				Source:   source,
+				Excerpt:  excerpt(content[loc[0]:loc[1]]),
+				Start:    loc[0],
+				End:      loc[1],
+			})
+		}
+	}
+	return findings
+}
+
+// Delimiters untrusted content could use to close the block it's quoted in, or to open a message of another role
+var delimiters = []struct {
+	rule    string
+	pattern *regexp.Regexp
+	escape  func(string) string
+}{
+	{"code-fence", regexp.MustCompile("`{3,}|~{3,}"), func(s string) string { return strings.Join(strings.Split(s, ""), "\u200b") }},
+	{"chat-token", regexp.MustCompile(`<\|[a-z_]{1,20}\|>|\[/?INST\]|<</?SYS>>|</?s>`), func(s string) string {
+		return strings.NewReplacer("<", "&lt;", ">", "&gt;", "[", "(", "]", ")").Replace(s)
+	}},
+	{"role-tag", regexp.MustCompile(`(?i)</?(system|assistant|user|instructions?|untrusted)>`), func(s string) string {
+		return strings.NewReplacer("<", "&lt;", ">", "&gt;").Replace(s)
+	}},
+	{"role-line", regexp.MustCompile(`(?im)^\s*(#{1,6}\s*)?(system|assistant)\s*:`), func(s string) string {
+		return strings.Replace(s, ":", "：", 1)
+	}},
+}
+
+/**
+ * Detector of delimiters and chat markup in untrusted content
+ * @description
+ * - Sanitizing escapes the delimiters rather than removing them, so the content keeps its meaning
+ */
+type DelimiterDetector struct {
+	name string
+}
+
+func NewDelimiterDetector(name string) *DelimiterDetector {
+	return &DelimiterDetector{name: name}
+}
+
+func (d *DelimiterDetector) Name() string {
+	return d.name
+}
+
+func (d *DelimiterDetector) Detect(content, source string) []Finding {
+	var findings []Finding
+	for _, delim := range delimiters {
+		for _, loc := range delim.pattern.FindAllStringIndex(content, -1) {
+			findings = append(findings, Finding{
+				Detector: d.name,
+				Rule:     delim.rule,
+				Source:   source,
+				Excerpt:  excerpt(content[loc[0]:loc[1]]),
+				Start:    loc[0],
+				End:      loc[1],
+				escape:   delim.escape,
+			})
+		}
+	}
+	return findings
+}
+
+/**
+ * Sanitize content by its findings
+ * @return content with delimiters escaped and other flagged spans replaced by Filtered;
+ *         Filtered alone if a finding judges the whole content
+ */
+func Sanitize(content string, findings []Finding) string {
+	spans := make([]Finding, 0, len(findings))
+	for _, f := range findings {
+		if f.Whole {
+			return Filtered
+		}
+		spans = append(spans, f)
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].Start < spans[j].Start })
+	var b strings.Builder
+	pos := 0
+	for _, f := range spans {
+		if f.Start < pos {
+			// Overlaps a span already replaced
+			continue
+		}
+		b.WriteString(content[pos:f.Start])
+		if f.escape != nil {
+			b.WriteString(f.escape(content[f.Start:f.End]))
+		} else {
+			b.WriteString(Filtered)
+		}
+		pos = f.End
+	}
+	b.WriteString(content[pos:])
+	return b.String()
+}
+
+/**
+ * Shorten a span for reports
+ */
+func excerpt(s string) string {
+	const max = 80
+	if len(s) <= max {
+		return s
+	}
+	cut := max
+	for cut > 0 && !isRuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + "..."
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
@@ -8,6 +8,10 @@ import (
 )
 
 func Init(cfg *config.LoggerConfig) {
+	// Mask secrets in every log entry
+	logrus.StandardLogger().ReplaceHooks(logrus.LevelHooks{})
+	logrus.AddHook(redactHook{})
+
 	// Set log level
 	lvl, err := logrus.ParseLevel(cfg.LogLevel)
 	if err != nil {
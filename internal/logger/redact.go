@@ -0,0 +1,113 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Replacement of secret values
+const Mask = "******"
+
+// Values shorter than this are not masked, they'd mask too much unrelated text
+const minRedactLength = 4
+
+var redactor atomic.Pointer[strings.Replacer]
+
+/**
+ * Set the secret values to mask in logs and in text returned by Redact
+ * @param values values of all secrets, replacing those set before
+ * @description
+ * - Values are also masked as escaped in JSON strings, with and without HTML escaping,
+ *   so they are found in marshalled responses and JSON logs
+ */
+func SetRedactions(values []string) {
+	var forms []string
+	seen := make(map[string]bool)
+	for _, v := range values {
+		for _, f := range append([]string{v}, jsonEscaped(v)...) {
+			if !seen[f] {
+				seen[f] = true
+				forms = append(forms, f)
+			}
+		}
+	}
+	// Longer values first, so a secret containing another one is masked whole
+	sorted := forms
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+	var pairs []string
+	for _, v := range sorted {
+		if len(v) >= minRedactLength {
+			pairs = append(pairs, v, Mask)
+		}
+	}
+	if len(pairs) == 0 {
+		redactor.Store(nil)
+		return
+	}
+	redactor.Store(strings.NewReplacer(pairs...))
+}
+
+/**
+ * Get the forms of a value escaped in a JSON string, without the quotes
+ */
+func jsonEscaped(v string) []string {
+	var forms []string
+	for _, escapeHTML := range []bool{true, false} {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(escapeHTML)
+		if err := enc.Encode(v); err != nil {
+			continue
+		}
+		s := strings.TrimSuffix(buf.String(), "\n")
+		forms = append(forms, s[1:len(s)-1])
+	}
+	return forms
+}
+
+/**
+ * Check whether there are secret values to mask
+ */
+func Redacting() bool {
+	return redactor.Load() != nil
+}
+
+/**
+ * Mask secret values in a text
+ */
+func Redact(s string) string {
+	if r := redactor.Load(); r != nil {
+		return r.Replace(s)
+	}
+	return s
+}
+
+/**
+ * Logrus hook masking secret values in messages and string fields
+ */
+type redactHook struct{}
+
+func (redactHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (redactHook) Fire(entry *logrus.Entry) error {
+	if redactor.Load() == nil {
+		return nil
+	}
+	entry.Message = Redact(entry.Message)
+	for k, v := range entry.Data {
+		switch v := v.(type) {
+		case string:
+			entry.Data[k] = Redact(v)
+		case error:
+			entry.Data[k] = Redact(v.Error())
+		}
+	}
+	return nil
+}
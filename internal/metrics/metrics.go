@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+/**
+ * Counter with labels, exposed in the Prometheus text format
+ */
+type Counter struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]float64 // by label values joined with \xff
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []*Counter
+)
+
+/**
+ * Create and register a counter
+ * @param name metric name, e.g. "ai_prompt_shell_guard_verdicts_total"
+ * @param labels names of the labels, given in this order to Add and Inc
+ */
+func NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{name: name, help: help, labels: labels, values: make(map[string]float64)}
+	registryMu.Lock()
+	registry = append(registry, c)
+	registryMu.Unlock()
+	return c
+}
+
+/**
+ * Add to the counter of some label values
+ */
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if len(labelValues) != len(c.labels) {
+		panic(fmt.Sprintf("metric %s needs %d label values", c.name, len(c.labels)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	c.mu.Lock()
+	c.values[key] += v
+	c.mu.Unlock()
+}
+
+/**
+ * Add 1 to the counter of some label values
+ */
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+/**
+ * Write all registered metrics in the Prometheus text exposition format
+ */
+func Write(w io.Writer) error {
+	registryMu.Lock()
+	counters := append([]*Counter(nil), registry...)
+	registryMu.Unlock()
+
+	for _, c := range counters {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name); err != nil {
+			return err
+		}
+		c.mu.Lock()
+		keys := make([]string, 0, len(c.values))
+		for k := range c.values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var b strings.Builder
+		for _, k := range keys {
+			b.WriteString(c.name)
+			if len(c.labels) > 0 {
+				b.WriteByte('{')
+				for i, v := range strings.Split(k, "\xff") {
+					if i > 0 {
+						b.WriteByte(',')
+					}
+					fmt.Fprintf(&b, "%s=\"%s\"", c.labels[i], escapeLabel(v))
+				}
+				b.WriteByte('}')
+			}
+			fmt.Fprintf(&b, " %g\n", c.values[k])
+		}
+		c.mu.Unlock()
+		if _, err := io.WriteString(w, b.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
@@ -0,0 +1,236 @@
+package redact
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+/**
+ * Span of sensitive text found by a detector
+ * @description
+ * - Start and End are byte offsets of the span in the text
+ */
+type Match struct {
+	Detector string
+	Start    int
+	End      int
+}
+
+/**
+ * Detector of sensitive text
+ * @description
+ * - If Pattern has groups, the first group is the sensitive part of a match, e.g. the value of "secret=..."
+ * - Check, if set, confirms a candidate span, for what a pattern alone matches too loosely
+ */
+type Detector struct {
+	Name    string
+	Pattern *regexp.Regexp
+	Check   func(string) bool
+}
+
+// Built-in detectors, in the order they're tried; an earlier match wins over an overlapping later one
+var builtins = []Detector{
+	{Name: "private-key", Pattern: regexp.MustCompile(`-----BEGIN [A-Z0-9 ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z0-9 ]*PRIVATE KEY-----`)},
+	{Name: "jwt", Pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]*`)},
+	{Name: "aws-access-key", Pattern: regexp.MustCompile(`\b(?:AKIA|ASIA|AIDA|AROA)[0-9A-Z]{16}\b`)},
+	{Name: "aws-secret-key", Pattern: regexp.MustCompile(`(?i)aws.{0,20}?(?:secret|key).{0,20}?[=:]\s*["']?([A-Za-z0-9/+]{40})\b`)},
+	{Name: "email", Pattern: regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`)},
+	{Name: "ipv4", Pattern: regexp.MustCompile(`\b\d{1,3}(?:\.\d{1,3}){3}\b`), Check: func(s string) bool {
+		return net.ParseIP(s) != nil
+	}},
+	{Name: "ipv6", Pattern: regexp.MustCompile(`(?i)\b[0-9a-f]{0,4}(?::[0-9a-f]{0,4}){2,7}\b`), Check: func(s string) bool {
+		ip := net.ParseIP(s)
+		return ip != nil && ip.To4() == nil && strings.Count(s, ":") >= 2 && hexGroups(s) >= 3
+	}},
+	{Name: "high-entropy", Pattern: regexp.MustCompile(`[A-Za-z0-9+/_=-]{24,}`), Check: highEntropy},
+}
+
+/**
+ * Get the names of the built-in detectors
+ */
+func Builtins() []string {
+	names := make([]string, 0, len(builtins))
+	for _, d := range builtins {
+		names = append(names, d.Name)
+	}
+	return names
+}
+
+/**
+ * Get a built-in detector
+ * @return detector, false if there's no such detector
+ */
+func Builtin(name string) (Detector, bool) {
+	for _, d := range builtins {
+		if d.Name == name {
+			return d, true
+		}
+	}
+	return Detector{}, false
+}
+
+/**
+ * Compile a custom detector from a regex
+ */
+func Compile(name, pattern string) (Detector, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return Detector{}, fmt.Errorf("pattern %s: %v", name, err)
+	}
+	return Detector{Name: name, Pattern: re}, nil
+}
+
+/**
+ * Find the sensitive spans of a text
+ * @return spans ordered by position, without overlaps
+ */
+func Find(text string, detectors []Detector) []Match {
+	var found []Match
+	for _, d := range detectors {
+		for _, loc := range d.Pattern.FindAllStringSubmatchIndex(text, -1) {
+			start, end := loc[0], loc[1]
+			if len(loc) >= 4 && loc[2] >= 0 {
+				start, end = loc[2], loc[3]
+			}
+			if start == end {
+				continue
+			}
+			if d.Check != nil && !d.Check(text[start:end]) {
+				continue
+			}
+			if overlaps(found, start, end) {
+				continue
+			}
+			found = append(found, Match{Detector: d.Name, Start: start, End: end})
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].Start < found[j].Start })
+	return found
+}
+
+func overlaps(found []Match, start, end int) bool {
+	for _, m := range found {
+		if start < m.End && m.Start < end {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ * Replace spans of a text
+ * @param replace gives the replacement of a span from its detector and text
+ */
+func Replace(text string, matches []Match, replace func(detector, value string) string) string {
+	if len(matches) == 0 {
+		return text
+	}
+	var b strings.Builder
+	pos := 0
+	for _, m := range matches {
+		b.WriteString(text[pos:m.Start])
+		b.WriteString(replace(m.Detector, text[m.Start:m.End]))
+		pos = m.End
+	}
+	b.WriteString(text[pos:])
+	return b.String()
+}
+
+/**
+ * One-way mask of a span, e.g. "[REDACTED:email]"
+ */
+func Mask(detector, value string) string {
+	return "[REDACTED:" + detector + "]"
+}
+
+/**
+ * Reversible substitution of sensitive values by placeholders, e.g. "[EMAIL_1]"
+ * @description
+ * - The same value gets the same placeholder everywhere, so the LLM can still relate its occurrences
+ */
+type Vault struct {
+	placeholders map[string]string // by value
+	values       map[string]string // by placeholder
+	counts       map[string]int    // placeholders made, by detector
+}
+
+func NewVault() *Vault {
+	return &Vault{
+		placeholders: make(map[string]string),
+		values:       make(map[string]string),
+		counts:       make(map[string]int),
+	}
+}
+
+/**
+ * Get the placeholder of a value, making one if it's new
+ */
+func (v *Vault) Substitute(detector, value string) string {
+	if p, ok := v.placeholders[value]; ok {
+		return p
+	}
+	v.counts[detector]++
+	label := strings.ToUpper(strings.NewReplacer("-", "_", " ", "_").Replace(detector))
+	p := fmt.Sprintf("[%s_%d]", label, v.counts[detector])
+	v.placeholders[value] = p
+	v.values[p] = value
+	return p
+}
+
+/**
+ * Put the values back in place of their placeholders
+ */
+func (v *Vault) Restore(text string) string {
+	if len(v.values) == 0 || !strings.Contains(text, "[") {
+		return text
+	}
+	pairs := make([]string, 0, 2*len(v.values))
+	for p, value := range v.values {
+		pairs = append(pairs, p, value)
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
+
+/**
+ * Whether a token looks random enough to be a secret
+ * @description
+ * - It must mix letters and digits and carry at least 4 bits per character,
+ *   which hex digests (at most 4) and words written together rarely reach
+ */
+func highEntropy(s string) bool {
+	var letters, digits bool
+	counts := make(map[rune]int)
+	for _, r := range s {
+		counts[r]++
+		if unicode.IsDigit(r) {
+			digits = true
+		} else if unicode.IsLetter(r) {
+			letters = true
+		}
+	}
+	if !letters || !digits {
+		return false
+	}
+	var entropy float64
+	n := float64(len(s))
+	for _, c := range counts {
+		p := float64(c) / n
+		entropy -= p * math.Log2(p)
+	}
+	return entropy > 4.0
+}
+
+func hexGroups(s string) int {
+	n := 0
+	for _, g := range strings.Split(s, ":") {
+		if g != "" {
+			n++
+		}
+	}
+	return n
+}
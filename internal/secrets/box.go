@@ -0,0 +1,106 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Prefix of sealed values, naming the format version
+const sealedPrefix = "v1:"
+
+/**
+ * Encrypts and decrypts secrets with AES-256-GCM
+ * @description
+ * - Sealed values are "v1:" followed by base64 of the nonce and the ciphertext
+ * - The secret ID is authenticated with the value, so a sealed value can't be moved to another ID
+ */
+type Box struct {
+	aead cipher.AEAD
+}
+
+/**
+ * Create a box from a key
+ * @param key key material of any length; it is hashed with SHA-256 into the AES key
+ */
+func NewBox(key []byte) (*Box, error) {
+	sum := sha256.Sum256(key)
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Box{aead: aead}, nil
+}
+
+/**
+ * Get the key from the configuration
+ * @param key key given inline
+ * @param keyFile file holding the key, used if key is empty; surrounding whitespace is ignored
+ * @return key, nil if neither is set
+ */
+func LoadKey(key, keyFile string) ([]byte, error) {
+	if key != "" {
+		return []byte(key), nil
+	}
+	if keyFile == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secrets key file: %v", err)
+	}
+	data = []byte(strings.TrimSpace(string(data)))
+	if len(data) == 0 {
+		return nil, fmt.Errorf("secrets key file %s is empty", keyFile)
+	}
+	return data, nil
+}
+
+/**
+ * Encrypt a secret
+ * @param id ID of the secret
+ * @param plaintext value of the secret
+ * @return sealed value
+ */
+func (b *Box) Seal(id, plaintext string) (string, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := b.aead.Seal(nonce, nonce, []byte(plaintext), []byte(id))
+	return sealedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+/**
+ * Decrypt a secret
+ * @param id ID of the secret
+ * @param sealed value returned by Seal for the same ID
+ * @return value of the secret, error if it was sealed with another key or for another ID
+ */
+func (b *Box) Open(id, sealed string) (string, error) {
+	if !strings.HasPrefix(sealed, sealedPrefix) {
+		return "", fmt.Errorf("unknown secret format")
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(sealed, sealedPrefix))
+	if err != nil {
+		return "", fmt.Errorf("invalid sealed secret: %v", err)
+	}
+	n := b.aead.NonceSize()
+	if len(data) < n {
+		return "", fmt.Errorf("invalid sealed secret")
+	}
+	plaintext, err := b.aead.Open(nil, data[:n], data[n:], []byte(id))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt secret, wrong key?")
+	}
+	return string(plaintext), nil
+}
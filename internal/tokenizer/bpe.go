@@ -0,0 +1,160 @@
+package tokenizer
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Pre-tokenization pattern of cl100k_base. The original "\s+(?!\S)" alternative
+// needs look-ahead, which RE2 lacks, so BPE.pretokenize emulates it.
+const cl100kPattern = `^(?:(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+)`
+
+/**
+ * Byte-pair encoding tokenizer compatible with tiktoken vocabularies
+ */
+type BPE struct {
+	ranks   map[string]int
+	pattern *regexp.Regexp
+}
+
+/**
+ * Load BPE tokenizer from a tiktoken vocabulary file
+ * @param path vocabulary file, one "base64(token) rank" pair per line, e.g. cl100k_base.tiktoken
+ * @param encoding encoding name, only cl100k_base is supported
+ * @return BPE tokenizer
+ * @return error if the file can't be read or is malformed
+ * @example
+ * bpe, err := tokenizer.LoadBPE("./vocab/cl100k_base.tiktoken", "cl100k_base")
+ */
+func LoadBPE(path, encoding string) (*BPE, error) {
+	if encoding != "" && encoding != "cl100k_base" {
+		return nil, fmt.Errorf("unsupported encoding: %s", encoding)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ranks := make(map[string]int)
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: malformed vocabulary line", path, lineNo)
+		}
+		token, err := base64.StdEncoding.DecodeString(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, lineNo, err)
+		}
+		rank, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, lineNo, err)
+		}
+		ranks[string(token)] = rank
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return &BPE{
+		ranks:   ranks,
+		pattern: regexp.MustCompile(cl100kPattern),
+	}, nil
+}
+
+func (b *BPE) Count(text string) int {
+	n := 0
+	for _, piece := range b.pretokenize(text) {
+		n += len(b.merge(piece))
+	}
+	return n
+}
+
+func (b *BPE) Split(text string) []string {
+	var tokens []string
+	for _, piece := range b.pretokenize(text) {
+		tokens = append(tokens, b.merge(piece)...)
+	}
+	return tokens
+}
+
+/**
+ * Split text into pieces that are encoded independently
+ * @param text input text
+ * @return pieces in order
+ * @description
+ * - A whitespace run followed by non-whitespace gives its last character to the next piece,
+ *   matching "\s+(?!\S)" in the original pattern
+ */
+func (b *BPE) pretokenize(text string) []string {
+	var pieces []string
+	for len(text) > 0 {
+		loc := b.pattern.FindStringIndex(text)
+		n := 1
+		if loc != nil && loc[1] > 0 {
+			n = loc[1]
+		}
+		match := text[:n]
+		if n < len(text) && isSpaceOnly(match) && !strings.ContainsAny(match, "\r\n") {
+			if _, size := utf8.DecodeLastRuneInString(match); size < len(match) {
+				n -= size
+				match = text[:n]
+			}
+		}
+		pieces = append(pieces, match)
+		text = text[n:]
+	}
+	return pieces
+}
+
+func isSpaceOnly(s string) bool {
+	for _, r := range s {
+		if !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+/**
+ * Apply byte-pair merges to one piece
+ * @param piece pre-tokenized piece
+ * @return token byte strings
+ */
+func (b *BPE) merge(piece string) []string {
+	if _, ok := b.ranks[piece]; ok {
+		return []string{piece}
+	}
+	parts := make([]string, len(piece))
+	for i := 0; i < len(piece); i++ {
+		parts[i] = piece[i : i+1]
+	}
+	for len(parts) > 1 {
+		best, bestRank := -1, 0
+		for i := 0; i < len(parts)-1; i++ {
+			rank, ok := b.ranks[parts[i]+parts[i+1]]
+			if ok && (best < 0 || rank < bestRank) {
+				best, bestRank = i, rank
+			}
+		}
+		if best < 0 {
+			break
+		}
+		parts[best] = parts[best] + parts[best+1]
+		parts = append(parts[:best+1], parts[best+2:]...)
+	}
+	return parts
+}
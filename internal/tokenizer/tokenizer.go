@@ -0,0 +1,113 @@
+package tokenizer
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+/**
+ * Tokenizer splits text into model tokens
+ * @description
+ * - Count returns the number of tokens in text
+ * - Split returns the text of each token in order; joining them restores the input
+ */
+type Tokenizer interface {
+	Count(text string) int
+	Split(text string) []string
+}
+
+// Truncation strategies, named after the part of the text that is kept
+const (
+	KeepHead   = "head"
+	KeepTail   = "tail"
+	KeepMiddle = "middle"
+)
+
+// Marker inserted where text is cut out by KeepMiddle
+const ElisionMarker = "\n...\n"
+
+var defaultTokenizer Tokenizer = Approx{}
+
+/**
+ * Get the process-wide tokenizer
+ * @return tokenizer set by SetDefault, or the Approx tokenizer
+ */
+func Default() Tokenizer {
+	return defaultTokenizer
+}
+
+/**
+ * Replace the process-wide tokenizer
+ * @param t tokenizer to use, nil restores the Approx tokenizer
+ */
+func SetDefault(t Tokenizer) {
+	if t == nil {
+		t = Approx{}
+	}
+	defaultTokenizer = t
+}
+
+/**
+ * Truncate text to at most maxTokens tokens
+ * @param t tokenizer used to split text
+ * @param text text to truncate
+ * @param maxTokens number of tokens to keep
+ * @param strategy KeepHead keeps the beginning, KeepTail keeps the end,
+ *        KeepMiddle keeps both ends and cuts out the middle
+ * @return truncated text, or text itself if it already fits
+ * @description
+ * - Token boundaries may split multi-byte characters; broken characters at cut points are dropped
+ * @example
+ * s := tokenizer.Truncate(tokenizer.Default(), code, 1000, tokenizer.KeepMiddle)
+ */
+func Truncate(t Tokenizer, text string, maxTokens int, strategy string) string {
+	if maxTokens <= 0 {
+		return ""
+	}
+	pieces := t.Split(text)
+	if len(pieces) <= maxTokens {
+		return text
+	}
+	var result string
+	switch strategy {
+	case KeepTail:
+		result = strings.Join(pieces[len(pieces)-maxTokens:], "")
+	case KeepMiddle:
+		head := maxTokens / 2
+		tail := maxTokens - head
+		result = strings.ToValidUTF8(strings.Join(pieces[:head], ""), "") +
+			ElisionMarker +
+			strings.ToValidUTF8(strings.Join(pieces[len(pieces)-tail:], ""), "")
+	default:
+		result = strings.Join(pieces[:maxTokens], "")
+	}
+	return strings.ToValidUTF8(result, "")
+}
+
+/**
+ * Approximate tokenizer assuming about 4 bytes per token
+ * @description
+ * - Used when no vocabulary file is configured; tends to over-count for English and under-count for CJK text
+ */
+type Approx struct{}
+
+func (Approx) Count(text string) int {
+	return (len(text) + 3) / 4
+}
+
+func (Approx) Split(text string) []string {
+	var pieces []string
+	for len(text) > 0 {
+		n := 4
+		if n >= len(text) {
+			n = len(text)
+		} else {
+			for n < len(text) && !utf8.RuneStart(text[n]) {
+				n++
+			}
+		}
+		pieces = append(pieces, text[:n])
+		text = text[n:]
+	}
+	return pieces
+}
@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Number of unchanged lines shown around each change
+const diffContext = 3
+
+/**
+ * Compare two texts line by line
+ * @param expected expected text
+ * @param actual actual text
+ * @return unified diff from expected to actual, empty if the texts are equal
+ */
+func LineDiff(expected, actual string) string {
+	if expected == actual {
+		return ""
+	}
+	a := strings.Split(expected, "\n")
+	b := strings.Split(actual, "\n")
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	type edit struct {
+		op   byte
+		a, b int
+	}
+	var edits []edit
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			edits = append(edits, edit{' ', i, j})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			edits = append(edits, edit{'-', i, j})
+			i++
+		default:
+			edits = append(edits, edit{'+', i, j})
+			j++
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("--- expected\n+++ actual\n")
+	for start := 0; start < len(edits); {
+		if edits[start].op == ' ' {
+			start++
+			continue
+		}
+		// Extend the hunk while changes are close enough to share context
+		from := start - diffContext
+		if from < 0 {
+			from = 0
+		}
+		end := start
+		for k := start; k < len(edits) && k-end <= 2*diffContext; k++ {
+			if edits[k].op != ' ' {
+				end = k
+			}
+		}
+		to := end + diffContext + 1
+		if to > len(edits) {
+			to = len(edits)
+		}
+		countA, countB := 0, 0
+		for _, e := range edits[from:to] {
+			if e.op != '+' {
+				countA++
+			}
+			if e.op != '-' {
+				countB++
+			}
+		}
+		fmt.Fprintf(&sb, "@@ -%d,%d +%d,%d @@\n", edits[from].a+1, countA, edits[from].b+1, countB)
+		for _, e := range edits[from:to] {
+			switch e.op {
+			case ' ':
+				sb.WriteString(" " + a[e.a] + "\n")
+			case '-':
+				sb.WriteString("-" + a[e.a] + "\n")
+			case '+':
+				sb.WriteString("+" + b[e.b] + "\n")
+			}
+		}
+		start = to
+	}
+	return sb.String()
+}
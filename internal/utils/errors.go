@@ -5,15 +5,17 @@ import (
 )
 
 var (
-	ErrKeyNotFound     = NewHttpError(http.StatusNotFound, "key not found")
-	ErrPromptNotFound  = NewHttpError(http.StatusNotFound, "prompt not found")
-	ErrEnvironNotFound = NewHttpError(http.StatusNotFound, "environment not found")
-	ErrToolNotFound    = NewHttpError(http.StatusNotFound, "tool not found")
-	ErrRedisError      = NewHttpError(http.StatusInternalServerError, "redis error")
-	ErrPromptInvalid   = NewHttpError(http.StatusInternalServerError, "prompt invalid")
-	ErrRenderTimeout   = NewHttpError(http.StatusGatewayTimeout, "render timeout")
-	ErrToolCallFailed  = NewHttpError(http.StatusInternalServerError, "tool call failed")
-	ErrBug             = NewHttpError(http.StatusInternalServerError, "bug")
+	ErrKeyNotFound       = NewHttpError(http.StatusNotFound, "key not found")
+	ErrPromptNotFound    = NewHttpError(http.StatusNotFound, "prompt not found")
+	ErrEnvironNotFound   = NewHttpError(http.StatusNotFound, "environment not found")
+	ErrSecretNotFound    = NewHttpError(http.StatusNotFound, "secret not found")
+	ErrToolNotFound      = NewHttpError(http.StatusNotFound, "tool not found")
+	ErrExtensionNotFound = NewHttpError(http.StatusNotFound, "extension not found")
+	ErrStorageError      = NewHttpError(http.StatusInternalServerError, "storage error")
+	ErrPromptInvalid     = NewHttpError(http.StatusInternalServerError, "prompt invalid")
+	ErrRenderTimeout     = NewHttpError(http.StatusGatewayTimeout, "render timeout")
+	ErrToolCallFailed    = NewHttpError(http.StatusInternalServerError, "tool call failed")
+	ErrBug               = NewHttpError(http.StatusInternalServerError, "bug")
 )
 
 /**
@@ -86,3 +86,39 @@ func ValidateArgs(args []interface{}, schema interface{}) error {
 
 	return nil
 }
+
+/**
+ * Validate any JSON value against JSON schema
+ * @param value Value to validate
+ * @param schema JSON schema definition
+ * @return Error if validation fails
+ */
+func ValidateValue(value interface{}, schema interface{}) error {
+	valueJSON, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal value: %w", err)
+	}
+
+	schemaJSON, err := json.Marshal(schema)
+	if err != nil {
+		return fmt.Errorf("failed to marshal schema: %w", err)
+	}
+
+	schemaLoader := gojsonschema.NewBytesLoader(schemaJSON)
+	documentLoader := gojsonschema.NewBytesLoader(valueJSON)
+
+	result, err := gojsonschema.Validate(schemaLoader, documentLoader)
+	if err != nil {
+		return fmt.Errorf("validation error: %w", err)
+	}
+
+	if !result.Valid() {
+		var errs []string
+		for _, desc := range result.Errors() {
+			errs = append(errs, desc.String())
+		}
+		return fmt.Errorf("invalid value: %v", errs)
+	}
+
+	return nil
+}
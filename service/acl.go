@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+
+	"github.com/zgsm-ai/ai-prompt-shell/dao"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/auth"
+)
+
+/**
+ * Check whether a caller may see and use a prompt
+ * @param p prompt
+ * @param id caller, nil if authentication is disabled
+ * @return true if the prompt has no ACL, the caller is an admin, or the caller matches an ACL entry
+ */
+func promptAllowed(p dao.Prompt, id *auth.Identity) bool {
+	if p.ACL == nil || id == nil || id.HasRole(auth.RoleAdmin) {
+		return true
+	}
+	for _, u := range p.ACL.Users {
+		if u == id.Subject {
+			return true
+		}
+	}
+	for _, g := range p.ACL.Groups {
+		if id.InGroup(g) {
+			return true
+		}
+	}
+	for _, r := range p.ACL.Roles {
+		if id.HasRole(r) {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ * Check whether the caller of a request may see and use a prompt
+ * @param ctx context carrying the caller identity
+ * @param prompt_id ID of the prompt
+ * @return false if the prompt doesn't exist or is hidden from the caller by its ACL
+ * @description
+ * - Hidden prompts are reported as not found, so their existence isn't revealed
+ */
+func PromptVisible(ctx context.Context, prompt_id string) bool {
+	p, origin := prompts.Get(prompt_id)
+	if origin == dao.PromptOrigin_Notexist {
+		return false
+	}
+	return promptAllowed(p, auth.FromContext(ctx))
+}
+
+/**
+ * Leave out the contributed prompts of an extension hidden from the caller of a request
+ * @param ctx context carrying the caller identity
+ * @param ext extension, not modified
+ * @return extension showing only the prompts the caller may see
+ */
+func VisibleExtension(ctx context.Context, ext dao.PromptExtension) dao.PromptExtension {
+	id := auth.FromContext(ctx)
+	if id == nil {
+		return ext
+	}
+	visible := make([]dao.Prompt, 0, len(ext.Contributes.Prompts))
+	for _, p := range ext.Contributes.Prompts {
+		if promptAllowed(p, id) {
+			visible = append(visible, p)
+		}
+	}
+	ext.Contributes.Prompts = visible
+	return ext
+}
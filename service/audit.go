@@ -0,0 +1,372 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/zgsm-ai/ai-prompt-shell/dao"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/auth"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/config"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/logger"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/utils"
+)
+
+// Types of audit records
+const (
+	AuditChange = "change" // write to prompts, tools, partials, extensions, shared variables or secrets
+	AuditChat   = "chat"   // chat with a prompt
+)
+
+// Default and maximum number of records returned by QueryAudit
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+var (
+	auditLog     dao.AuditLog
+	auditPayload bool
+)
+
+/**
+ * Record of the audit log
+ * @description
+ * - Actor is the authenticated caller, else the user of the scope, else "anonymous"; "sync" for automatic syncs
+ * - Changes have Kind, Target, Action and Diff, a unified diff of the stored JSON; secrets have no diff
+ * - Chats have Prompt, Version, Model, Usage, and Payload when payloads are audited; Cached if answered by the response cache,
+ *   Session for the turns of a session
+ */
+type AuditRecord struct {
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type" enums:"change,chat"`
+	Actor   string    `json:"actor"`
+	TraceID string    `json:"trace_id,omitempty"`
+
+	Kind   string `json:"kind,omitempty"`
+	Target string `json:"target,omitempty"`
+	Action string `json:"action,omitempty" enums:"create,update,delete"`
+	Diff   string `json:"diff,omitempty"`
+
+	Prompt   string        `json:"prompt,omitempty"`
+	Version  string        `json:"version,omitempty"`
+	Model    string        `json:"model,omitempty"`
+	Scope    *dao.EnvScope `json:"scope,omitempty"`
+	Usage    *AuditUsage   `json:"usage,omitempty"`
+	Duration int64         `json:"duration_ms,omitempty"`
+	Cached   bool          `json:"cached,omitempty"`
+	Session  string        `json:"session,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Payload  *AuditPayload `json:"payload,omitempty"`
+}
+
+/**
+ * Tokens used by an audited chat
+ */
+type AuditUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+/**
+ * Messages sent to the LLM by an audited chat, after redaction, and its answers
+ */
+type AuditPayload struct {
+	Messages []dao.Message `json:"messages"`
+	Answers  []string      `json:"answers,omitempty"`
+}
+
+/**
+ * Filter of audit records; empty fields match everything
+ */
+type AuditFilter struct {
+	Type    string    `form:"type"`
+	Actor   string    `form:"actor"`
+	Kind    string    `form:"kind"`
+	Target  string    `form:"target"`
+	Prompt  string    `form:"prompt"`
+	Model   string    `form:"model"`
+	TraceID string    `form:"trace_id"`
+	Session string    `form:"session"`
+	Since   time.Time `form:"since" time_format:"2006-01-02T15:04:05Z07:00"`
+	Until   time.Time `form:"until" time_format:"2006-01-02T15:04:05Z07:00"`
+	Limit   int       `form:"limit"`
+}
+
+func (f *AuditFilter) match(r *AuditRecord) bool {
+	return (f.Type == "" || r.Type == f.Type) &&
+		(f.Actor == "" || r.Actor == f.Actor) &&
+		(f.Kind == "" || r.Kind == f.Kind) &&
+		(f.Target == "" || r.Target == f.Target) &&
+		(f.Prompt == "" || r.Prompt == f.Prompt) &&
+		(f.Model == "" || r.Model == f.Model) &&
+		(f.TraceID == "" || r.TraceID == f.TraceID) &&
+		(f.Session == "" || r.Session == f.Session) &&
+		(f.Since.IsZero() || !r.Time.Before(f.Since)) &&
+		(f.Until.IsZero() || r.Time.Before(f.Until))
+}
+
+/**
+ * Initialize the audit log from configuration
+ * @throws error if the sink is unknown or can't be opened
+ */
+func initAudit(c *config.Config) error {
+	var err error
+	switch c.Audit.Sink {
+	case "":
+		auditLog = nil
+	case "redis":
+		auditLog, err = dao.NewRedisAuditLog(c.Audit.MaxLen)
+	case "file":
+		auditLog, err = dao.NewFileAuditLog(c.Audit.File, c.Audit.MaxBytes, c.Audit.MaxFiles)
+	default:
+		err = fmt.Errorf("unknown sink %s", c.Audit.Sink)
+	}
+	if err != nil {
+		return fmt.Errorf("audit: %v", err)
+	}
+	auditPayload = c.Audit.Payload
+	return nil
+}
+
+type traceIDKey struct{}
+type auditActorKey struct{}
+
+/**
+ * Attach the trace ID of a request to a context, recorded by the audit log
+ */
+func WithTraceID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, traceIDKey{}, id)
+}
+
+/**
+ * Get the trace ID carried by a context
+ * @return trace ID, empty if none
+ */
+func TraceIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(traceIDKey{}).(string)
+	return id
+}
+
+/**
+ * Attach the actor of work done without a caller, such as automatic syncs, to a context
+ */
+func withAuditActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, auditActorKey{}, actor)
+}
+
+func auditActor(ctx context.Context) string {
+	if id := auth.FromContext(ctx); id != nil {
+		return id.Subject
+	}
+	if actor, ok := ctx.Value(auditActorKey{}).(string); ok {
+		return actor
+	}
+	if user := EnvScopeFrom(ctx).User; user != "" {
+		return user
+	}
+	return "anonymous"
+}
+
+/**
+ * Append a record to the audit log, if auditing is enabled
+ * @description
+ * - Failures are logged, they don't fail the audited operation
+ */
+func writeAudit(ctx context.Context, r AuditRecord) {
+	if auditLog == nil {
+		return
+	}
+	r.Time = time.Now().UTC()
+	r.Actor = auditActor(ctx)
+	r.TraceID = TraceIDFrom(ctx)
+	data, err := json.Marshal(r)
+	if err != nil {
+		logrus.Warnf("Encode audit record failed: %v", err)
+		return
+	}
+	if logger.Redacting() {
+		data = []byte(logger.Redact(string(data)))
+	}
+	if err := auditLog.Append(data); err != nil {
+		logrus.Warnf("Write audit record failed: %v", err)
+	}
+}
+
+/**
+ * Audit a change of a stored item
+ * @param kind kind of item, as in bundles: prompts, tools, partials, extensions, environs, scopes or secrets
+ * @param before value before the change, nil if it's created
+ * @param after value after the change, nil if it's deleted
+ */
+func auditChange(ctx context.Context, kind, target string, before, after any) {
+	if r, ok := changeRecord(kind, target, before, after); ok {
+		writeAudit(ctx, r)
+	}
+}
+
+/**
+ * Audit a change of a shared variable, or of its override in a scope
+ * @param scope layer changed, empty for the global variable
+ */
+func auditEnvironChange(ctx context.Context, scope dao.EnvScope, environ_id string, before, after any) {
+	if r, ok := changeRecord("environs", environ_id, before, after); ok {
+		if scope != (dao.EnvScope{}) {
+			r.Scope = &scope
+		}
+		writeAudit(ctx, r)
+	}
+}
+
+/**
+ * Make the record of a change
+ * @return record, false if auditing is disabled or nothing changed
+ */
+func changeRecord(kind, target string, before, after any) (AuditRecord, bool) {
+	r := AuditRecord{Type: AuditChange, Kind: kind, Target: target, Action: ChangeUpdate}
+	if auditLog == nil {
+		return r, false
+	}
+	if before == nil {
+		r.Action = ChangeCreate
+	} else if after == nil {
+		r.Action = ChangeDelete
+	}
+	if kind != "secrets" {
+		r.Diff = utils.LineDiff(auditJSON(before), auditJSON(after))
+		if r.Action == ChangeUpdate && r.Diff == "" {
+			return r, false
+		}
+	}
+	return r, true
+}
+
+func auditJSON(v any) string {
+	if v == nil {
+		return ""
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+/**
+ * Audit a chat
+ * @param messages messages sent to the LLM, nil if the chat failed before
+ * @param resp response of the LLM, nil if it wasn't called or failed
+ */
+func auditChat(ctx context.Context, promptId string, req ChatPromptRequest, messages []dao.Message,
+	resp *ChatResponse, err error, start time.Time) {
+	if auditLog == nil {
+		return
+	}
+	r := AuditRecord{
+		Type:     AuditChat,
+		Prompt:   promptId,
+		Version:  auditPromptVersion(promptId),
+		Model:    req.Model,
+		Duration: time.Since(start).Milliseconds(),
+	}
+	if scope := EnvScopeFrom(ctx); scope != (dao.EnvScope{}) {
+		r.Scope = &scope
+	}
+	if id, ok := ctx.Value(sessionIDKey{}).(string); ok {
+		r.Session = id
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	if resp != nil {
+		r.Cached = resp.Cached
+		r.Usage = &AuditUsage{
+			PromptTokens:     resp.Usage.PromptTokens,
+			CompletionTokens: resp.Usage.CompletionTokens,
+			TotalTokens:      resp.Usage.TotalTokens,
+		}
+	}
+	if auditPayload && messages != nil {
+		r.Payload = &AuditPayload{Messages: messages}
+		if resp != nil {
+			for _, c := range resp.Choices {
+				r.Payload.Answers = append(r.Payload.Answers, c.Message.Content)
+			}
+		}
+	}
+	writeAudit(ctx, r)
+}
+
+/**
+ * Get the version of a prompt as audited
+ * @return commit it was published from by sync, else "<extension>@<version>" for extension prompts,
+ *         else a hash of its content
+ */
+func auditPromptVersion(prompt_id string) string {
+	if v := PromptVersion(prompt_id); v != "" {
+		return v
+	}
+	p, ok := prompts.Loaded(prompt_id)
+	if !ok {
+		return ""
+	}
+	if p.Origin == dao.PromptOrigin_Extension {
+		if ext, ok := extensions.Get(p.Extension); ok {
+			return p.Extension + "@" + ext.Version
+		}
+	}
+	return "sha256:" + promptDigest(prompt_id)
+}
+
+/**
+ * Get a short hash of the content of a prompt, changing with any edit of it
+ * @return hash, empty if the prompt doesn't exist
+ */
+func promptDigest(prompt_id string) string {
+	p, ok := prompts.Loaded(prompt_id)
+	if !ok {
+		return ""
+	}
+	data, _ := json.Marshal(p.Prompt)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:6])
+}
+
+/**
+ * Query the audit log
+ * @return matching records, newest first
+ * @throws 400 error if auditing is disabled
+ */
+func QueryAudit(f AuditFilter) ([]AuditRecord, error) {
+	if auditLog == nil {
+		return nil, utils.NewHttpError(http.StatusBadRequest, "audit is not enabled")
+	}
+	if f.Limit <= 0 {
+		f.Limit = defaultAuditLimit
+	}
+	if f.Limit > maxAuditLimit {
+		f.Limit = maxAuditLimit
+	}
+	records := []AuditRecord{}
+	err := auditLog.Scan(func(data []byte) bool {
+		var r AuditRecord
+		if err := json.Unmarshal(data, &r); err != nil {
+			return true
+		}
+		if !f.Since.IsZero() && r.Time.Before(f.Since) {
+			// Records are newest first, the rest are older still
+			return false
+		}
+		if f.match(&r) {
+			records = append(records, r)
+		}
+		return len(records) < f.Limit
+	})
+	return records, err
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/zgsm-ai/ai-prompt-shell/dao"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/tokenizer"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/utils"
+	"net/http"
+	"sync"
+)
+
+// Number of trim-and-render rounds before giving up on fitting a budget
+const maxBudgetAttempts = 5
+
+/**
+ * Render prompt, trimming truncatable args so the request fits the model context window
+ * @param ctx context of the render, as RenderPrompt
+ * @param prompt_id ID of prompt to render
+ * @param args input args for template
+ * @param model model the rendered prompt will be sent to
+ * @param maxTokens tokens reserved for the completion
+ * @return type of rendered content, rendered content and error, as RenderPrompt
+ * @description
+ * - Prompts without a budget are rendered unchanged
+ * - The input limit is the model context size minus maxTokens, further capped by Budget.MaxInputTokens
+ * - Over-budget renders trim truncatable args in proportion to their size and render again;
+ *   tools are called once per tool and args across these renders, see toolCallCache
+ * @throws
+ * - 400 error if maxTokens leaves no room for the prompt in the model context
+ * - 413 error if the prompt can't be fitted by trimming truncatable args
+ */
+func RenderPromptWithBudget(ctx context.Context, prompt_id string, args map[string]interface{}, model string, maxTokens int) (string, interface{}, error) {
+	prompt, _ := prompts.Get(prompt_id)
+	if prompt.Budget != nil {
+		ctx = context.WithValue(ctx, toolCallCacheKey{}, &toolCallCache{results: make(map[string]interface{})})
+	}
+	kind, data, err := RenderPrompt(ctx, prompt_id, args)
+	if err != nil {
+		return kind, data, err
+	}
+	if prompt.Budget == nil {
+		return kind, data, nil
+	}
+
+	limit, err := inputLimit(prompt, model, maxTokens)
+	if err != nil {
+		return "", nil, err
+	}
+	count := CountMessages(toChatMessages(kind, data)).Total
+	for attempt := 0; count > limit; attempt++ {
+		trimmed, ok := trimArgs(args, prompt.Budget.Truncatable, count-limit)
+		if !ok || attempt >= maxBudgetAttempts {
+			return "", nil, utils.RethrowError(http.StatusRequestEntityTooLarge,
+				fmt.Errorf("prompt %s needs %d tokens, exceeding the budget of %d tokens", prompt_id, count, limit))
+		}
+		args = trimmed
+		if kind, data, err = RenderPrompt(ctx, prompt_id, args); err != nil {
+			return kind, data, err
+		}
+		count = CountMessages(toChatMessages(kind, data)).Total
+	}
+	return kind, data, nil
+}
+
+type toolCallCacheKey struct{}
+
+/**
+ * Results of the tool calls of a budgeted render, reused when it is rendered again with trimmed args
+ * @description
+ * - Results are keyed by tool ID and args, so tools, fixtures and the guard see each call once
+ * - Failed calls are not kept, they fail the render anyway
+ */
+type toolCallCache struct {
+	mu      sync.Mutex
+	results map[string]interface{}
+}
+
+func toolCallCacheFrom(ctx context.Context) *toolCallCache {
+	c, _ := ctx.Value(toolCallCacheKey{}).(*toolCallCache)
+	return c
+}
+
+/**
+ * Get the result of a call made before, or make it
+ * @param toolId ID of the tool
+ * @param args arguments of the call
+ * @param call makes the call if its result isn't kept
+ */
+func (c *toolCallCache) call(toolId string, args []interface{}, call func() (interface{}, error)) (interface{}, error) {
+	data, err := json.Marshal(args)
+	if err != nil {
+		return call()
+	}
+	key := toolId + "\x00" + string(data)
+	c.mu.Lock()
+	result, ok := c.results[key]
+	c.mu.Unlock()
+	if ok {
+		return result, nil
+	}
+	result, err = call()
+	if err == nil {
+		c.mu.Lock()
+		c.results[key] = result
+		c.mu.Unlock()
+	}
+	return result, err
+}
+
+/**
+ * Get the number of tokens a prompt may send to a model
+ * @param prompt prompt whose budget caps the limit
+ * @param model model the prompt is sent to
+ * @param maxTokens tokens reserved for the completion
+ * @return model context size minus maxTokens, capped by Budget.MaxInputTokens
+ * @throws 400 error if maxTokens is not less than the model context size
+ */
+func inputLimit(prompt dao.Prompt, model string, maxTokens int) (int, error) {
+	size := ContextSize(model)
+	if maxTokens >= size {
+		return 0, utils.NewHttpError(http.StatusBadRequest,
+			fmt.Sprintf("max_tokens %d is too large for the context size %d of model %s", maxTokens, size, model))
+	}
+	limit := size - maxTokens
+	if prompt.Budget != nil && prompt.Budget.MaxInputTokens > 0 && prompt.Budget.MaxInputTokens < limit {
+		limit = prompt.Budget.MaxInputTokens
+	}
+	return limit, nil
+}
+
+/**
+ * Trim truncatable string args to shed excess tokens
+ * @param args input args, left unchanged
+ * @param truncatable arg names mapped to the part to keep (head/tail/middle)
+ * @param excess number of tokens to shed
+ * @return copy of args with truncated values
+ * @return false if there is nothing left to trim
+ */
+func trimArgs(args map[string]interface{}, truncatable map[string]string, excess int) (map[string]interface{}, bool) {
+	sizes := make(map[string]int)
+	total := 0
+	for name := range truncatable {
+		if text, ok := args[name].(string); ok {
+			if n := CountTokens(text); n > 0 {
+				sizes[name] = n
+				total += n
+			}
+		}
+	}
+	if total == 0 {
+		return args, false
+	}
+
+	trimmed := make(map[string]interface{}, len(args))
+	for k, v := range args {
+		trimmed[k] = v
+	}
+	for name, n := range sizes {
+		cut := (excess*n+total-1)/total + 1
+		keep := n - cut
+		if keep < 0 {
+			keep = 0
+		}
+		trimmed[name] = tokenizer.Truncate(tokenizer.Default(), args[name].(string), keep, truncatable[name])
+	}
+	return trimmed, true
+}
+
+/**
+ * Convert a render result into the chat messages sent to the LLM
+ * @param kind kind of rendered content ("prompt" or "messages")
+ * @param data rendered text or messages
+ * @return chat messages; a "prompt" result becomes the user message after a default system message
+ */
+func toChatMessages(kind string, data interface{}) []dao.Message {
+	if kind == "prompt" {
+		return []dao.Message{
+			{
+				Role:    "system",
+				Content: "You are a helpful assistant.",
+			},
+			{
+				Role:    "user",
+				Content: data.(string),
+			},
+		}
+	}
+	return data.([]dao.Message)
+}
@@ -0,0 +1,555 @@
+package service
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/zgsm-ai/ai-prompt-shell/dao"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/auth"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/utils"
+)
+
+// Version of the bundle format written by ExportBundle
+const BundleVersion = 1
+
+// Import modes
+const (
+	ImportMerge   = "merge"
+	ImportReplace = "replace"
+)
+
+// Actions of bundle changes
+const (
+	ChangeCreate = "create"
+	ChangeUpdate = "update"
+	ChangeDelete = "delete"
+)
+
+// Name of the manifest in tar bundles
+const bundleManifest = "bundle.json"
+
+/**
+ * Kinds of items in a bundle, in the order they are imported
+ * @description
+ * - Each kind has its storage prefix, the field of Bundle holding its items, and how its items are exported
+ */
+var bundleKinds = []struct {
+	name   string
+	prefix string
+	field  bundleField
+	export func() (map[string]any, error)
+}{
+	{"environs", dao.PREFIX_ENVIRONS, bundleMap[any](func(b *Bundle) *map[string]any { return &b.Environs }), exportStored(dao.PREFIX_ENVIRONS)},
+	{"scopes", dao.PREFIX_SCOPES, bundleMap[map[string]any](func(b *Bundle) *map[string]map[string]any { return &b.Scopes }), exportStored(dao.PREFIX_SCOPES)},
+	{"secrets", dao.PREFIX_SECRETS, bundleMap[string](func(b *Bundle) *map[string]string { return &b.Secrets }), exportStored(dao.PREFIX_SECRETS)},
+	{"tools", dao.PREFIX_TOOLS, bundleMap[dao.Tool](func(b *Bundle) *map[string]dao.Tool { return &b.Tools }), exportCached(tools.All)},
+	{"partials", dao.PREFIX_PARTIALS, bundleMap[dao.Partial](func(b *Bundle) *map[string]dao.Partial { return &b.Partials }), exportCached(partials.All)},
+	{"extensions", dao.PREFIX_EXTENSIONS, bundleMap[dao.PromptExtension](func(b *Bundle) *map[string]dao.PromptExtension { return &b.Extensions }), exportCached(extensions.All)},
+	{"prompts", dao.PREFIX_TEMPLATES, bundleMap[dao.Prompt](func(b *Bundle) *map[string]dao.Prompt { return &b.Prompts }), exportDirectPrompts},
+}
+
+/**
+ * Access to the items of one kind in a Bundle, whatever their type
+ */
+type bundleField interface {
+	// Get the items, nil if the bundle doesn't have the kind
+	get(b *Bundle) map[string]any
+	// Replace the items, nil removing the kind from the bundle; values of another type are skipped
+	set(b *Bundle, items map[string]any)
+	// Decode an item from JSON
+	decode(data []byte) (any, error)
+}
+
+/**
+ * Field of Bundle mapping IDs to items of type T, given by a function returning its address
+ */
+type bundleMap[T any] func(b *Bundle) *map[string]T
+
+func (f bundleMap[T]) get(b *Bundle) map[string]any {
+	m := *f(b)
+	if m == nil {
+		return nil
+	}
+	items := make(map[string]any, len(m))
+	for id, v := range m {
+		items[id] = v
+	}
+	return items
+}
+
+func (f bundleMap[T]) set(b *Bundle, items map[string]any) {
+	if items == nil {
+		*f(b) = nil
+		return
+	}
+	m := make(map[string]T, len(items))
+	for id, v := range items {
+		if t, ok := v.(T); ok {
+			m[id] = t
+		}
+	}
+	*f(b) = m
+}
+
+func (f bundleMap[T]) decode(data []byte) (any, error) {
+	var v T
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+/**
+ * Export the items of a kind as stored
+ * @description
+ * - Used for shared variables, whose cache also holds the nested maps built from their dot paths,
+ *   and for scopes and secrets, which aren't cached as stored
+ */
+func exportStored(prefix string) func() (map[string]any, error) {
+	return func() (map[string]any, error) {
+		values, err := dao.LoadJsons(prefix)
+		if err != nil {
+			return nil, err
+		}
+		items := make(map[string]any, len(values))
+		for key, v := range values {
+			items[dao.KeyToID(key, prefix)] = v
+		}
+		return items, nil
+	}
+}
+
+/**
+ * Export the items of a kind from its cache
+ */
+func exportCached[T any](all func() map[string]T) func() (map[string]any, error) {
+	return func() (map[string]any, error) {
+		m := all()
+		items := make(map[string]any, len(m))
+		for id, v := range m {
+			items[id] = v
+		}
+		return items, nil
+	}
+}
+
+/**
+ * Export directly-published prompts; extension prompts travel with their extensions
+ */
+func exportDirectPrompts() (map[string]any, error) {
+	items := map[string]any{}
+	for id, p := range prompts.All() {
+		if p.Origin == dao.PromptOrigin_Direct {
+			items[id] = p.Prompt
+		}
+	}
+	return items, nil
+}
+
+/**
+ * Everything published to the prompt shell, for moving it between environments
+ * @description
+ * - Kinds lists the kinds of items the bundle was exported with, even those without any item
+ * - Prompts only holds directly-published prompts; extension prompts travel with their extensions
+ * - Scopes holds the overrides of shared variables by scope layer, e.g. "tenant.acme"
+ * - Secrets are exported sealed, as stored; they can only be imported where the same secrets.key is configured
+ */
+type Bundle struct {
+	Version    int                            `json:"version"`
+	Kinds      []string                       `json:"kinds,omitempty"`
+	Extensions map[string]dao.PromptExtension `json:"extensions,omitempty"`
+	Prompts    map[string]dao.Prompt          `json:"prompts,omitempty"`
+	Tools      map[string]dao.Tool            `json:"tools,omitempty"`
+	Partials   map[string]dao.Partial         `json:"partials,omitempty"`
+	Environs   map[string]interface{}         `json:"environs,omitempty"`
+	Scopes     map[string]map[string]any      `json:"scopes,omitempty"`
+	Secrets    map[string]string              `json:"secrets,omitempty"`
+}
+
+/**
+ * How a bundle is imported
+ * @description
+ * - Merge creates and updates the items of the bundle; Replace also deletes items of the selected kinds that the bundle doesn't have
+ * - Kinds selects the kinds of items to import, by default those the bundle was exported with
+ * - DryRun computes the changes without writing them
+ */
+type ImportOptions struct {
+	Mode   string   `json:"mode"`
+	Kinds  []string `json:"kinds"`
+	DryRun bool     `json:"dry_run"`
+}
+
+/**
+ * Change made, or to be made, to one item by an import
+ */
+type BundleChange struct {
+	Kind   string `json:"kind"`
+	ID     string `json:"id"`
+	Action string `json:"action" enums:"create,update,delete"`
+}
+
+/**
+ * Result of importing a bundle
+ */
+type ImportResult struct {
+	Mode      string         `json:"mode"`
+	Kinds     []string       `json:"kinds"`
+	DryRun    bool           `json:"dry_run"`
+	Changes   []BundleChange `json:"changes"`
+	Created   int            `json:"created"`
+	Updated   int            `json:"updated"`
+	Deleted   int            `json:"deleted"`
+	Unchanged int            `json:"unchanged"`
+}
+
+/**
+ * Parse a selection of item kinds
+ * @param names kind names (environs, scopes, secrets, tools, partials, extensions, prompts) or their storage prefixes, e.g. shenma:tools:
+ * @return kind names in import order, nil if names is empty
+ */
+func ParseBundleKinds(names []string) ([]string, error) {
+	var kinds []string
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		found := false
+		for _, k := range bundleKinds {
+			if name == k.name || name == k.prefix {
+				if !slices.Contains(kinds, k.name) {
+					kinds = append(kinds, k.name)
+				}
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown item kind: %s", name)
+		}
+	}
+	return sortKinds(kinds), nil
+}
+
+func sortKinds(kinds []string) []string {
+	var sorted []string
+	for _, k := range bundleKinds {
+		if slices.Contains(kinds, k.name) {
+			sorted = append(sorted, k.name)
+		}
+	}
+	return sorted
+}
+
+func kindPrefix(kind string) string {
+	for _, k := range bundleKinds {
+		if k.name == kind {
+			return k.prefix
+		}
+	}
+	return ""
+}
+
+/**
+ * Export extensions, direct prompts, tools, partials, shared variables with their scoped overrides, and sealed secrets
+ * @param kinds kinds of items to export, nil for all
+ * @return bundle of the selected items
+ * @return error if shared variables can't be read from storage
+ */
+func ExportBundle(kinds []string) (Bundle, error) {
+	if len(kinds) == 0 {
+		for _, k := range bundleKinds {
+			kinds = append(kinds, k.name)
+		}
+	}
+	b := Bundle{Version: BundleVersion, Kinds: sortKinds(kinds)}
+	for _, k := range bundleKinds {
+		if !slices.Contains(b.Kinds, k.name) {
+			continue
+		}
+		items, err := k.export()
+		if err != nil {
+			return b, err
+		}
+		k.field.set(&b, items)
+	}
+	return b, nil
+}
+
+/**
+ * Get the items of one kind in a bundle
+ * @return items by ID, nil if the bundle doesn't have the kind
+ */
+func (b *Bundle) items(kind string) map[string]any {
+	for _, k := range bundleKinds {
+		if k.name == kind {
+			return k.field.get(b)
+		}
+	}
+	return nil
+}
+
+/**
+ * Get the kinds a bundle was exported with, or those it has items of if not recorded
+ */
+func (b *Bundle) kinds() []string {
+	if len(b.Kinds) > 0 {
+		return sortKinds(b.Kinds)
+	}
+	var kinds []string
+	for _, k := range bundleKinds {
+		if b.items(k.name) != nil {
+			kinds = append(kinds, k.name)
+		}
+	}
+	return kinds
+}
+
+/**
+ * Import a bundle
+ * @param ctx context of the import, naming its actor in the audit log
+ * @param b bundle to import
+ * @param opts import mode, selected kinds and dry run
+ * @return changes made, or to be made if dry run
+ * @return error if options are invalid or storage write fails; nothing is written in that case
+ * @throws 403 error if an authenticated caller without the admin role imports secrets
+ * @description
+ * - All changes are written at once with dao.Apply, then the caches of the changed kinds are reloaded
+ * - Items equal to those stored are left alone
+ */
+func ImportBundle(ctx context.Context, b Bundle, opts ImportOptions) (ImportResult, error) {
+	if opts.Mode == "" {
+		opts.Mode = ImportMerge
+	}
+	if opts.Mode != ImportMerge && opts.Mode != ImportReplace {
+		return ImportResult{}, fmt.Errorf("unknown import mode: %s", opts.Mode)
+	}
+	kinds, err := ParseBundleKinds(opts.Kinds)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	if kinds == nil {
+		kinds = b.kinds()
+	}
+	// Publishers may import anything but secrets, which only admins manage
+	if slices.Contains(kinds, "secrets") {
+		if id := auth.FromContext(ctx); id != nil && !id.HasRole(auth.RoleAdmin) {
+			return ImportResult{}, utils.NewHttpError(http.StatusForbidden, "importing secrets requires the admin role")
+		}
+	}
+	result := ImportResult{Mode: opts.Mode, Kinds: kinds, DryRun: opts.DryRun, Changes: []BundleChange{}}
+	if result.Kinds == nil {
+		result.Kinds = []string{}
+	}
+
+	current, err := ExportBundle(kinds)
+	if err != nil {
+		return result, err
+	}
+	values := map[string]any{}
+	var dels []string
+	// Stored and imported items by kind, to audit the changes
+	before := map[string]map[string]any{}
+	after := map[string]map[string]any{}
+	for _, kind := range kinds {
+		prefix := kindPrefix(kind)
+		items := b.items(kind)
+		stored := current.items(kind)
+		before[kind], after[kind] = stored, items
+		for _, id := range sortedKeys(items) {
+			old, ok := stored[id]
+			action := ChangeCreate
+			if ok {
+				if jsonEqual(old, items[id]) {
+					result.Unchanged++
+					continue
+				}
+				action = ChangeUpdate
+			}
+			result.Changes = append(result.Changes, BundleChange{Kind: kind, ID: id, Action: action})
+			values[dao.IDToKey(id, prefix)] = items[id]
+		}
+		if opts.Mode != ImportReplace {
+			continue
+		}
+		for _, id := range sortedKeys(stored) {
+			if _, ok := items[id]; !ok {
+				result.Changes = append(result.Changes, BundleChange{Kind: kind, ID: id, Action: ChangeDelete})
+				dels = append(dels, dao.IDToKey(id, prefix))
+			}
+		}
+	}
+	for _, c := range result.Changes {
+		switch c.Action {
+		case ChangeCreate:
+			result.Created++
+		case ChangeUpdate:
+			result.Updated++
+		case ChangeDelete:
+			result.Deleted++
+		}
+	}
+	if opts.DryRun || len(result.Changes) == 0 {
+		return result, nil
+	}
+
+	if err := dao.Apply(values, dels); err != nil {
+		return result, err
+	}
+	for _, c := range result.Changes {
+		auditChange(ctx, c.Kind, c.ID, before[c.Kind][c.ID], after[c.Kind][c.ID])
+	}
+	for _, kind := range kinds {
+		reload(kindPrefix(kind))
+	}
+	// Prompts contributed by extensions depend on both
+	if slices.Contains(kinds, "extensions") && !slices.Contains(kinds, "prompts") {
+		reload(dao.PREFIX_TEMPLATES)
+	}
+	return result, nil
+}
+
+func jsonEqual(a, b any) bool {
+	da, err1 := json.Marshal(a)
+	db, err2 := json.Marshal(b)
+	return err1 == nil && err2 == nil && bytes.Equal(da, db)
+}
+
+/**
+ * Write a bundle as a tar archive in the file storage layout
+ * @description
+ * - Items are laid out as in a file storage directory, e.g. tools/{tool_id}.json, so an extracted
+ *   archive can be used as storage.dir
+ * - bundle.json records the format version and the kinds exported
+ */
+func WriteBundleTar(w io.Writer, b Bundle) error {
+	tw := tar.NewWriter(w)
+	now := time.Now()
+	write := func(name string, v any) error {
+		data, err := json.MarshalIndent(v, "", "    ")
+		if err != nil {
+			return err
+		}
+		data = append(data, '\n')
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), ModTime: now, Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err = tw.Write(data)
+		return err
+	}
+	manifest := Bundle{Version: b.Version, Kinds: b.kinds()}
+	if err := write(bundleManifest, manifest); err != nil {
+		return err
+	}
+	for _, kind := range manifest.Kinds {
+		items := b.items(kind)
+		for _, id := range sortedKeys(items) {
+			name, ok := dao.LayoutPath(dao.IDToKey(id, kindPrefix(kind)))
+			if !ok {
+				return fmt.Errorf("%s %s can't be written to a tar bundle", kind, id)
+			}
+			if err := write(name, items[id]); err != nil {
+				return err
+			}
+		}
+	}
+	return tw.Close()
+}
+
+/**
+ * Read a bundle in JSON, or as a tar archive written by WriteBundleTar, optionally gzipped
+ */
+func ReadBundle(r io.Reader) (Bundle, error) {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(2)
+	if len(head) == 2 && head[0] == 0x1f && head[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return Bundle{}, err
+		}
+		defer zr.Close()
+		return readBundleTar(zr)
+	}
+	for {
+		c, err := br.Peek(1)
+		if err != nil || !strings.ContainsRune(" \t\r\n", rune(c[0])) {
+			break
+		}
+		br.ReadByte()
+	}
+	if c, err := br.Peek(1); err == nil && c[0] == '{' {
+		var b Bundle
+		if err := json.NewDecoder(br).Decode(&b); err != nil {
+			return b, fmt.Errorf("invalid bundle: %v", err)
+		}
+		return b, nil
+	}
+	return readBundleTar(br)
+}
+
+func readBundleTar(r io.Reader) (Bundle, error) {
+	b := Bundle{Version: BundleVersion}
+	found := map[string]map[string]any{}
+	var manifest *Bundle
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return b, fmt.Errorf("invalid tar bundle: %v", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := strings.TrimPrefix(path.Clean(hdr.Name), "./")
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return b, err
+		}
+		if name == bundleManifest {
+			manifest = &Bundle{}
+			if err := json.Unmarshal(data, manifest); err != nil {
+				return b, fmt.Errorf("%s: %v", name, err)
+			}
+			continue
+		}
+		key, prefix := dao.LayoutKey(name)
+		for _, k := range bundleKinds {
+			if k.prefix != prefix {
+				continue
+			}
+			v, err := k.field.decode(data)
+			if err != nil {
+				return b, fmt.Errorf("%s: %v", name, err)
+			}
+			if found[k.name] == nil {
+				found[k.name] = map[string]any{}
+			}
+			found[k.name][dao.KeyToID(key, prefix)] = v
+		}
+	}
+	if manifest != nil {
+		b.Version = manifest.Version
+		b.Kinds = manifest.Kinds
+	}
+	// Kinds without any file are only part of the bundle if the manifest says so
+	for _, k := range bundleKinds {
+		if items := found[k.name]; items != nil {
+			k.field.set(&b, items)
+		} else if slices.Contains(b.Kinds, k.name) {
+			k.field.set(&b, map[string]any{})
+		}
+	}
+	return b, nil
+}
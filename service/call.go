@@ -2,10 +2,12 @@ package service
 
 import (
 	"github.com/zgsm-ai/ai-prompt-shell/dao"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/logger"
 	"github.com/zgsm-ai/ai-prompt-shell/internal/utils"
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -14,6 +16,7 @@ import (
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
 )
 
 type CallStats struct {
@@ -27,15 +30,45 @@ var callStats = sync.Map{}
 /**
  * Execute tool call with validation
  * @param ctx Context for the call
+ * @param toolId ID of the tool
  * @param t Tool definition
  * @param args Arguments for the tool
  * @return Execution result or error
  */
-func Call(ctx context.Context, t *dao.Tool, args []interface{}) (interface{}, error) {
+func Call(ctx context.Context, toolId string, t *dao.Tool, args []interface{}) (interface{}, error) {
 	if err := utils.ValidateArgs(args, t.Parameters); err != nil {
 		return nil, err
 	}
-	return callTool(ctx, t, args)
+	return callTool(ctx, toolId, t, args)
+}
+
+/**
+ * Call tool, unless the context carries a tool session that mocks or replays it
+ * @param ctx Context for the call, see WithToolOptions; if it carries a guard, the result is checked by it;
+ *        if it carries a tool call cache, calls made before in the render return their kept result
+ * @param toolId ID of the tool
+ * @param tool Tool definition
+ * @param args Arguments for the tool
+ * @return Execution result or error
+ */
+func callTool(ctx context.Context, toolId string, tool *dao.Tool, args []interface{}) (interface{}, error) {
+	call := func() (interface{}, error) {
+		var result interface{}
+		var err error
+		if s := toolSessionFrom(ctx); s != nil {
+			result, err = s.call(ctx, toolId, tool, args)
+		} else {
+			result, err = invokeTool(ctx, tool, args)
+		}
+		if g := guardRunFrom(ctx); g != nil && err == nil {
+			result = g.checkValue(ctx, result, "tool:"+toolId)
+		}
+		return result, err
+	}
+	if c := toolCallCacheFrom(ctx); c != nil {
+		return c.call(toolId, args, call)
+	}
+	return call()
 }
 
 /**
@@ -45,17 +78,24 @@ func Call(ctx context.Context, t *dao.Tool, args []interface{}) (interface{}, er
  * @param args Arguments for the tool
  * @return Execution result or error
  */
-func callTool(ctx context.Context, tool *dao.Tool, args []interface{}) (interface{}, error) {
+func invokeTool(ctx context.Context, tool *dao.Tool, args []interface{}) (interface{}, error) {
+	var result interface{}
+	var err error
 	switch tool.Type {
 	case "restful":
-		return callRestfulTool(ctx, tool, args)
+		result, err = callRestfulTool(ctx, tool, args)
 	case "grpc":
-		return callGRPCTool(ctx, tool, args)
+		result, err = callGRPCTool(ctx, tool, args)
 	case "mcp":
-		return callMCPTool(ctx, tool, args)
+		result, err = callMCPTool(ctx, tool, args)
 	default:
 		return nil, fmt.Errorf("unsupported tool type: %s", tool.Type)
 	}
+	if err != nil {
+		// Servers may echo the request, including headers filled with secrets
+		return nil, errors.New(logger.Redact(err.Error()))
+	}
+	return result, nil
 }
 
 /**
@@ -64,6 +104,8 @@ func callTool(ctx context.Context, tool *dao.Tool, args []interface{}) (interfac
  * @param tool RESTful tool definition
  * @param args Arguments for API call
  * @return API response or error
+ * @description
+ * - The URL, redirects, the addresses connected to and the response size are checked by the egress policy
  */
 func callRestfulTool(ctx context.Context, tool *dao.Tool, args []interface{}) (interface{}, error) {
 	if tool.Restful == nil {
@@ -76,10 +118,6 @@ func callRestfulTool(ctx context.Context, tool *dao.Tool, args []interface{}) (i
 		return nil, fmt.Errorf("missing method for tool %s", tool.Name)
 	}
 
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
-
 	reqBody, err := json.Marshal(args)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %v", err)
@@ -89,10 +127,20 @@ func callRestfulTool(ctx context.Context, tool *dao.Tool, args []interface{}) (i
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %v", err)
 	}
+	if err := egressPolicy.CheckURL(req.URL); err != nil {
+		return nil, fmt.Errorf("tool %s: %v", tool.Name, err)
+	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("User-Agent", "ai-prompt-shell/1.0")
+	headers, err := toolHeaders(tool.Restful.Headers)
+	if err != nil {
+		return nil, fmt.Errorf("tool %s: %v", tool.Name, err)
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
 
-	resp, err := client.Do(req)
+	resp, err := toolClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("tool %s request failed: %v", tool.Name, err)
 	}
@@ -108,8 +156,12 @@ func callRestfulTool(ctx context.Context, tool *dao.Tool, args []interface{}) (i
 		return nil, fmt.Errorf("tool %s call failed (status %d): %s", tool.Name, resp.StatusCode, string(body))
 	}
 
+	body, err := egressPolicy.ReadBody(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("tool %s: %v", tool.Name, err)
+	}
 	var result interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+	if err := json.Unmarshal(body, &result); err != nil {
 		return nil, fmt.Errorf("failed to decode %s response: %v", tool.Name, err)
 	}
 
@@ -122,19 +174,25 @@ func callRestfulTool(ctx context.Context, tool *dao.Tool, args []interface{}) (i
  * @param tool gRPC tool definition with endpoint and method
  * @param args Arguments for gRPC call
  * @return gRPC response or error
+ * @description
+ * - The endpoint host, the addresses connected to and the response size are checked by the egress policy
  */
 func callGRPCTool(ctx context.Context, tool *dao.Tool, args []interface{}) (interface{}, error) {
 	if tool.Grpc.Url == "" {
 		return nil, fmt.Errorf("missing gRPC endpoint URL for tool %s", tool.Name)
 	}
 
+	if err := egressPolicy.CheckHost(grpcTargetHost(tool.Grpc.Url)); err != nil {
+		return nil, fmt.Errorf("tool %s: %v", tool.Name, err)
+	}
+
 	// 1. Establish connection (with timeout)
 	connCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	conn, err := grpc.DialContext(connCtx, tool.Grpc.Url,
+	conn, err := grpc.DialContext(connCtx, tool.Grpc.Url, append(grpcEgressOptions(),
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpc.WithBlock())
+		grpc.WithBlock())...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to gRPC server: %v", err)
 	}
@@ -149,8 +207,12 @@ func callGRPCTool(ctx context.Context, tool *dao.Tool, args []interface{}) (inte
 	resp := new(bytes.Buffer)
 	method := fmt.Sprintf("%s/%s", tool.Module, tool.Name)
 
-	// 3. Execute gRPC call (with timeout)
-	callCtx, callCancel := context.WithTimeout(ctx, 10*time.Second)
+	// 3. Execute gRPC call (with timeout), with headers as metadata
+	headers, err := toolHeaders(tool.Grpc.Headers)
+	if err != nil {
+		return nil, fmt.Errorf("tool %s: %v", tool.Name, err)
+	}
+	callCtx, callCancel := context.WithTimeout(metadata.NewOutgoingContext(ctx, metadata.New(headers)), 10*time.Second)
 	defer callCancel()
 
 	err = conn.Invoke(callCtx, method, reqBody, resp)
@@ -1,8 +1,10 @@
 package service
 
 import (
-	"github.com/zgsm-ai/ai-prompt-shell/dao"
 	"context"
+	"time"
+
+	"github.com/zgsm-ai/ai-prompt-shell/dao"
 )
 
 type ChatPromptRequest struct {
@@ -17,10 +19,14 @@ type ChatPromptRequest struct {
 	N                int                    `json:"n,omitempty"`
 	Stream           bool                   `json:"stream,omitempty"`
 	User             string                 `json:"user,omitempty"`
+	Scope            dao.EnvScope           `json:"scope"`
+	NoCache          bool                   `json:"no_cache,omitempty"` // skip the response cache lookup; the response still refreshes it
 }
 
 /**
  * ChatWithPrompt executes chat completion using specified prompt template
+ * @param ctx context of the render; shared variables are overridden by those of the scope it carries,
+ *            and the quota report it carries is filled in
  * @param promptId ID of the prompt template to use
  * @param req chat request parameters containing:
  *      - Model: LLM model to use
@@ -33,22 +39,105 @@ type ChatPromptRequest struct {
  *      - prompt template rendering failure
  *      - LLM service call failure
  *      - parameter validation failure
+ *      - exhausted request or token quota (429)
+ *      - untrusted content flagged by the guard of a prompt blocking it (*GuardError, 422)
  * Implementation flow:
- * 1. Render prompt template using promptId and Args
- * 2. Construct LLM request parameters
- * 3. Call LLM service to get completion results
+ * 1. Check the quotas of the user, tenant, prompt and model, and count the request
+ * 2. If the prompt is guarded, check the args, and the tool outputs as they are returned during rendering
+ * 3. Render prompt template using promptId and Args, trimming truncatable args to the token budget
+ * 4. Give the guard verdict: block the chat, or report it in the response
+ * 5. Construct LLM request parameters, redacting sensitive values of the messages if the prompt is redacted
+ * 6. Answer from the response cache, or with the response to a near-duplicate, if the prompt opts in,
+ *    else call LLM service to get completion results,
+ *    cache them, and account the tokens used to the quotas
+ * 7. Put the values replaced by placeholders back in the completion
+ * 8. Audit the chat, refused or not
  */
-func ChatWithPrompt(promptId string, req ChatPromptRequest) (ChatResponse, error) {
-	var resp ChatResponse
-	// Render template
-	kind, data, err := RenderPrompt(promptId, req.Args)
+func ChatWithPrompt(ctx context.Context, promptId string, req ChatPromptRequest) (resp ChatResponse, err error) {
+	start := time.Now()
+	var sent []dao.Message
+	var answered *ChatResponse
+	defer func() {
+		auditChat(ctx, promptId, req, sent, answered, err, start)
+	}()
+
+	quotas, err := checkQuota(ctx, promptId, req)
 	if err != nil {
 		return resp, err
 	}
+	run := newGuardRun(promptId)
+	if run != nil {
+		if args, ok := run.checkValue(ctx, req.Args, "args").(map[string]interface{}); ok {
+			req.Args = args
+		}
+		ctx = context.WithValue(ctx, guardRunKey{}, run)
+	}
+	// Render template within the model context window
+	kind, data, err := RenderPromptWithBudget(ctx, promptId, req.Args, req.Model, req.MaxTokens)
+	if err != nil {
+		return resp, err
+	}
+	var verdict *GuardVerdict
+	if run != nil {
+		if verdict, err = run.finish(); err != nil {
+			return resp, err
+		}
+	}
 
 	// Call LLM
-	var llmReq ChatRequest = ChatRequest{
+	llmReq := llmRequest(req, toChatMessages(kind, data))
+	redaction := newRedactRun(ctx, promptId)
+	if redaction != nil {
+		llmReq.Messages = redaction.messages(llmReq.Messages)
+	}
+	sent = llmReq.Messages
+
+	// Responses are cached as answered, keyed by the messages sent, so placeholders are restored on hits too
+	ttl, cacheable := chatCachePolicy(promptId, req)
+	var cacheKey, cacheLevel string
+	var cached *cachedChat
+	var sem *semanticQuery
+	if cacheable {
+		cacheKey = chatCacheKey(llmReq)
+		sem = newSemanticQuery(promptId, llmReq)
+		if req.NoCache {
+			chatCacheResults.Inc(promptId, "bypass")
+		} else {
+			cached, cacheLevel = lookupChat(ctx, promptId, cacheKey, sem)
+		}
+	}
+	if cached != nil {
+		resp = cached.Response
+		resp.Choices = append(resp.Choices[:0:0], cached.Response.Choices...)
+		resp.Cached = true
+	} else {
+		resp, _, err = completeChat(llmReq, quotas)
+		if err == nil && cacheable {
+			storeChat(ctx, cacheKey, resp, ttl, sem)
+		}
+	}
+	if err == nil {
+		answered = restoreAnswer(&resp, redaction)
+	}
+	if cacheable {
+		resp.Cache = chatCacheStatus(cacheKey, cached, cacheLevel, sem)
+	}
+	resp.Guard = verdict
+	if redaction != nil {
+		resp.Redaction = redaction.report()
+	}
+	//TODO:
+	return resp, err
+}
+
+/**
+ * Build the LLM request of a chat
+ * @param messages messages to send, redacted if the prompt says so
+ */
+func llmRequest(req ChatPromptRequest, messages []dao.Message) ChatRequest {
+	return ChatRequest{
 		Model:            req.Model,
+		Messages:         messages,
 		Temperature:      req.Temperature,
 		MaxTokens:        req.MaxTokens,
 		TopP:             req.TopP,
@@ -59,22 +148,37 @@ func ChatWithPrompt(promptId string, req ChatPromptRequest) (ChatResponse, error
 		Stream:           req.Stream,
 		User:             req.User,
 	}
-	if kind == "prompt" {
-		llmReq.Messages = []dao.Message{
-			{
-				Role:    "system",
-				Content: "You are a helpful assistant.",
-			},
-			{
-				Role:    "user",
-				Content: data.(string),
-			},
-		}
-	} else {
-		llmReq.Messages = data.([]dao.Message)
+}
+
+/**
+ * Get a completion from the LLM and charge the tokens used to the quotas
+ * @return response as answered, with placeholders not restored; and the tokens used
+ * @description
+ * - The call isn't bound to the request context, so an answer being paid for isn't lost if the caller goes away
+ * - If the LLM reports no usage, the messages sent are accounted at least
+ */
+func completeChat(llmReq ChatRequest, quotas []quotaCounter) (ChatResponse, int, error) {
+	resp, err := llmClient.ChatCompletion(context.Background(), llmReq)
+	if err != nil {
+		return resp, 0, err
 	}
+	used := resp.Usage.TotalTokens
+	if used == 0 {
+		used = CountMessages(llmReq.Messages).Total
+	}
+	chargeQuota(quotas, used)
+	return resp, used, nil
+}
 
-	resp, err = llmClient.ChatCompletion(context.Background(), llmReq)
-	//TODO:
-	return resp, err
+/**
+ * Restore the placeholders of an answer
+ * @return copy of the response as answered, before placeholders are restored, for the audit
+ */
+func restoreAnswer(resp *ChatResponse, redaction *redactRun) *ChatResponse {
+	raw := *resp
+	raw.Choices = append(raw.Choices[:0:0], resp.Choices...)
+	if redaction != nil {
+		redaction.restore(resp)
+	}
+	return &raw
 }
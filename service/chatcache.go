@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/zgsm-ai/ai-prompt-shell/dao"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/cache"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/config"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/metrics"
+)
+
+// Levels of the response cache
+const (
+	CacheL1 = "l1" // in process
+	CacheL2 = "l2" // in Redis, shared by all instances
+)
+
+var (
+	chatCacheL1  *cache.LRUCache
+	chatCacheTTL time.Duration
+
+	chatCacheL1Hits  atomic.Int64
+	chatCacheL2Hits  atomic.Int64
+	chatCacheSemHits atomic.Int64
+	chatCacheMisses  atomic.Int64
+	chatCacheStores  atomic.Int64
+
+	chatCacheResults = metrics.NewCounter("ai_prompt_shell_chat_cache_total",
+		"Chats looked up in the response cache", "prompt", "result")
+)
+
+/**
+ * Response cache state reported with chats of prompts opting in
+ * @description
+ * - Level, Age and TTL describe the cached response, for hits
+ * - Stats are counted by this instance since it started
+ */
+type CacheStatus struct {
+	Key        string     `json:"key"`
+	Level      string     `json:"level,omitempty" enums:"l1,l2,semantic"`
+	Similarity float64    `json:"similarity,omitempty"` // of the chat answered, for semantic hits
+	Age        int64      `json:"age,omitempty"`        // seconds since the response was cached
+	TTL        int64      `json:"ttl,omitempty"`        // seconds until it expires
+	Stats      CacheStats `json:"stats"`
+}
+
+/**
+ * Counters of the response cache
+ */
+type CacheStats struct {
+	L1Hits          int64 `json:"l1_hits"`
+	L2Hits          int64 `json:"l2_hits"`
+	SemanticHits    int64 `json:"semantic_hits"`
+	Misses          int64 `json:"misses"`
+	Stores          int64 `json:"stores"`
+	L1Entries       int   `json:"l1_entries"`
+	SemanticEntries int   `json:"semantic_entries"`
+}
+
+/**
+ * Response kept in the cache
+ */
+type cachedChat struct {
+	Response  ChatResponse `json:"response"`
+	CreatedAt time.Time    `json:"created_at"`
+	ExpiresAt time.Time    `json:"expires_at"`
+}
+
+/**
+ * Initialize the response cache from configuration
+ */
+func initChatCache(c *config.Config) {
+	chatCacheL1 = nil
+	if c.Cache.L1Size > 0 {
+		chatCacheL1 = cache.NewLRUCache(c.Cache.L1Size)
+	}
+	chatCacheTTL = c.Cache.DefaultTTL
+	initSemanticCache(c)
+}
+
+/**
+ * Decide whether the response of a chat is cached
+ * @return time to keep the response, false if the prompt doesn't opt in or the chat isn't deterministic enough
+ * @description
+ * - Chats with a temperature above 0 are cached only if the policy forces it; streamed chats never are
+ */
+func chatCachePolicy(promptId string, req ChatPromptRequest) (time.Duration, bool) {
+	p, _ := prompts.Get(promptId)
+	if p.Cache == nil {
+		return 0, false
+	}
+	if req.Stream {
+		chatCacheResults.Inc(promptId, "skip")
+		return 0, false
+	}
+	if req.Temperature > 0 && !p.Cache.Force {
+		chatCacheResults.Inc(promptId, "skip")
+		return 0, false
+	}
+	ttl := time.Duration(p.Cache.TTL) * time.Second
+	if ttl <= 0 {
+		ttl = chatCacheTTL
+	}
+	return ttl, ttl > 0
+}
+
+/**
+ * Key of the response of an LLM request: the hash of its model, messages and sampling parameters
+ */
+func chatCacheKey(req ChatRequest) string {
+	data, _ := json.Marshal(struct {
+		Model            string        `json:"model"`
+		Messages         []dao.Message `json:"messages"`
+		Temperature      float64       `json:"temperature"`
+		MaxTokens        int           `json:"max_tokens"`
+		TopP             float64       `json:"top_p"`
+		FrequencyPenalty float64       `json:"frequency_penalty"`
+		PresencePenalty  float64       `json:"presence_penalty"`
+		Stop             []string      `json:"stop"`
+		N                int           `json:"n"`
+	}{req.Model, req.Messages, req.Temperature, req.MaxTokens, req.TopP,
+		req.FrequencyPenalty, req.PresencePenalty, req.Stop, req.N})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+/**
+ * Look up a cached response, in process first, then in Redis, then the response to a near-duplicate
+ * @param sem semantic lookup of the chat, nil if the prompt doesn't match near-duplicates
+ * @return response, nil if it isn't cached; and the level it was found at
+ * @description
+ * - Responses found in Redis are kept in process too, until they expire
+ * - Redis and embeddings API failures are logged and count as misses
+ */
+func lookupChat(ctx context.Context, promptId, key string, sem *semanticQuery) (*cachedChat, string) {
+	now := time.Now()
+	if chatCacheL1 != nil {
+		if v, ok := chatCacheL1.Get(key); ok {
+			entry := v.(*cachedChat)
+			if now.Before(entry.ExpiresAt) {
+				chatCacheL1Hits.Add(1)
+				chatCacheResults.Inc(promptId, "hit_l1")
+				return entry, CacheL1
+			}
+			chatCacheL1.Remove(key)
+		}
+	}
+	data, err := dao.GetCachedChat(key)
+	if err != nil {
+		logrus.Warnf("Read cached chat failed: %v", err)
+	}
+	if data != nil {
+		var entry cachedChat
+		if err := json.Unmarshal(data, &entry); err == nil && now.Before(entry.ExpiresAt) {
+			if chatCacheL1 != nil {
+				chatCacheL1.Put(key, &entry)
+			}
+			chatCacheL2Hits.Add(1)
+			chatCacheResults.Inc(promptId, "hit_l2")
+			return &entry, CacheL2
+		}
+	}
+	if sem != nil {
+		if entry := sem.lookup(ctx); entry != nil {
+			chatCacheSemHits.Add(1)
+			chatCacheResults.Inc(promptId, "hit_semantic")
+			return entry, CacheSemantic
+		}
+	}
+	chatCacheMisses.Add(1)
+	chatCacheResults.Inc(promptId, "miss")
+	return nil, ""
+}
+
+/**
+ * Cache the response of an LLM request
+ * @param resp response as returned by the LLM
+ * @param sem semantic lookup of the chat, nil if the prompt doesn't match near-duplicates
+ */
+func storeChat(ctx context.Context, key string, resp ChatResponse, ttl time.Duration, sem *semanticQuery) *cachedChat {
+	now := time.Now()
+	entry := &cachedChat{Response: resp, CreatedAt: now, ExpiresAt: now.Add(ttl)}
+	entry.Response.Choices = append(resp.Choices[:0:0], resp.Choices...)
+	if chatCacheL1 != nil {
+		chatCacheL1.Put(key, entry)
+	}
+	if data, err := json.Marshal(entry); err == nil {
+		if err := dao.SetCachedChat(key, data, ttl); err != nil {
+			logrus.Warnf("Write cached chat failed: %v", err)
+		}
+	}
+	if sem != nil {
+		sem.store(ctx, entry)
+	}
+	chatCacheStores.Add(1)
+	return entry
+}
+
+/**
+ * Report the cache state for a chat
+ * @param entry response found in the cache, nil for misses
+ * @param sem semantic lookup of the chat, nil if the prompt doesn't match near-duplicates
+ */
+func chatCacheStatus(key string, entry *cachedChat, level string, sem *semanticQuery) *CacheStatus {
+	s := &CacheStatus{
+		Key: key,
+		Stats: CacheStats{
+			L1Hits:       chatCacheL1Hits.Load(),
+			L2Hits:       chatCacheL2Hits.Load(),
+			SemanticHits: chatCacheSemHits.Load(),
+			Misses:       chatCacheMisses.Load(),
+			Stores:       chatCacheStores.Load(),
+		},
+	}
+	if chatCacheL1 != nil {
+		s.Stats.L1Entries = chatCacheL1.Len()
+	}
+	if semanticIndex != nil {
+		s.Stats.SemanticEntries = semanticIndex.Len()
+	}
+	if entry != nil {
+		now := time.Now()
+		s.Level = level
+		if level == CacheSemantic {
+			s.Similarity = sem.score
+		}
+		s.Age = int64(now.Sub(entry.CreatedAt) / time.Second)
+		s.TTL = int64(entry.ExpiresAt.Sub(now) / time.Second)
+	}
+	return s
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/zgsm-ai/ai-prompt-shell/internal/config"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/egress"
+	"google.golang.org/grpc"
+)
+
+// Policy of outbound tool calls; unrestricted until initEgress
+var egressPolicy = new(egress.Policy)
+
+// HTTP client of RESTful tools, enforcing the egress policy
+var toolClient = egressPolicy.HTTPClient(10 * time.Second)
+
+/**
+ * Initialize the egress policy of tool calls from configuration
+ * @throws error if the policy configuration is invalid
+ */
+func initEgress(c *config.Config) error {
+	p, err := egress.New(&c.Egress)
+	if err != nil {
+		return err
+	}
+	egressPolicy = p
+	toolClient = p.HTTPClient(10 * time.Second)
+	return nil
+}
+
+/**
+ * Get the host of a gRPC target, such as "host:port" or "dns:///host:port"
+ */
+func grpcTargetHost(target string) string {
+	if i := strings.Index(target, ":///"); i >= 0 {
+		target = target[i+4:]
+	}
+	host, _, err := net.SplitHostPort(target)
+	if err != nil {
+		return target
+	}
+	return host
+}
+
+/**
+ * Dial options enforcing the egress policy on gRPC connections
+ */
+func grpcEgressOptions() []grpc.DialOption {
+	opts := []grpc.DialOption{
+		grpc.WithNoProxy(),
+		// Report refused addresses at once instead of retrying until the dial times out
+		grpc.FailOnNonTempDialError(true),
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return egressPolicy.DialContext(ctx, "tcp", addr)
+		}),
+	}
+	if n := egressPolicy.MaxResponseBytes(); n > 0 {
+		opts = append(opts, grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(int(n))))
+	}
+	return opts
+}
@@ -1,7 +1,13 @@
 package service
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
 	"github.com/zgsm-ai/ai-prompt-shell/dao"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/utils"
 )
 
 var environs = dao.NewEnvironments()
@@ -13,3 +19,214 @@ var environs = dao.NewEnvironments()
 func Environments() *dao.Environments {
 	return environs
 }
+
+/**
+ * Set a shared variable
+ * @param ctx context of the change, naming its actor in the audit log
+ * @param environ_id ID of the variable, a dot path such as "completion.model"
+ * @param value value of the variable
+ * @return error if storage access fails
+ */
+func SetEnviron(ctx context.Context, environ_id string, value interface{}) error {
+	key := dao.IDToKey(environ_id, dao.PREFIX_ENVIRONS)
+	var before interface{}
+	if err := dao.GetJSON(key, &before); err != nil {
+		return err
+	}
+	if err := dao.SetJSON(key, value, 0); err != nil {
+		return err
+	}
+	auditEnvironChange(ctx, dao.EnvScope{}, environ_id, before, value)
+	return environs.Load(context.Background())
+}
+
+/**
+ * Value of a shared variable seen by a scope
+ * @description
+ * - Source is the most specific layer that sets the variable, or part of it, e.g. "project.web"; "global" if none does
+ */
+type EnvironValue struct {
+	ID     string       `json:"id"`
+	Value  interface{}  `json:"value"`
+	Source string       `json:"source"`
+	Scope  dao.EnvScope `json:"scope"`
+}
+
+type envScopeKey struct{}
+
+/**
+ * Attach the scope of a request to a context, selecting the shared variables its renders see
+ */
+func WithEnvScope(ctx context.Context, scope dao.EnvScope) context.Context {
+	return context.WithValue(ctx, envScopeKey{}, scope)
+}
+
+/**
+ * Get the scope carried by a context
+ * @return scope, empty for global variables only
+ */
+func EnvScopeFrom(ctx context.Context) dao.EnvScope {
+	scope, _ := ctx.Value(envScopeKey{}).(dao.EnvScope)
+	return scope
+}
+
+/**
+ * Parse a scope given as comma-separated kind:id pairs
+ * @param s scope such as "tenant:acme,project:web,user:alice", empty for none
+ * @throws 400 error if a kind is unknown or repeated, or an ID is missing or invalid
+ */
+func ParseEnvScope(s string) (dao.EnvScope, error) {
+	var scope dao.EnvScope
+	if s == "" {
+		return scope, nil
+	}
+	for _, part := range strings.Split(s, ",") {
+		kind, id, _ := strings.Cut(strings.TrimSpace(part), ":")
+		var field *string
+		switch kind {
+		case dao.SCOPE_TENANT:
+			field = &scope.Tenant
+		case dao.SCOPE_PROJECT:
+			field = &scope.Project
+		case dao.SCOPE_USER:
+			field = &scope.User
+		default:
+			return scope, utils.NewHttpError(http.StatusBadRequest, fmt.Sprintf("unknown scope kind: %s", kind))
+		}
+		if *field != "" {
+			return scope, utils.NewHttpError(http.StatusBadRequest, fmt.Sprintf("scope kind repeated: %s", kind))
+		}
+		if id == "" {
+			return scope, utils.NewHttpError(http.StatusBadRequest, fmt.Sprintf("missing %s ID in scope: %s", kind, s))
+		}
+		*field = id
+	}
+	return scope, CheckEnvScope(scope)
+}
+
+/**
+ * Check the IDs of a scope
+ * @throws 400 error if an ID holds ':', '/' or spaces, which can't be part of a storage key
+ */
+func CheckEnvScope(scope dao.EnvScope) error {
+	for _, id := range []string{scope.Tenant, scope.Project, scope.User} {
+		if strings.ContainsAny(id, ":/\\ \t") {
+			return utils.NewHttpError(http.StatusBadRequest, fmt.Sprintf("invalid scope ID: %s", id))
+		}
+	}
+	return nil
+}
+
+/**
+ * Get the layer a scope writes to
+ * @return layer ID, empty for global variables
+ * @throws 400 error if the scope has more than one part
+ */
+func scopeLayer(scope dao.EnvScope) (string, error) {
+	layers := scope.Layers()
+	if len(layers) > 1 {
+		return "", utils.NewHttpError(http.StatusBadRequest, "a variable can only be set in one scope at a time")
+	}
+	if len(layers) == 0 {
+		return "", nil
+	}
+	return layers[0], nil
+}
+
+/**
+ * Get the value of a shared variable seen by a scope
+ * @param scope scope of the request
+ * @param environ_id ID of the variable, a dot path
+ * @return value and where it comes from, false if the variable doesn't exist in the scope
+ */
+func ResolveEnviron(scope dao.EnvScope, environ_id string) (EnvironValue, bool) {
+	val, source, ok := environs.Lookup(environ_id, scope)
+	if !ok {
+		return EnvironValue{}, false
+	}
+	return EnvironValue{ID: environ_id, Value: val, Source: source, Scope: scope}, true
+}
+
+/**
+ * Set a shared variable, or its override in a scope
+ * @param ctx context of the change, naming its actor in the audit log
+ * @param scope layer to write, empty to set the global variable
+ * @param environ_id ID of the variable, a dot path
+ * @param value value of the variable
+ * @throws 400 error if scope has more than one part
+ */
+func SetScopedEnviron(ctx context.Context, scope dao.EnvScope, environ_id string, value interface{}) error {
+	layer, err := scopeLayer(scope)
+	if err != nil {
+		return err
+	}
+	if layer == "" {
+		return SetEnviron(ctx, environ_id, value)
+	}
+	key := dao.IDToKey(layer, dao.PREFIX_SCOPES)
+	vars := map[string]interface{}{}
+	if err := dao.GetJSON(key, &vars); err != nil {
+		return err
+	}
+	if vars == nil {
+		vars = map[string]interface{}{}
+	}
+	before := vars[environ_id]
+	vars[environ_id] = value
+	if err := dao.SetJSON(key, vars, 0); err != nil {
+		return err
+	}
+	auditEnvironChange(ctx, scope, environ_id, before, value)
+	return environs.Load(context.Background())
+}
+
+/**
+ * Delete a shared variable, or its override in a scope
+ * @param ctx context of the change, naming its actor in the audit log
+ * @param scope layer to delete from, empty to delete the global variable
+ * @param environ_id ID of the variable, a dot path
+ * @throws 400 error if scope has more than one part, 404 error if the variable isn't set there
+ */
+func DeleteEnviron(ctx context.Context, scope dao.EnvScope, environ_id string) error {
+	layer, err := scopeLayer(scope)
+	if err != nil {
+		return err
+	}
+	if layer == "" {
+		key := dao.IDToKey(environ_id, dao.PREFIX_ENVIRONS)
+		var before interface{}
+		if err := dao.GetJSON(key, &before); err != nil {
+			return err
+		}
+		if ok, err := dao.Exists(key); err != nil {
+			return err
+		} else if !ok {
+			return utils.ErrEnvironNotFound
+		}
+		if err := dao.Del(key); err != nil {
+			return err
+		}
+		auditEnvironChange(ctx, scope, environ_id, before, nil)
+		return environs.Load(context.Background())
+	}
+	key := dao.IDToKey(layer, dao.PREFIX_SCOPES)
+	vars := map[string]interface{}{}
+	if err := dao.GetJSON(key, &vars); err != nil {
+		return err
+	}
+	before, ok := vars[environ_id]
+	if !ok {
+		return utils.ErrEnvironNotFound
+	}
+	delete(vars, environ_id)
+	if len(vars) == 0 {
+		err = dao.Del(key)
+	} else {
+		err = dao.SetJSON(key, vars, 0)
+	}
+	if err != nil {
+		return err
+	}
+	auditEnvironChange(ctx, scope, environ_id, before, nil)
+	return environs.Load(context.Background())
+}
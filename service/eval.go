@@ -0,0 +1,465 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zgsm-ai/ai-prompt-shell/internal/utils"
+)
+
+// Scorers applied to evaluation outputs
+const (
+	ScorerExact  = "exact"
+	ScorerSchema = "schema"
+	ScorerJudge  = "judge"
+)
+
+// Default and maximum number of concurrent chats of an evaluation
+const (
+	defaultEvalConcurrency = 4
+	maxEvalConcurrency     = 32
+)
+
+/**
+ * One dataset line: args for the prompt and the expected output
+ * @description
+ * - Expected is a string, or a JSON value compared with the output parsed as JSON
+ */
+type EvalSample struct {
+	ID       string                 `json:"id,omitempty"`
+	Args     map[string]interface{} `json:"args"`
+	Expected interface{}            `json:"expected,omitempty"`
+}
+
+/**
+ * Batch evaluation of prompts and models over a dataset
+ * @description
+ * - Every prompt is run with every model; each prompt/model pair is a variant of the report
+ * - Scorers default to exact if the dataset has expected outputs, otherwise schema
+ * - The judge scorer chats with JudgePrompt using JudgeModel (defaults to the model being evaluated),
+ *   passing args input, output, expected and prompt; the judge replies a score, or {"score": ..., "reason": ...}
+ * - Judge scores are divided by JudgeMaxScore (default 1), so all scores range from 0 to 1
+ */
+type EvalRequest struct {
+	Prompts       []string     `json:"prompts"`
+	Models        []string     `json:"models"`
+	Dataset       []EvalSample `json:"dataset"`
+	Scorers       []string     `json:"scorers,omitempty" enums:"exact,schema,judge"`
+	JudgePrompt   string       `json:"judge_prompt,omitempty"`
+	JudgeModel    string       `json:"judge_model,omitempty"`
+	JudgeMaxScore float64      `json:"judge_max_score,omitempty"`
+	Concurrency   int          `json:"concurrency,omitempty"`
+	Temperature   float64      `json:"temperature,omitempty"`
+	MaxTokens     int          `json:"max_tokens,omitempty"`
+}
+
+/**
+ * Output and scores of one sample run with one variant
+ */
+type EvalResult struct {
+	Sample    string             `json:"sample"`
+	Prompt    string             `json:"prompt"`
+	Model     string             `json:"model"`
+	Output    string             `json:"output,omitempty"`
+	Error     string             `json:"error,omitempty"`
+	Scores    map[string]float64 `json:"scores"`
+	Details   map[string]string  `json:"details,omitempty"`
+	LatencyMs int64              `json:"latency_ms"`
+	Tokens    int                `json:"tokens"`
+}
+
+/**
+ * Aggregated results of a variant
+ * @description
+ * - Scores holds the mean score of each scorer over all samples; failed chats score 0
+ */
+type EvalVariant struct {
+	Prompt       string             `json:"prompt"`
+	Model        string             `json:"model"`
+	Samples      int                `json:"samples"`
+	Errors       int                `json:"errors"`
+	Scores       map[string]float64 `json:"scores"`
+	AvgLatencyMs int64              `json:"avg_latency_ms"`
+	Tokens       int                `json:"tokens"`
+}
+
+/**
+ * Comparison report of an evaluation
+ * @description
+ * - Best maps each scorer to the "prompt@model" variant with the highest mean score
+ */
+type EvalReport struct {
+	Scorers  []string          `json:"scorers"`
+	Variants []EvalVariant     `json:"variants"`
+	Best     map[string]string `json:"best"`
+	Results  []EvalResult      `json:"results"`
+}
+
+/**
+ * Read an evaluation dataset in JSONL format, one EvalSample per line
+ * @param r dataset content
+ * @return samples; samples without ID are numbered by line
+ */
+func ParseDataset(r io.Reader) ([]EvalSample, error) {
+	var samples []EvalSample
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var s EvalSample
+		if err := json.Unmarshal([]byte(text), &s); err != nil {
+			return nil, fmt.Errorf("dataset line %d: %v", line, err)
+		}
+		if s.ID == "" {
+			s.ID = strconv.Itoa(line)
+		}
+		samples = append(samples, s)
+	}
+	return samples, scanner.Err()
+}
+
+/**
+ * Run an evaluation
+ * @param ctx context of the evaluation; cancelling it stops starting new chats
+ * @param req prompts, models, dataset and scorers
+ * @return comparison report
+ * @throws
+ * - 400 error if the request is incomplete or names an unknown scorer
+ * - 404 error if a prompt or the judge prompt doesn't exist
+ */
+func RunEval(ctx context.Context, req EvalRequest) (EvalReport, error) {
+	report := EvalReport{}
+	if err := checkEvalRequest(ctx, &req); err != nil {
+		return report, err
+	}
+	report.Scorers = req.Scorers
+
+	type job struct {
+		index  int
+		sample EvalSample
+		prompt string
+		model  string
+	}
+	var jobs []job
+	for _, p := range req.Prompts {
+		for _, m := range req.Models {
+			for _, s := range req.Dataset {
+				jobs = append(jobs, job{len(jobs), s, p, m})
+			}
+		}
+	}
+
+	results := make([]EvalResult, len(jobs))
+	sem := make(chan struct{}, req.Concurrency)
+	var wg sync.WaitGroup
+	for _, j := range jobs {
+		select {
+		case <-ctx.Done():
+			results[j.index] = EvalResult{Sample: j.sample.ID, Prompt: j.prompt, Model: j.model, Error: ctx.Err().Error()}
+			continue
+		case sem <- struct{}{}:
+		}
+		wg.Add(1)
+		go func(j job) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[j.index] = evalSample(ctx, req, j.prompt, j.model, j.sample)
+		}(j)
+	}
+	wg.Wait()
+
+	report.Results = results
+	report.Variants = summarizeEval(req, results)
+	report.Best = bestVariants(req.Scorers, report.Variants)
+	return report, nil
+}
+
+/**
+ * Check an evaluation request and fill in defaults
+ * @param ctx context carrying the caller, which must be able to see every prompt used
+ */
+func checkEvalRequest(ctx context.Context, req *EvalRequest) error {
+	if len(req.Prompts) == 0 || len(req.Models) == 0 || len(req.Dataset) == 0 {
+		return utils.NewHttpError(http.StatusBadRequest, "prompts, models and dataset are required")
+	}
+	for _, p := range req.Prompts {
+		if !PromptVisible(ctx, p) {
+			return utils.NewHttpError(http.StatusNotFound, fmt.Sprintf("prompt %s not found", p))
+		}
+	}
+	if len(req.Scorers) == 0 {
+		req.Scorers = []string{ScorerSchema}
+		for _, s := range req.Dataset {
+			if s.Expected != nil {
+				req.Scorers = []string{ScorerExact}
+				break
+			}
+		}
+	}
+	for _, s := range req.Scorers {
+		switch s {
+		case ScorerExact, ScorerSchema:
+		case ScorerJudge:
+			if req.JudgePrompt == "" {
+				return utils.NewHttpError(http.StatusBadRequest, "judge_prompt is required by the judge scorer")
+			}
+			if !PromptVisible(ctx, req.JudgePrompt) {
+				return utils.NewHttpError(http.StatusNotFound, fmt.Sprintf("judge prompt %s not found", req.JudgePrompt))
+			}
+		default:
+			return utils.NewHttpError(http.StatusBadRequest, fmt.Sprintf("unknown scorer: %s", s))
+		}
+	}
+	if req.JudgeMaxScore <= 0 {
+		req.JudgeMaxScore = 1
+	}
+	if req.Concurrency <= 0 {
+		req.Concurrency = defaultEvalConcurrency
+	} else if req.Concurrency > maxEvalConcurrency {
+		req.Concurrency = maxEvalConcurrency
+	}
+	for i := range req.Dataset {
+		if req.Dataset[i].ID == "" {
+			req.Dataset[i].ID = strconv.Itoa(i + 1)
+		}
+	}
+	return nil
+}
+
+/**
+ * Run one sample with one variant and score the output
+ */
+func evalSample(ctx context.Context, req EvalRequest, prompt_id, model string, sample EvalSample) EvalResult {
+	result := EvalResult{
+		Sample:  sample.ID,
+		Prompt:  prompt_id,
+		Model:   model,
+		Scores:  map[string]float64{},
+		Details: map[string]string{},
+	}
+	start := time.Now()
+	resp, err := ChatWithPrompt(ctx, prompt_id, ChatPromptRequest{
+		Model:       model,
+		Args:        sample.Args,
+		Temperature: req.Temperature,
+		MaxTokens:   req.MaxTokens,
+	})
+	result.LatencyMs = time.Since(start).Milliseconds()
+	if err == nil && len(resp.Choices) == 0 {
+		err = fmt.Errorf("LLM returned no choices")
+	}
+	if err != nil {
+		result.Error = err.Error()
+		for _, s := range req.Scorers {
+			result.Scores[s] = 0
+		}
+		return result
+	}
+	result.Output = resp.Choices[0].Message.Content
+	result.Tokens = resp.Usage.TotalTokens
+
+	for _, s := range req.Scorers {
+		var score float64
+		var detail string
+		switch s {
+		case ScorerExact:
+			score, detail = scoreExact(result.Output, sample.Expected)
+		case ScorerSchema:
+			score, detail = scoreSchema(result.Output, prompt_id)
+		case ScorerJudge:
+			score, detail = scoreJudge(ctx, req, model, prompt_id, sample, result.Output)
+		}
+		result.Scores[s] = score
+		if detail != "" {
+			result.Details[s] = detail
+		}
+	}
+	return result
+}
+
+var reCodeFence = regexp.MustCompile("(?s)^```[A-Za-z0-9_-]*\\s*\\n(.*?)\\n?```$")
+
+/**
+ * Parse LLM output as JSON, ignoring a surrounding markdown code fence
+ */
+func parseJSONOutput(output string) (interface{}, error) {
+	text := strings.TrimSpace(output)
+	if m := reCodeFence.FindStringSubmatch(text); m != nil {
+		text = m[1]
+	}
+	var v interface{}
+	err := json.Unmarshal([]byte(text), &v)
+	return v, err
+}
+
+/**
+ * Score 1 if the output equals the expected output
+ * @description
+ * - String expectations are compared ignoring leading and trailing whitespace
+ * - Other expectations are compared with the output parsed as JSON
+ */
+func scoreExact(output string, expected interface{}) (float64, string) {
+	if expected == nil {
+		return 0, "sample has no expected output"
+	}
+	if s, ok := expected.(string); ok {
+		if strings.TrimSpace(output) == strings.TrimSpace(s) {
+			return 1, ""
+		}
+		return 0, "output differs from expected"
+	}
+	v, err := parseJSONOutput(output)
+	if err != nil {
+		return 0, fmt.Sprintf("output is not JSON: %v", err)
+	}
+	if reflect.DeepEqual(v, expected) {
+		return 1, ""
+	}
+	return 0, "output differs from expected"
+}
+
+/**
+ * Score 1 if the output conforms to the Returns schema of the prompt
+ * @description
+ * - Outputs of prompts returning strings are taken as is, others are parsed as JSON
+ */
+func scoreSchema(output, prompt_id string) (float64, string) {
+	prompt, _ := prompts.Get(prompt_id)
+	if len(prompt.Returns) == 0 {
+		return 1, "prompt declares no returns"
+	}
+	var value interface{} = output
+	if prompt.Returns["type"] != "string" {
+		v, err := parseJSONOutput(output)
+		if err != nil {
+			return 0, fmt.Sprintf("output is not JSON: %v", err)
+		}
+		value = v
+	}
+	if err := utils.ValidateValue(value, prompt.Returns); err != nil {
+		return 0, err.Error()
+	}
+	return 1, ""
+}
+
+var reNumber = regexp.MustCompile(`-?\d+(\.\d+)?`)
+
+/**
+ * Score the output with the judge prompt
+ * @return judge score scaled to 0..1, with the judge's reason or error as detail
+ */
+func scoreJudge(ctx context.Context, req EvalRequest, model, prompt_id string, sample EvalSample, output string) (float64, string) {
+	judgeModel := req.JudgeModel
+	if judgeModel == "" {
+		judgeModel = model
+	}
+	resp, err := ChatWithPrompt(ctx, req.JudgePrompt, ChatPromptRequest{
+		Model: judgeModel,
+		Args: map[string]interface{}{
+			"prompt":   prompt_id,
+			"input":    sample.Args,
+			"output":   output,
+			"expected": sample.Expected,
+		},
+	})
+	if err != nil {
+		return 0, fmt.Sprintf("judge failed: %v", err)
+	}
+	if len(resp.Choices) == 0 {
+		return 0, "judge returned no choices"
+	}
+	reply := resp.Choices[0].Message.Content
+
+	var score float64
+	reason := ""
+	var verdict struct {
+		Score  *float64 `json:"score"`
+		Reason string   `json:"reason"`
+	}
+	text := strings.TrimSpace(reply)
+	if m := reCodeFence.FindStringSubmatch(text); m != nil {
+		text = m[1]
+	}
+	if err := json.Unmarshal([]byte(text), &verdict); err == nil && verdict.Score != nil {
+		score, reason = *verdict.Score, verdict.Reason
+	} else if m := reNumber.FindString(reply); m != "" {
+		score, _ = strconv.ParseFloat(m, 64)
+		reason = reply
+	} else {
+		return 0, fmt.Sprintf("judge reply has no score: %s", reply)
+	}
+	score /= req.JudgeMaxScore
+	if score < 0 {
+		score = 0
+	} else if score > 1 {
+		score = 1
+	}
+	return score, reason
+}
+
+/**
+ * Aggregate results by variant, in request order
+ */
+func summarizeEval(req EvalRequest, results []EvalResult) []EvalVariant {
+	var variants []EvalVariant
+	for _, p := range req.Prompts {
+		for _, m := range req.Models {
+			v := EvalVariant{Prompt: p, Model: m, Scores: map[string]float64{}}
+			var latency int64
+			for _, r := range results {
+				if r.Prompt != p || r.Model != m {
+					continue
+				}
+				v.Samples++
+				if r.Error != "" {
+					v.Errors++
+				}
+				latency += r.LatencyMs
+				v.Tokens += r.Tokens
+				for _, s := range req.Scorers {
+					v.Scores[s] += r.Scores[s]
+				}
+			}
+			if v.Samples > 0 {
+				v.AvgLatencyMs = latency / int64(v.Samples)
+				for _, s := range req.Scorers {
+					v.Scores[s] /= float64(v.Samples)
+				}
+			}
+			variants = append(variants, v)
+		}
+	}
+	return variants
+}
+
+/**
+ * Find the variant with the highest mean score of each scorer; ties go to the earlier variant
+ */
+func bestVariants(scorers []string, variants []EvalVariant) map[string]string {
+	best := map[string]string{}
+	for _, s := range scorers {
+		ranked := make([]EvalVariant, len(variants))
+		copy(ranked, variants)
+		sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].Scores[s] > ranked[j].Scores[s] })
+		if len(ranked) > 0 {
+			best[s] = ranked[0].Prompt + "@" + ranked[0].Model
+		}
+	}
+	return best
+}
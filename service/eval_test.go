@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/zgsm-ai/ai-prompt-shell/dao"
+)
+
+/**
+ * Fake the LLM: judge chats get a verdict, other chats echo the last message
+ */
+func fakeLLM(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		var req ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		content := req.Messages[len(req.Messages)-1].Content
+		if strings.HasPrefix(content, "JUDGE ") {
+			if strings.Contains(content, "good") {
+				content = `{"score": 8, "reason": "mostly right"}`
+			} else {
+				content = "Score: 3"
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"model": req.Model,
+			"choices": []interface{}{map[string]interface{}{
+				"message":       map[string]string{"role": "assistant", "content": content},
+				"finish_reason": "stop",
+			}},
+			"usage": map[string]int{"prompt_tokens": 5, "completion_tokens": 5, "total_tokens": 10},
+		})
+	}))
+	t.Cleanup(srv.Close)
+
+	oldClient, oldPrompts := llmClient, prompts
+	t.Cleanup(func() {
+		llmClient, prompts = oldClient, oldPrompts
+		onRefreshPrompts()
+	})
+	llmClient = NewLLMClient(srv.URL, "")
+	prompts = dao.NewPromptCache()
+	user := func(content string) []dao.Message {
+		return []dao.Message{{Role: "user", Content: content}}
+	}
+	prompts.Set("echo", dao.Prompt{Name: "echo", Messages: user("{{.args.text}}")}, dao.PromptOrigin_Direct)
+	prompts.Set("answer", dao.Prompt{
+		Name:     "answer",
+		Messages: user("{{.args.text}}"),
+		Returns: map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{"answer": map[string]interface{}{"type": "string"}},
+			"required":   []interface{}{"answer"},
+		},
+	}, dao.PromptOrigin_Direct)
+	prompts.Set("judge", dao.Prompt{Name: "judge", Messages: user("JUDGE {{.args.output}}")}, dao.PromptOrigin_Direct)
+	onRefreshTools()
+	onRefreshPrompts()
+}
+
+func sample(id, text string, expected interface{}) EvalSample {
+	return EvalSample{ID: id, Args: map[string]interface{}{"text": text}, Expected: expected}
+}
+
+func scoresOf(t *testing.T, report EvalReport, scorer string) map[string]float64 {
+	scores := make(map[string]float64)
+	for _, r := range report.Results {
+		if r.Error != "" {
+			t.Fatalf("sample %s failed: %s", r.Sample, r.Error)
+		}
+		scores[r.Sample] = r.Scores[scorer]
+	}
+	return scores
+}
+
+func TestEvalExact(t *testing.T) {
+	fakeLLM(t)
+	report, err := RunEval(context.Background(), EvalRequest{
+		Prompts: []string{"echo"},
+		Models:  []string{"m"},
+		Dataset: []EvalSample{
+			sample("string", " hello ", "hello"),
+			sample("wrong", "hello", "bye"),
+			sample("json", "```json\n{\"a\": [1, 2]}\n```", map[string]interface{}{"a": []interface{}{1.0, 2.0}}),
+			sample("notjson", "hello", map[string]interface{}{"a": 1.0}),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Scorers) != 1 || report.Scorers[0] != ScorerExact {
+		t.Fatalf("scorers = %v, want exact by default with expected outputs", report.Scorers)
+	}
+	want := map[string]float64{"string": 1, "wrong": 0, "json": 1, "notjson": 0}
+	for id, score := range scoresOf(t, report, ScorerExact) {
+		if score != want[id] {
+			t.Errorf("sample %s scored %v, want %v", id, score, want[id])
+		}
+	}
+	if v := report.Variants[0]; v.Scores[ScorerExact] != 0.5 || v.Tokens != 40 {
+		t.Errorf("variant = %+v, want mean 0.5 and 40 tokens", v)
+	}
+}
+
+func TestEvalSchema(t *testing.T) {
+	fakeLLM(t)
+	report, err := RunEval(context.Background(), EvalRequest{
+		Prompts: []string{"answer", "echo"},
+		Models:  []string{"m"},
+		Dataset: []EvalSample{
+			sample("valid", `{"answer": "42"}`, nil),
+			sample("missing", `{"result": "42"}`, nil),
+			sample("text", "42", nil),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Scorers) != 1 || report.Scorers[0] != ScorerSchema {
+		t.Fatalf("scorers = %v, want schema by default without expected outputs", report.Scorers)
+	}
+	want := map[string]float64{"answer": 1.0 / 3, "echo": 1}
+	for _, v := range report.Variants {
+		if v.Scores[ScorerSchema] != want[v.Prompt] {
+			t.Errorf("prompt %s scored %v, want %v", v.Prompt, v.Scores[ScorerSchema], want[v.Prompt])
+		}
+	}
+	if report.Best[ScorerSchema] != "echo@m" {
+		t.Errorf("best = %s, want echo@m", report.Best[ScorerSchema])
+	}
+}
+
+func TestEvalJudge(t *testing.T) {
+	fakeLLM(t)
+	report, err := RunEval(context.Background(), EvalRequest{
+		Prompts:       []string{"echo"},
+		Models:        []string{"m"},
+		Dataset:       []EvalSample{sample("good", "a good answer", nil), sample("poor", "a poor answer", nil)},
+		Scorers:       []string{ScorerJudge},
+		JudgePrompt:   "judge",
+		JudgeMaxScore: 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]float64{"good": 0.8, "poor": 0.3}
+	for id, score := range scoresOf(t, report, ScorerJudge) {
+		if score != want[id] {
+			t.Errorf("sample %s scored %v, want %v", id, score, want[id])
+		}
+	}
+	if reason := report.Results[0].Details[ScorerJudge]; reason != "mostly right" {
+		t.Errorf("judge reason = %q, want the reason of the verdict", reason)
+	}
+
+	_, err = RunEval(context.Background(), EvalRequest{
+		Prompts:     []string{"echo"},
+		Models:      []string{"m"},
+		Dataset:     []EvalSample{sample("good", "a good answer", nil)},
+		Scorers:     []string{ScorerJudge},
+		JudgePrompt: "missing",
+	})
+	if err == nil {
+		t.Error("missing judge prompt was accepted")
+	}
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"github.com/zgsm-ai/ai-prompt-shell/dao"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/utils"
 	"context"
 )
 
@@ -20,10 +21,10 @@ func Extension(extension_id string) (dao.PromptExtension, bool) {
 /**
  * Get all available extension IDs
  * @return slice of extension IDs
- * @return error if failed to load from Redis
+ * @return error if failed to load from storage
  */
 func ExtensionIDs() ([]string, error) {
-	if err := extensions.LoadFromRedis(context.Background()); err != nil {
+	if err := extensions.Load(context.Background()); err != nil {
 		return nil, err
 	}
 	var result []string
@@ -32,3 +33,78 @@ func ExtensionIDs() ([]string, error) {
 	}
 	return result, nil
 }
+
+/**
+ * Enable or disable an installed extension
+ * @param ctx context of the change, naming its actor in the audit log
+ * @param extension_id ID of the extension to update
+ * @param enabled new state of the extension
+ * @return updated extension content
+ * @return error if the extension doesn't exist or storage write fails
+ * @description
+ * - Disabled extensions stay installed but contribute no prompts
+ * - Contributed prompts are recomputed immediately
+ */
+func EnableExtension(ctx context.Context, extension_id string, enabled bool) (dao.PromptExtension, error) {
+	ext, ok := extensions.Get(extension_id)
+	if !ok {
+		return ext, utils.ErrExtensionNotFound
+	}
+	before := ext
+	ext.Enabled = &enabled
+	if err := extensions.Save(extension_id, ext); err != nil {
+		return ext, err
+	}
+	auditChange(ctx, "extensions", extension_id, before, ext)
+	refreshExtensionPrompts()
+	return ext, nil
+}
+
+/**
+ * Uninstall extension and drop the prompts it contributed
+ * @param ctx context of the change, naming its actor in the audit log
+ * @param extension_id ID of the extension to remove
+ * @return error if the extension doesn't exist or storage delete fails
+ */
+func UninstallExtension(ctx context.Context, extension_id string) error {
+	before, ok := extensions.Get(extension_id)
+	if !ok {
+		return utils.ErrExtensionNotFound
+	}
+	if err := extensions.Remove(extension_id); err != nil {
+		return err
+	}
+	auditChange(ctx, "extensions", extension_id, before, nil)
+	refreshExtensionPrompts()
+	return nil
+}
+
+/**
+ * Recompute extension-contributed prompts and recompile templates
+ */
+func refreshExtensionPrompts() {
+	onRefreshExtensions()
+	onRefreshPrompts()
+}
+
+/**
+ * Install or update an extension
+ * @param ctx context of the change, naming its actor in the audit log
+ * @param extension_id ID of the extension
+ * @param ext extension definition
+ * @return error if storage write fails
+ * @description
+ * - Contributed prompts are recomputed immediately
+ */
+func InstallExtension(ctx context.Context, extension_id string, ext dao.PromptExtension) error {
+	var before any
+	if old, ok := extensions.Get(extension_id); ok {
+		before = old
+	}
+	if err := extensions.Save(extension_id, ext); err != nil {
+		return err
+	}
+	auditChange(ctx, "extensions", extension_id, before, ext)
+	refreshExtensionPrompts()
+	return nil
+}
@@ -0,0 +1,256 @@
+package service
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/zgsm-ai/ai-prompt-shell/internal/auth"
+)
+
+/**
+ * Filter, search and pagination options for list endpoints
+ * @description
+ * - Empty fields don't filter anything
+ * - Page is 1-based; PageSize 0 returns all matching items
+ * - Viewer hides prompts whose ACL excludes it; nil when authentication is disabled
+ */
+type ListOptions struct {
+	Viewer    *auth.Identity
+	Supports  string
+	Language  string
+	Origin    string
+	Publisher string
+	Query     string
+	Page      int
+	PageSize  int
+}
+
+type PromptSummary struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Origin      string   `json:"origin"`
+	Extension   string   `json:"extension,omitempty"`
+	Publisher   string   `json:"publisher,omitempty"`
+	Supports    []string `json:"supports"`
+	Languages   []string `json:"languages,omitempty"`
+}
+
+type ToolSummary struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Module      string   `json:"module"`
+	Type        string   `json:"type"`
+	Description string   `json:"description"`
+	Supports    []string `json:"supports"`
+}
+
+type ExtensionSummary struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	DisplayName string   `json:"displayName"`
+	Publisher   string   `json:"publisher"`
+	Description string   `json:"description"`
+	Version     string   `json:"version"`
+	Enabled     bool     `json:"enabled"`
+	Languages   []string `json:"languages"`
+}
+
+/**
+ * List prompts matching filter options
+ * @param opts filter, search and pagination options
+ * @return page of prompt summaries sorted by ID
+ * @return total number of matching prompts before pagination
+ * @description
+ * - Language and publisher come from the extension contributing the prompt
+ * - Prompts that declare no language, or declare "*", match any language
+ * - Prompts hidden from the viewer by their ACL are left out
+ */
+func ListPrompts(opts ListOptions) ([]PromptSummary, int) {
+	results := []PromptSummary{}
+	for id, p := range prompts.All() {
+		if !promptAllowed(p.Prompt, opts.Viewer) {
+			continue
+		}
+		s := PromptSummary{
+			ID:          id,
+			Name:        p.Name,
+			Description: p.Description,
+			Origin:      string(p.Origin),
+			Extension:   p.Extension,
+			Supports:    p.Supports,
+		}
+		if ext, ok := extensions.Get(p.Extension); ok && p.Extension != "" {
+			s.Publisher = ext.Publisher
+			s.Languages = ext.Contributes.Languages
+		}
+		if opts.Supports != "" && !containsFold(s.Supports, opts.Supports) {
+			continue
+		}
+		if opts.Language != "" && !matchLanguage(s.Languages, opts.Language) {
+			continue
+		}
+		if opts.Origin != "" && !strings.EqualFold(s.Origin, opts.Origin) {
+			continue
+		}
+		if opts.Publisher != "" && !strings.EqualFold(s.Publisher, opts.Publisher) {
+			continue
+		}
+		if !matchQuery(opts.Query, id, s.Name, s.Description) {
+			continue
+		}
+		results = append(results, s)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].ID < results[j].ID })
+	return paginate(results, opts)
+}
+
+/**
+ * List tools matching filter options
+ * @param opts filter, search and pagination options (supports and query apply to tools)
+ * @return page of tool summaries sorted by ID
+ * @return total number of matching tools before pagination
+ */
+func ListTools(opts ListOptions) ([]ToolSummary, int) {
+	results := []ToolSummary{}
+	for id, t := range tools.All() {
+		if opts.Supports != "" && !containsFold(t.Supports, opts.Supports) {
+			continue
+		}
+		if !matchQuery(opts.Query, id, t.Name, t.Description) {
+			continue
+		}
+		results = append(results, ToolSummary{
+			ID:          id,
+			Name:        t.Name,
+			Module:      t.Module,
+			Type:        t.Type,
+			Description: t.Description,
+			Supports:    t.Supports,
+		})
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].ID < results[j].ID })
+	return paginate(results, opts)
+}
+
+/**
+ * List extensions matching filter options
+ * @param opts filter, search and pagination options (language, publisher and query apply to extensions)
+ * @return page of extension summaries sorted by ID
+ * @return total number of matching extensions before pagination
+ * @return error if failed to load from storage
+ */
+func ListExtensions(opts ListOptions) ([]ExtensionSummary, int, error) {
+	ids, err := ExtensionIDs()
+	if err != nil {
+		return nil, 0, err
+	}
+	results := []ExtensionSummary{}
+	for _, id := range ids {
+		ext, ok := extensions.Get(id)
+		if !ok {
+			continue
+		}
+		if opts.Language != "" && !matchLanguage(ext.Contributes.Languages, opts.Language) {
+			continue
+		}
+		if opts.Publisher != "" && !strings.EqualFold(ext.Publisher, opts.Publisher) {
+			continue
+		}
+		if !matchQuery(opts.Query, id, ext.Name, ext.DisplayName, ext.Description) {
+			continue
+		}
+		results = append(results, ExtensionSummary{
+			ID:          id,
+			Name:        ext.Name,
+			DisplayName: ext.DisplayName,
+			Publisher:   ext.Publisher,
+			Description: ext.Description,
+			Version:     ext.Version,
+			Enabled:     ext.IsEnabled(),
+			Languages:   ext.Contributes.Languages,
+		})
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].ID < results[j].ID })
+	items, total := paginate(results, opts)
+	return items, total, nil
+}
+
+/**
+ * Check whether list contains value, ignoring case
+ * @param list values to search
+ * @param value value to look for
+ * @return true if found
+ */
+func containsFold(list []string, value string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ * Check whether declared languages cover the requested language
+ * @param languages languages declared by an extension, empty means any
+ * @param language requested language
+ * @return true if matched
+ */
+func matchLanguage(languages []string, language string) bool {
+	if len(languages) == 0 {
+		return true
+	}
+	return containsFold(languages, "*") || containsFold(languages, language)
+}
+
+/**
+ * Case-insensitive free-text match over several fields
+ * @param query search text, empty matches everything
+ * @param fields fields to search in
+ * @return true if any field contains the query
+ */
+func matchQuery(query string, fields ...string) bool {
+	if query == "" {
+		return true
+	}
+	query = strings.ToLower(query)
+	for _, f := range fields {
+		if strings.Contains(strings.ToLower(f), query) {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ * Slice a sorted result set according to page options
+ * @param items sorted items
+ * @param opts page options
+ * @return items on the requested page, and total item count
+ * @description
+ * - Pages and page sizes of any size are accepted; the bounds are compared before multiplying or adding,
+ *   so they can't overflow
+ */
+func paginate[T any](items []T, opts ListOptions) ([]T, int) {
+	total := len(items)
+	if opts.PageSize <= 0 {
+		return items, total
+	}
+	page := opts.Page
+	if page < 1 {
+		page = 1
+	}
+	if page-1 > total/opts.PageSize {
+		return []T{}, total
+	}
+	start := (page - 1) * opts.PageSize
+	if start >= total {
+		return []T{}, total
+	}
+	end := total
+	if opts.PageSize < total-start {
+		end = start + opts.PageSize
+	}
+	return items[start:end], total
+}
@@ -0,0 +1,371 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/config"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/guard"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/metrics"
+)
+
+// Actions of guard policies
+const (
+	GuardBlock    = "block"    // refuse chats with flagged content
+	GuardWarn     = "warn"     // chat anyway, reporting the findings
+	GuardSanitize = "sanitize" // escape or remove flagged content, then chat
+	GuardOff      = "off"      // don't check
+)
+
+// Outcomes of guard verdicts
+const (
+	GuardPassed    = "passed"
+	GuardFlagged   = "flagged"
+	GuardSanitized = "sanitized"
+	GuardBlocked   = "blocked"
+)
+
+// Content shorter than this isn't sent to classifiers
+const minClassifiedLength = 16
+
+var (
+	guardDetectors     []guardDetector
+	guardDefaultAction string
+
+	guardVerdicts = metrics.NewCounter("ai_prompt_shell_guard_verdicts_total",
+		"Chats checked by the prompt injection guard", "prompt", "action", "outcome")
+	guardFindings = metrics.NewCounter("ai_prompt_shell_guard_findings_total",
+		"Untrusted content flagged by the prompt injection guard", "detector", "rule")
+)
+
+/**
+ * Verdict of the guard on the untrusted content of a chat: its args and tool outputs
+ */
+type GuardVerdict struct {
+	Action   string          `json:"action"`
+	Outcome  string          `json:"outcome" enums:"passed,flagged,sanitized,blocked"`
+	Findings []guard.Finding `json:"findings"`
+	Errors   []string        `json:"errors,omitempty"` // failed detectors; the content they checked isn't flagged by them
+}
+
+/**
+ * Error of a chat blocked by the guard
+ */
+type GuardError struct {
+	Verdict *GuardVerdict
+}
+
+func (e *GuardError) Error() string {
+	f := e.Verdict.Findings[0]
+	return fmt.Sprintf("prompt injection suspected in %s (%s %s)", f.Source, f.Detector, f.Rule)
+}
+
+func (e *GuardError) Code() int {
+	return http.StatusUnprocessableEntity
+}
+
+/**
+ * Detector run by the guard
+ */
+type guardDetector interface {
+	Name() string
+	detect(ctx context.Context, content, source string) ([]guard.Finding, error)
+}
+
+/**
+ * Detector of the guard package, which can't fail
+ */
+type localDetector struct {
+	guard.Detector
+}
+
+func (d localDetector) detect(ctx context.Context, content, source string) ([]guard.Finding, error) {
+	return d.Detect(content, source), nil
+}
+
+/**
+ * Detector asking an LLM to classify content with a prompt
+ * @description
+ * - The prompt gets the content and its source as args "content" and "source"
+ * - The answer is a JSON object with a "score" from 0 to 1 or an "injection" boolean,
+ *   else a bare score, else "yes" or "no"
+ */
+type classifierDetector struct {
+	name      string
+	prompt    string
+	model     string
+	threshold float64
+}
+
+func (d *classifierDetector) Name() string {
+	return d.name
+}
+
+func (d *classifierDetector) detect(ctx context.Context, content, source string) ([]guard.Finding, error) {
+	if len(content) < minClassifiedLength {
+		return nil, nil
+	}
+	// Not on the chat's context, whose guard would check the classifier's own tool outputs
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	kind, data, err := RenderPrompt(ctx, d.prompt, map[string]interface{}{"content": content, "source": source})
+	if err != nil {
+		return nil, fmt.Errorf("render classifier prompt %s: %v", d.prompt, err)
+	}
+	resp, err := llmClient.ChatCompletion(ctx, ChatRequest{Model: d.model, Messages: toChatMessages(kind, data)})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("classifier %s gave no answer", d.name)
+	}
+	score := classifierScore(resp.Choices[0].Message.Content)
+	if score < d.threshold {
+		return nil, nil
+	}
+	return []guard.Finding{{
+		Detector: d.name,
+		Rule:     "classified",
+		Source:   source,
+		Excerpt:  fmt.Sprintf("score %.2f", score),
+		Whole:    true,
+	}}, nil
+}
+
+/**
+ * Get the score of a classifier answer, 0 if it can't be understood
+ */
+func classifierScore(answer string) float64 {
+	answer = strings.TrimSpace(answer)
+	answer = strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(answer, "```json"), "```"), "```")
+	answer = strings.TrimSpace(answer)
+	var obj struct {
+		Score     *float64 `json:"score"`
+		Injection *bool    `json:"injection"`
+	}
+	if json.Unmarshal([]byte(answer), &obj) == nil {
+		if obj.Score != nil {
+			return *obj.Score
+		}
+		if obj.Injection != nil && *obj.Injection {
+			return 1
+		}
+		return 0
+	}
+	word := strings.ToLower(strings.Trim(strings.Fields(answer + " x")[0], ".,:;!"))
+	if score, err := strconv.ParseFloat(word, 64); err == nil {
+		return score
+	}
+	if word == "yes" || word == "true" || word == "injection" {
+		return 1
+	}
+	return 0
+}
+
+/**
+ * Initialize the guard detectors from configuration
+ * @throws error if a detector is invalid or the default action unknown
+ */
+func initGuard(c *config.Config) error {
+	if !validGuardAction(c.Guard.DefaultAction) {
+		return fmt.Errorf("guard.default_action: unknown action %s", c.Guard.DefaultAction)
+	}
+	detectors := c.Guard.Detectors
+	if len(detectors) == 0 {
+		detectors = []config.GuardDetectorConfig{
+			{Name: "rules", Type: "regex", Ruleset: "default"},
+			{Name: "delimiters", Type: "delimiter"},
+		}
+	}
+	var result []guardDetector
+	for i, d := range detectors {
+		if d.Name == "" {
+			d.Name = d.Type
+		}
+		switch d.Type {
+		case "regex":
+			var rules []guard.Rule
+			if d.Ruleset != "" {
+				set, ok := guard.Ruleset(d.Ruleset)
+				if !ok {
+					return fmt.Errorf("guard.detectors[%d]: unknown ruleset %s", i, d.Ruleset)
+				}
+				rules = append(rules, set...)
+			}
+			patterns, err := guard.CompileRules(d.Patterns)
+			if err != nil {
+				return fmt.Errorf("guard.detectors[%d]: %v", i, err)
+			}
+			rules = append(rules, patterns...)
+			result = append(result, localDetector{guard.NewRegexDetector(d.Name, rules)})
+		case "delimiter":
+			result = append(result, localDetector{guard.NewDelimiterDetector(d.Name)})
+		case "classifier":
+			if d.Prompt == "" {
+				return fmt.Errorf("guard.detectors[%d]: classifier needs a prompt", i)
+			}
+			threshold := d.Threshold
+			if threshold <= 0 {
+				threshold = 0.5
+			}
+			result = append(result, &classifierDetector{name: d.Name, prompt: d.Prompt, model: d.Model, threshold: threshold})
+		default:
+			return fmt.Errorf("guard.detectors[%d]: unknown type %s", i, d.Type)
+		}
+	}
+	guardDetectors = result
+	guardDefaultAction = c.Guard.DefaultAction
+	return nil
+}
+
+func validGuardAction(action string) bool {
+	switch action {
+	case "", GuardBlock, GuardWarn, GuardSanitize, GuardOff:
+		return true
+	}
+	return false
+}
+
+/**
+ * Guard of one chat, collecting the findings on its untrusted content
+ */
+type guardRun struct {
+	prompt    string
+	action    string
+	detectors []guardDetector
+
+	mu       sync.Mutex
+	findings []guard.Finding
+	errors   []string
+}
+
+type guardRunKey struct{}
+
+/**
+ * Start guarding a chat with a prompt
+ * @return guard, nil if the prompt isn't guarded
+ * @description
+ * - The policy of the prompt decides the action and detectors, else the default action with all detectors
+ */
+func newGuardRun(prompt_id string) *guardRun {
+	p, _ := prompts.Get(prompt_id)
+	g := &guardRun{prompt: prompt_id, action: guardDefaultAction, detectors: guardDetectors}
+	if p.Guard != nil {
+		g.action = p.Guard.Action
+		if len(p.Guard.Detectors) > 0 {
+			g.detectors = nil
+			for _, d := range guardDetectors {
+				for _, name := range p.Guard.Detectors {
+					if d.Name() == name {
+						g.detectors = append(g.detectors, d)
+					}
+				}
+			}
+		}
+	}
+	if g.action == "" || g.action == GuardOff || !validGuardAction(g.action) {
+		return nil
+	}
+	return g
+}
+
+/**
+ * Get the guard carried by a context
+ * @return guard, nil if tool outputs aren't guarded
+ */
+func guardRunFrom(ctx context.Context) *guardRun {
+	g, _ := ctx.Value(guardRunKey{}).(*guardRun)
+	return g
+}
+
+/**
+ * Check untrusted content
+ * @param source where it comes from, as reported in findings
+ * @return content, sanitized if the action is sanitize
+ */
+func (g *guardRun) check(ctx context.Context, content, source string) string {
+	var findings []guard.Finding
+	for _, d := range g.detectors {
+		f, err := d.detect(ctx, content, source)
+		if err != nil {
+			logrus.Warnf("Guard detector %s failed on %s: %v", d.Name(), source, err)
+			g.mu.Lock()
+			g.errors = append(g.errors, fmt.Sprintf("%s on %s: %v", d.Name(), source, err))
+			g.mu.Unlock()
+			continue
+		}
+		findings = append(findings, f...)
+	}
+	if len(findings) == 0 {
+		return content
+	}
+	g.mu.Lock()
+	g.findings = append(g.findings, findings...)
+	g.mu.Unlock()
+	if g.action == GuardSanitize {
+		return guard.Sanitize(content, findings)
+	}
+	return content
+}
+
+/**
+ * Check the strings of a JSON-like value
+ * @return value, with sanitized strings if the action is sanitize
+ */
+func (g *guardRun) checkValue(ctx context.Context, v interface{}, source string) interface{} {
+	switch val := v.(type) {
+	case string:
+		return g.check(ctx, val, source)
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			result[k] = g.checkValue(ctx, item, source+"."+k)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(val))
+		for i, item := range val {
+			result[i] = g.checkValue(ctx, item, fmt.Sprintf("%s[%d]", source, i))
+		}
+		return result
+	}
+	return v
+}
+
+/**
+ * Give the verdict on the chat, once its prompt is rendered
+ * @throws *GuardError if the action is block and content was flagged
+ */
+func (g *guardRun) finish() (*GuardVerdict, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	v := &GuardVerdict{Action: g.action, Outcome: GuardPassed, Findings: g.findings, Errors: g.errors}
+	if v.Findings == nil {
+		v.Findings = []guard.Finding{}
+	}
+	for _, f := range v.Findings {
+		guardFindings.Inc(f.Detector, f.Rule)
+	}
+	if len(v.Findings) > 0 {
+		switch g.action {
+		case GuardBlock:
+			v.Outcome = GuardBlocked
+		case GuardWarn:
+			v.Outcome = GuardFlagged
+		case GuardSanitize:
+			v.Outcome = GuardSanitized
+		}
+	}
+	guardVerdicts.Inc(g.prompt, g.action, v.Outcome)
+	if v.Outcome == GuardBlocked {
+		return v, &GuardError{Verdict: v}
+	}
+	return v, nil
+}
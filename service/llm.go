@@ -51,6 +51,11 @@ type ChatResponse struct {
 		CompletionTokens int `json:"completion_tokens"`
 		TotalTokens      int `json:"total_tokens"`
 	} `json:"usage"`
+	Guard     *GuardVerdict `json:"guard,omitempty"`     // set by ChatWithPrompt for guarded prompts
+	Redaction *RedactReport `json:"redaction,omitempty"` // set by ChatWithPrompt for redacted prompts
+	Cached    bool          `json:"cached,omitempty"`    // set by ChatWithPrompt for responses from the cache
+	Cache     *CacheStatus  `json:"cache,omitempty"`     // set by ChatWithPrompt for prompts opting in to the cache
+	Session   *SessionTurn  `json:"session,omitempty"`   // set by SendSessionMessage
 }
 
 /**
@@ -112,3 +117,71 @@ func (c *LLMClient) ChatCompletion(ctx context.Context, req ChatRequest) (ChatRe
 
 	return result, nil
 }
+
+// EmbeddingRequest defines embeddings request structure
+type EmbeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type EmbeddingResponse struct {
+	Data []struct {
+		Embedding []float64 `json:"embedding"`
+		Index     int       `json:"index"`
+	} `json:"data"`
+}
+
+/**
+ * Get embedding vectors of texts using the embeddings API
+ * @param ctx context for request cancellation
+ * @param model embedding model
+ * @param input texts to embed
+ * @return vectors in the order of the texts
+ * @return error if API call fails
+ */
+func (c *LLMClient) Embeddings(ctx context.Context, model string, input []string) ([][]float64, error) {
+	reqBody, err := json.Marshal(EmbeddingRequest{Model: model, Input: input})
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(
+		ctx,
+		"POST",
+		fmt.Sprintf("%s/v1/embeddings", c.baseURL),
+		bytes.NewBuffer(reqBody),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.apiKey))
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("embeddings API error: %s", resp.Status)
+	}
+
+	var result EmbeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	vectors := make([][]float64, len(input))
+	for _, d := range result.Data {
+		if d.Index >= 0 && d.Index < len(vectors) {
+			vectors[d.Index] = d.Embedding
+		}
+	}
+	for _, v := range vectors {
+		if len(v) == 0 {
+			return nil, fmt.Errorf("embeddings API returned %d vectors for %d texts", len(result.Data), len(input))
+		}
+	}
+	return vectors, nil
+}
@@ -0,0 +1,273 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+
+	"github.com/zgsm-ai/ai-prompt-shell/dao"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/auth"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/config"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/utils"
+)
+
+// How tool calls are served during a render
+const (
+	ToolModeLive   = ""
+	ToolModeRecord = "record"
+	ToolModeReplay = "replay"
+)
+
+/**
+ * Options controlling how tools are called during a render
+ * @description
+ * - Mocks maps tool IDs to canned responses, returned without calling the tool, in any mode
+ * - In record mode, real tool calls are captured to the fixture file named Fixture
+ * - In replay mode, tool calls are served from the fixture file named Fixture and never reach the tool
+ */
+type ToolOptions struct {
+	Mocks   map[string]interface{} `json:"mocks,omitempty"`
+	Mode    string                 `json:"tool_mode,omitempty"`
+	Fixture string                 `json:"fixture,omitempty"`
+}
+
+/**
+ * Tool call captured in a fixture
+ */
+type ToolCall struct {
+	Tool   string        `json:"tool"`
+	Args   []interface{} `json:"args"`
+	Result interface{}   `json:"result,omitempty"`
+	Error  string        `json:"error,omitempty"`
+}
+
+/**
+ * Fixture file content: tool calls in the order they were made
+ */
+type ToolFixture struct {
+	Calls []ToolCall `json:"calls"`
+}
+
+/**
+ * Tool call settings of one render, carried in its context
+ */
+type ToolSession struct {
+	options ToolOptions
+	fixture ToolFixture
+	used    []bool
+	mu      sync.Mutex
+}
+
+type toolSessionKey struct{}
+
+var fixtureDir = "fixtures"
+
+var reFixtureName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+/**
+ * Initialize the fixture directory from configuration
+ * @param c configuration containing the fixture settings
+ */
+func initFixtures(c *config.Config) {
+	if c.Fixtures.Dir != "" {
+		fixtureDir = c.Fixtures.Dir
+	}
+}
+
+/**
+ * Attach tool call options to a context
+ * @param ctx parent context, carrying the caller
+ * @param opts tool call options
+ * @return context carrying the options, and the session to finish after rendering; the session is nil if opts are empty
+ * @throws
+ * - 400 error if the mode is unknown or the fixture name is missing or invalid
+ * - 403 error if an authenticated caller without the publisher role records, since fixtures are shared by test suites
+ * - 404 error if the fixture to replay doesn't exist
+ */
+func WithToolOptions(ctx context.Context, opts ToolOptions) (context.Context, *ToolSession, error) {
+	if opts.Mocks == nil && opts.Mode == ToolModeLive {
+		return ctx, nil, nil
+	}
+	s := &ToolSession{options: opts}
+	switch opts.Mode {
+	case ToolModeLive:
+	case ToolModeRecord, ToolModeReplay:
+		if _, err := fixturePath(opts.Fixture); err != nil {
+			return ctx, nil, err
+		}
+		if id := auth.FromContext(ctx); opts.Mode == ToolModeRecord && id != nil && !id.HasRole(auth.RolePublisher) {
+			return ctx, nil, utils.NewHttpError(http.StatusForbidden, "recording fixtures requires the publisher role")
+		}
+		if opts.Mode == ToolModeReplay {
+			fixture, err := LoadFixture(opts.Fixture)
+			if err != nil {
+				return ctx, nil, err
+			}
+			s.fixture = fixture
+			s.used = make([]bool, len(fixture.Calls))
+		}
+	default:
+		return ctx, nil, utils.NewHttpError(http.StatusBadRequest, fmt.Sprintf("unknown tool mode: %s", opts.Mode))
+	}
+	return context.WithValue(ctx, toolSessionKey{}, s), s, nil
+}
+
+/**
+ * Get the tool session carried by a context
+ * @return session, or nil if tools are called normally
+ */
+func toolSessionFrom(ctx context.Context) *ToolSession {
+	s, _ := ctx.Value(toolSessionKey{}).(*ToolSession)
+	return s
+}
+
+/**
+ * Finish the session, writing the fixture file in record mode
+ * @return error if the fixture can't be written
+ */
+func (s *ToolSession) Finish() error {
+	if s == nil || s.options.Mode != ToolModeRecord {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return SaveFixture(s.options.Fixture, s.fixture)
+}
+
+/**
+ * Serve a tool call according to the session
+ * @param ctx context of the call
+ * @param toolId ID of the tool
+ * @param tool tool definition
+ * @param args arguments of the call
+ * @return tool result or error
+ */
+func (s *ToolSession) call(ctx context.Context, toolId string, tool *dao.Tool, args []interface{}) (interface{}, error) {
+	if result, ok := s.options.Mocks[toolId]; ok {
+		return result, nil
+	}
+	switch s.options.Mode {
+	case ToolModeReplay:
+		return s.replay(toolId, args)
+	case ToolModeRecord:
+		result, err := invokeTool(ctx, tool, args)
+		s.record(toolId, args, result, err)
+		return result, err
+	}
+	return invokeTool(ctx, tool, args)
+}
+
+func (s *ToolSession) record(toolId string, args []interface{}, result interface{}, err error) {
+	call := ToolCall{Tool: toolId, Args: args, Result: result}
+	if err != nil {
+		call.Error = err.Error()
+	}
+	s.mu.Lock()
+	s.fixture.Calls = append(s.fixture.Calls, call)
+	s.mu.Unlock()
+}
+
+/**
+ * Find the recorded response of a tool call
+ * @description
+ * - Calls match on tool ID and JSON-encoded args; identical calls are served in recorded order, the last one repeating
+ */
+func (s *ToolSession) replay(toolId string, args []interface{}) (interface{}, error) {
+	want, err := json.Marshal(args)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	found := -1
+	for i, call := range s.fixture.Calls {
+		if call.Tool != toolId {
+			continue
+		}
+		if got, _ := json.Marshal(call.Args); string(got) != string(want) {
+			continue
+		}
+		found = i
+		if !s.used[i] {
+			break
+		}
+	}
+	if found < 0 {
+		return nil, fmt.Errorf("no recorded response of tool %s for args %s in fixture %s", toolId, want, s.options.Fixture)
+	}
+	s.used[found] = true
+	call := s.fixture.Calls[found]
+	if call.Error != "" {
+		return nil, fmt.Errorf("%s", call.Error)
+	}
+	return call.Result, nil
+}
+
+/**
+ * Get the path of a fixture file
+ * @param name fixture name, letters, digits, '.', '_' and '-' only
+ * @return path of the fixture file in the fixture directory
+ */
+func fixturePath(name string) (string, error) {
+	if !reFixtureName.MatchString(name) {
+		return "", utils.NewHttpError(http.StatusBadRequest, fmt.Sprintf("invalid fixture name: %q", name))
+	}
+	return filepath.Join(fixtureDir, name+".json"), nil
+}
+
+/**
+ * Load a fixture file
+ * @param name fixture name
+ * @return recorded tool calls
+ * @throws
+ * - 404 error if the fixture doesn't exist
+ */
+func LoadFixture(name string) (ToolFixture, error) {
+	var fixture ToolFixture
+	path, err := fixturePath(name)
+	if err != nil {
+		return fixture, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return fixture, utils.NewHttpError(http.StatusNotFound, fmt.Sprintf("fixture %s not found", name))
+	} else if err != nil {
+		return fixture, err
+	}
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return fixture, fmt.Errorf("invalid fixture %s: %v", name, err)
+	}
+	return fixture, nil
+}
+
+/**
+ * Write a fixture file, replacing any previous recording
+ * @param name fixture name
+ * @param fixture recorded tool calls
+ */
+func SaveFixture(name string, fixture ToolFixture) error {
+	path, err := fixturePath(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(fixtureDir, 0755); err != nil {
+		return err
+	}
+	if fixture.Calls == nil {
+		fixture.Calls = []ToolCall{}
+	}
+	data, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"sort"
+
+	"github.com/zgsm-ai/ai-prompt-shell/dao"
+)
+
+var partials = dao.NewPartialCache()
+
+/**
+ * Get partial by ID from cache
+ * @param partial_id ID of the partial to retrieve
+ * @return partial content if found
+ * @return bool indicating if partial exists
+ */
+func Partial(partial_id string) (dao.Partial, bool) {
+	return partials.Get(partial_id)
+}
+
+/**
+ * Get all available partial IDs
+ * @return sorted slice of partial IDs
+ */
+func PartialIDs() []string {
+	result := []string{}
+	for k := range partials.All() {
+		result = append(result, k)
+	}
+	sort.Strings(result)
+	return result
+}
+
+/**
+ * Create or update a template partial
+ * @param partial_id ID of the partial
+ * @param p partial content
+ * @return error if storage access fails
+ */
+func SavePartial(partial_id string, p dao.Partial) error {
+	if err := dao.SetJSON(dao.IDToKey(partial_id, dao.PREFIX_PARTIALS), p, 0); err != nil {
+		return err
+	}
+	if err := partials.Load(context.Background()); err != nil {
+		return err
+	}
+	onRefreshPartials()
+	onRefreshPrompts()
+	return nil
+}
@@ -1,6 +1,8 @@
 package service
 
 import (
+	"context"
+
 	"github.com/zgsm-ai/ai-prompt-shell/dao"
 )
 
@@ -28,3 +30,27 @@ func PromptIDs() ([]string, error) {
 	}
 	return result, nil
 }
+
+/**
+ * Publish a prompt template directly, without an extension
+ * @param ctx context of the change, naming its actor in the audit log
+ * @param prompt_id ID of the prompt
+ * @param p prompt template
+ * @return error if storage write fails
+ * @description
+ * - Direct prompts take precedence over extension prompts with the same ID
+ * - Templates are recompiled immediately; compile errors are reported by PromptError, not here
+ */
+func SavePrompt(ctx context.Context, prompt_id string, p dao.Prompt) error {
+	var before any
+	if old, ok := prompts.Loaded(prompt_id); ok && old.Origin == dao.PromptOrigin_Direct {
+		before = old.Prompt
+	}
+	if err := dao.SetJSON(dao.IDToKey(prompt_id, dao.PREFIX_TEMPLATES), p, 0); err != nil {
+		return err
+	}
+	auditChange(ctx, "prompts", prompt_id, before, p)
+	prompts.Set(prompt_id, p, dao.PromptOrigin_Direct)
+	onRefreshPrompts()
+	return nil
+}
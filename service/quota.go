@@ -0,0 +1,271 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/zgsm-ai/ai-prompt-shell/dao"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/auth"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/config"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/utils"
+)
+
+// Scopes of quota limits
+const (
+	QuotaUser   = "user"
+	QuotaTenant = "tenant"
+	QuotaPrompt = "prompt"
+	QuotaModel  = "model"
+)
+
+// Limit ID applying to each ID of its scope separately
+const quotaEach = "*"
+
+// Configured quota limits
+var quotaLimits []config.QuotaLimit
+
+/**
+ * State of the quota closest to being exhausted, per kind
+ * @description
+ * - Limit is 0 if no limit of the kind applied
+ * - Reset is when the current window ends; the sliding window frees usage gradually until then
+ */
+type QuotaStatus struct {
+	Limit     int64     `json:"limit"`
+	Remaining int64     `json:"remaining"`
+	Reset     time.Time `json:"reset"`
+}
+
+/**
+ * Quotas checked for a chat, filled in by ChatWithPrompt
+ */
+type QuotaReport struct {
+	Requests QuotaStatus `json:"requests"`
+	Tokens   QuotaStatus `json:"tokens"`
+}
+
+type quotaReportKey struct{}
+
+/**
+ * Initialize quota limits from configuration
+ * @throws error if a limit has an unknown scope, no window, or limits nothing
+ */
+func initQuota(c *config.Config) error {
+	for i, l := range c.Quota.Limits {
+		switch l.Scope {
+		case QuotaUser, QuotaTenant, QuotaPrompt, QuotaModel:
+		default:
+			return fmt.Errorf("quota.limits[%d]: unknown scope %s", i, l.Scope)
+		}
+		if l.Window <= 0 {
+			return fmt.Errorf("quota.limits[%d]: window must be positive", i)
+		}
+		if l.Requests <= 0 && l.Tokens <= 0 {
+			return fmt.Errorf("quota.limits[%d]: neither requests nor tokens is limited", i)
+		}
+		if l.ID == "" {
+			c.Quota.Limits[i].ID = quotaEach
+		}
+	}
+	quotaLimits = c.Quota.Limits
+	return nil
+}
+
+/**
+ * Attach a quota report to a context, to learn the quotas checked by the chats made with it
+ * @return context carrying the report, and the report
+ */
+func WithQuotaReport(ctx context.Context) (context.Context, *QuotaReport) {
+	r := &QuotaReport{}
+	return context.WithValue(ctx, quotaReportKey{}, r), r
+}
+
+/**
+ * A quota limit applied to one ID
+ */
+type quotaCounter struct {
+	limit config.QuotaLimit
+	id    string
+}
+
+/**
+ * Key of the counter of a limit in a fixed window
+ * @param kind requests or tokens
+ * @param bucket index of the fixed window since the Unix epoch
+ */
+func (q quotaCounter) key(kind string, bucket int64) string {
+	return fmt.Sprintf("%s%s:%s:%s:%d:%d", dao.PREFIX_QUOTAS, q.limit.Scope, q.id, kind,
+		int64(q.limit.Window/time.Second), bucket)
+}
+
+/**
+ * Get the IDs a chat is accounted to
+ * @description
+ * - The user is the authenticated caller, else the user field of the request, else the user of the scope
+ * - The tenant is the one of the scope, which for an authenticated caller is its own (auth.Identity.Tenant)
+ */
+func quotaIDs(ctx context.Context, promptId string, req ChatPromptRequest) map[string]string {
+	scope := EnvScopeFrom(ctx)
+	user := scope.User
+	if req.User != "" {
+		user = req.User
+	}
+	if id := auth.FromContext(ctx); id != nil {
+		user = id.Subject
+	}
+	return map[string]string{
+		QuotaUser:   user,
+		QuotaTenant: scope.Tenant,
+		QuotaPrompt: promptId,
+		QuotaModel:  req.Model,
+	}
+}
+
+/**
+ * Get the limits applying to the IDs of a chat
+ * @description
+ * - Limits with the exact ID replace the "*" limits of the same scope
+ * - Empty IDs aren't limited
+ */
+func matchQuotas(ids map[string]string) []quotaCounter {
+	var counters []quotaCounter
+	for _, scope := range []string{QuotaUser, QuotaTenant, QuotaPrompt, QuotaModel} {
+		id := ids[scope]
+		if id == "" {
+			continue
+		}
+		var exact, each []quotaCounter
+		for _, l := range quotaLimits {
+			if l.Scope != scope {
+				continue
+			}
+			if l.ID == id {
+				exact = append(exact, quotaCounter{limit: l, id: id})
+			} else if l.ID == quotaEach {
+				each = append(each, quotaCounter{limit: l, id: id})
+			}
+		}
+		if len(exact) > 0 {
+			counters = append(counters, exact...)
+		} else {
+			counters = append(counters, each...)
+		}
+	}
+	return counters
+}
+
+/**
+ * Usage of a sliding window, estimated from the counters of the current and previous fixed windows
+ */
+func windowUsage(current, previous int64, elapsed float64) int64 {
+	return current + int64(float64(previous)*(1-elapsed))
+}
+
+/**
+ * Check the quotas of a chat, and count it as a request
+ * @param ctx context of the chat; the report it carries, if any, is filled in
+ * @return limits applying to the chat, to account its tokens to
+ * @throws
+ * - 429 error if a request or token quota is exhausted
+ * @description
+ * - Token quotas are checked against the tokens already used, since a chat's own usage is known only after it
+ * - Checks and counts aren't atomic, so concurrent chats may exceed a limit slightly
+ * - Quotas aren't enforced if the counters can't be read
+ */
+func checkQuota(ctx context.Context, promptId string, req ChatPromptRequest) ([]quotaCounter, error) {
+	counters := matchQuotas(quotaIDs(ctx, promptId, req))
+	if len(counters) == 0 {
+		return nil, nil
+	}
+	report, _ := ctx.Value(quotaReportKey{}).(*QuotaReport)
+	if report == nil {
+		report = &QuotaReport{}
+	}
+	now := time.Now()
+
+	var keys []string
+	for _, q := range counters {
+		bucket := now.UnixNano() / int64(q.limit.Window)
+		keys = append(keys, q.key("requests", bucket), q.key("requests", bucket-1),
+			q.key("tokens", bucket), q.key("tokens", bucket-1))
+	}
+	values, err := dao.GetCounters(keys...)
+	if err != nil {
+		logrus.Warnf("Read quota counters failed, quotas are not enforced: %v", err)
+		return nil, nil
+	}
+
+	var exceeded error
+	for i, q := range counters {
+		window := int64(q.limit.Window)
+		elapsed := float64(now.UnixNano()%window) / float64(window)
+		reset := time.Unix(0, (now.UnixNano()/window+1)*window)
+		requests := windowUsage(values[4*i], values[4*i+1], elapsed)
+		tokens := windowUsage(values[4*i+2], values[4*i+3], elapsed)
+		if q.limit.Requests > 0 {
+			updateQuotaStatus(&report.Requests, q.limit.Requests, q.limit.Requests-requests-1, reset)
+			if requests >= q.limit.Requests && exceeded == nil {
+				exceeded = quotaError("request", q, q.limit.Requests, reset, now)
+			}
+		}
+		if q.limit.Tokens > 0 {
+			updateQuotaStatus(&report.Tokens, q.limit.Tokens, q.limit.Tokens-tokens, reset)
+			if tokens >= q.limit.Tokens && exceeded == nil {
+				exceeded = quotaError("token", q, q.limit.Tokens, reset, now)
+			}
+		}
+	}
+	if exceeded != nil {
+		return nil, exceeded
+	}
+	for _, q := range counters {
+		if q.limit.Requests > 0 {
+			bucket := now.UnixNano() / int64(q.limit.Window)
+			if err := dao.IncrCounter(q.key("requests", bucket), 1, 2*q.limit.Window); err != nil {
+				logrus.Warnf("Count quota request failed: %v", err)
+			}
+		}
+	}
+	return counters, nil
+}
+
+/**
+ * Account the tokens used by a chat to its token quotas
+ */
+func chargeQuota(counters []quotaCounter, tokens int) {
+	if tokens <= 0 {
+		return
+	}
+	now := time.Now()
+	for _, q := range counters {
+		if q.limit.Tokens <= 0 {
+			continue
+		}
+		bucket := now.UnixNano() / int64(q.limit.Window)
+		if err := dao.IncrCounter(q.key("tokens", bucket), int64(tokens), 2*q.limit.Window); err != nil {
+			logrus.Warnf("Count quota tokens failed: %v", err)
+		}
+	}
+}
+
+/**
+ * Keep the status of the quota with the least remaining
+ */
+func updateQuotaStatus(s *QuotaStatus, limit, remaining int64, reset time.Time) {
+	if remaining < 0 {
+		remaining = 0
+	}
+	if s.Limit == 0 || remaining < s.Remaining {
+		*s = QuotaStatus{Limit: limit, Remaining: remaining, Reset: reset}
+	}
+}
+
+func quotaError(kind string, q quotaCounter, limit int64, reset, now time.Time) error {
+	retry := int64(reset.Sub(now)/time.Second) + 1
+	return utils.NewHttpError(http.StatusTooManyRequests,
+		fmt.Sprintf("%s quota of %s %s exceeded: %d per %s, retry in %d seconds",
+			kind, q.limit.Scope, q.id, limit, q.limit.Window, retry))
+}
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/zgsm-ai/ai-prompt-shell/dao"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/config"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/metrics"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/redact"
+)
+
+// Modes of redaction policies
+const (
+	RedactPlaceholder = "placeholder" // substitute placeholders, and put the values back in the completion
+	RedactMask        = "mask"        // mask for good
+	RedactOff         = "off"         // don't redact
+)
+
+var (
+	redactDefaultMode string
+	redactEnviron     string
+
+	// Compiled custom patterns, by pattern
+	redactPatterns sync.Map
+
+	redactions = metrics.NewCounter("ai_prompt_shell_redactions_total",
+		"Sensitive values redacted from messages sent to the LLM", "prompt", "detector")
+)
+
+/**
+ * Redactions made in the messages of a chat
+ * @description
+ * - Counts are of the distinct values redacted, by detector; values themselves are never reported
+ */
+type RedactReport struct {
+	Mode   string         `json:"mode" enums:"placeholder,mask"`
+	Counts map[string]int `json:"counts"`
+}
+
+/**
+ * Initialize redaction from configuration
+ * @throws error if the default mode is unknown
+ */
+func initRedact(c *config.Config) error {
+	if !validRedactMode(c.Redact.DefaultMode) {
+		return fmt.Errorf("redact.default_mode: unknown mode %s", c.Redact.DefaultMode)
+	}
+	redactDefaultMode = c.Redact.DefaultMode
+	redactEnviron = c.Redact.Environ
+	return nil
+}
+
+func validRedactMode(mode string) bool {
+	switch mode {
+	case "", RedactPlaceholder, RedactMask, RedactOff:
+		return true
+	}
+	return false
+}
+
+/**
+ * Redaction of one chat
+ */
+type redactRun struct {
+	prompt    string
+	mode      string
+	detectors []redact.Detector
+	vault     *redact.Vault
+	counts    map[string]int
+	seen      map[string]bool
+}
+
+/**
+ * Start redacting a chat with a prompt
+ * @param ctx context of the chat; custom patterns are the shared variable seen by the scope it carries
+ * @return redaction, nil if the prompt isn't redacted
+ * @description
+ * - The policy of the prompt decides the mode and detectors, else the default mode with all detectors
+ */
+func newRedactRun(ctx context.Context, prompt_id string) *redactRun {
+	p, _ := prompts.Get(prompt_id)
+	mode := redactDefaultMode
+	var names []string
+	if p.Redact != nil {
+		mode = p.Redact.Mode
+		names = p.Redact.Detectors
+	}
+	if mode == "" || mode == RedactOff || !validRedactMode(mode) {
+		return nil
+	}
+	detectors := append(builtinRedactors(), customRedactors(ctx)...)
+	if len(names) > 0 {
+		var selected []redact.Detector
+		for _, d := range detectors {
+			for _, name := range names {
+				if d.Name == name {
+					selected = append(selected, d)
+				}
+			}
+		}
+		detectors = selected
+	}
+	return &redactRun{
+		prompt:    prompt_id,
+		mode:      mode,
+		detectors: detectors,
+		vault:     redact.NewVault(),
+		counts:    make(map[string]int),
+		seen:      make(map[string]bool),
+	}
+}
+
+func builtinRedactors() []redact.Detector {
+	var detectors []redact.Detector
+	for _, name := range redact.Builtins() {
+		d, _ := redact.Builtin(name)
+		detectors = append(detectors, d)
+	}
+	return detectors
+}
+
+/**
+ * Get the custom detectors of the shared variable of redaction patterns
+ * @description
+ * - The variable maps names to regexes; a list of regexes is named "custom-<index>"
+ * - Invalid patterns are skipped with a warning, so a bad variable doesn't stop chats
+ */
+func customRedactors(ctx context.Context) []redact.Detector {
+	if redactEnviron == "" {
+		return nil
+	}
+	val, ok := ResolveEnviron(EnvScopeFrom(ctx), redactEnviron)
+	if !ok {
+		return nil
+	}
+	patterns := map[string]string{}
+	switch v := val.Value.(type) {
+	case map[string]interface{}:
+		for name, p := range v {
+			if s, ok := p.(string); ok {
+				patterns[name] = s
+			}
+		}
+	case []interface{}:
+		for i, p := range v {
+			if s, ok := p.(string); ok {
+				patterns[fmt.Sprintf("custom-%d", i)] = s
+			}
+		}
+	case string:
+		patterns["custom-0"] = v
+	}
+	names := make([]string, 0, len(patterns))
+	for name := range patterns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var detectors []redact.Detector
+	for _, name := range names {
+		d, err := compileRedactor(name, patterns[name])
+		if err != nil {
+			logrus.Warnf("Redaction pattern in %s skipped: %v", redactEnviron, err)
+			continue
+		}
+		detectors = append(detectors, d)
+	}
+	return detectors
+}
+
+func compileRedactor(name, pattern string) (redact.Detector, error) {
+	if d, ok := redactPatterns.Load(pattern); ok {
+		return redact.Detector{Name: name, Pattern: d.(redact.Detector).Pattern}, nil
+	}
+	d, err := redact.Compile(name, pattern)
+	if err != nil {
+		return d, err
+	}
+	redactPatterns.Store(pattern, d)
+	return d, nil
+}
+
+/**
+ * Redact the messages sent to the LLM
+ * @return copy of the messages with sensitive values replaced
+ */
+func (r *redactRun) messages(msgs []dao.Message) []dao.Message {
+	result := make([]dao.Message, len(msgs))
+	for i, m := range msgs {
+		result[i] = m
+		result[i].Content = r.text(m.Content)
+	}
+	return result
+}
+
+func (r *redactRun) text(s string) string {
+	return redact.Replace(s, redact.Find(s, r.detectors), func(detector, value string) string {
+		if !r.seen[value] {
+			r.seen[value] = true
+			r.counts[detector]++
+		}
+		if r.mode == RedactMask {
+			return redact.Mask(detector, value)
+		}
+		return r.vault.Substitute(detector, value)
+	})
+}
+
+/**
+ * Put the redacted values back in the completion, in placeholder mode
+ */
+func (r *redactRun) restore(resp *ChatResponse) {
+	if r.mode != RedactPlaceholder {
+		return
+	}
+	for i := range resp.Choices {
+		resp.Choices[i].Message.Content = r.vault.Restore(resp.Choices[i].Message.Content)
+	}
+}
+
+/**
+ * Report the redactions made, and count them
+ */
+func (r *redactRun) report() *RedactReport {
+	for detector, n := range r.counts {
+		redactions.Add(float64(n), r.prompt, detector)
+	}
+	return &RedactReport{Mode: r.mode, Counts: r.counts}
+}
@@ -2,10 +2,13 @@ package service
 
 import (
 	"github.com/zgsm-ai/ai-prompt-shell/dao"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/auth"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/funcs"
 	"github.com/zgsm-ai/ai-prompt-shell/internal/utils"
 	"bytes"
 	"context"
 	"fmt"
+	"slices"
 	"strings"
 	"sync"
 	"text/template"
@@ -30,13 +33,29 @@ type ToolExecutor func(args ...interface{}) (interface{}, error)
 
 type Renderer struct {
 	refreshInterval time.Duration
+	base            *template.Template
 	templates       map[string]*template.Template
+	errors          map[string]*RenderError
 	funcMap         template.FuncMap
+	toolNames       map[string]string // template function name -> tool ID
 	stats           *RenderStats
 }
 
 var renderer *Renderer = NewRenderer()
 
+// Maximum nesting depth of {{prompt}} calls
+const maxPromptDepth = 8
+
+/**
+ * State shared by a top-level render and the prompts it renders inline
+ */
+type renderState struct {
+	ctx   context.Context
+	stack []string
+	funcs template.FuncMap       // overrides of template functions, e.g. mocked tools
+	envs  map[string]interface{} // shared variables seen by the render's scope
+}
+
 /**
  * Create new renderer instance with default settings
  * @return initialized renderer with 10s refresh interval
@@ -45,19 +64,22 @@ func NewRenderer() *Renderer {
 	return &Renderer{
 		refreshInterval: 10 * time.Second,
 		templates:       make(map[string]*template.Template),
+		errors:          make(map[string]*RenderError),
 		funcMap:         make(template.FuncMap),
+		toolNames:       make(map[string]string),
 		stats:           &RenderStats{},
 	}
 }
 
 /**
  * Construct context data by combining environment variables with template args
+ * @param envs environment variables, as seen by the render's scope
  * @param args additional args to include in context
  * @return combined map containing all variables
  */
-func constructContextData(args map[string]interface{}) map[string]interface{} {
+func constructContextData(envs, args map[string]interface{}) map[string]interface{} {
 	data := make(map[string]interface{})
-	for k, v := range environs.All() {
+	for k, v := range envs {
 		data[k] = v
 	}
 	data["args"] = args
@@ -73,88 +95,259 @@ func idToVariable(id string) string {
 	return strings.ReplaceAll(strings.ToLower(id), ".", "_")
 }
 
+/**
+ * Check whether a template function name is taken by a built-in helper
+ * @param name template function name, as made from a tool ID by idToVariable
+ */
+func reservedFuncName(name string) bool {
+	if _, ok := funcs.Builtins()[name]; ok || name == "prompt" {
+		return true
+	}
+	return slices.Contains(templateBuiltins, name)
+}
+
 /**
  * Update template functions when tools are refreshed
+ * @description
+ * - Template functions are the built-in helper library plus one executor per tool
+ * - Tools whose function name collides with a built-in helper or a text/template function are skipped,
+ *   so they can't shadow it
  */
 func onRefreshTools() {
-	newFuncs := make(template.FuncMap)
+	newFuncs := funcs.Builtins()
+	newFuncs["prompt"] = promptPlaceholder
+	toolNames := make(map[string]string)
 	for k, v := range tools.All() {
-		newFuncs[idToVariable(k)] = newToolExecutor(&v)
+		name := idToVariable(k)
+		if reservedFuncName(name) {
+			logrus.Warnf("tool %s is ignored: function name %s is reserved by a built-in helper", k, name)
+			continue
+		}
+		tool := v
+		newFuncs[name] = newToolExecutor(context.Background(), k, &tool)
+		toolNames[name] = k
 	}
 	renderer.funcMap = newFuncs
+	renderer.toolNames = toolNames
+}
+
+/**
+ * Create tool executors bound to the context of a render
+ * @param ctx context of the render, e.g. carrying a tool session
+ * @return template functions of all tools
+ */
+func toolFuncs(ctx context.Context) template.FuncMap {
+	result := make(template.FuncMap)
+	for name, id := range renderer.toolNames {
+		if t, ok := tools.Get(id); ok {
+			result[name] = newToolExecutor(ctx, id, &t)
+		}
+	}
+	return result
+}
+
+/**
+ * Stand-in for the prompt function at parse time, rebound per render by renderTemplate
+ */
+func promptPlaceholder(prompt_id string, args ...interface{}) (string, error) {
+	return "", fmt.Errorf("prompt %s can only be rendered inside a template", prompt_id)
+}
+
+/**
+ * Rebuild the shared namespace of partials when partials or tools are refreshed
+ * @description
+ * - Every prompt template is compiled on a clone of this namespace, so {{template "partial_id" .}} works everywhere
+ * - Partials with syntax errors are skipped and logged
+ */
+func onRefreshPartials() {
+	base := template.New("").Funcs(renderer.funcMap)
+	for id, p := range partials.All() {
+		if _, err := base.New(id).Parse(p.Content); err != nil {
+			logrus.Errorf("partial %s is invalid: %v", id, err)
+		}
+	}
+	renderer.base = base
+}
+
+/**
+ * Compile template text on top of the partials namespace
+ * @param key template key
+ * @param text template text
+ * @param strict whether references to missing map keys fail execution instead of printing "<no value>"
+ * @return compiled template
+ * @return error if template syntax is invalid
+ */
+func compileTemplate(key, text string, strict bool) (*template.Template, error) {
+	var t *template.Template
+	if renderer.base == nil {
+		t = template.New(key).Funcs(renderer.funcMap)
+	} else {
+		base, err := renderer.base.Clone()
+		if err != nil {
+			return nil, err
+		}
+		t = base.New(key)
+	}
+	if strict {
+		t.Option("missingkey=error")
+	}
+	return t.Parse(text)
 }
 
 /**
  * Create executor function for tool
+ * @param ctx context the tool is called with
+ * @param id ID of the tool
  * @param t tool definition to create executor for
  * @return executor function that calls the tool
  */
-func newToolExecutor(t *dao.Tool) ToolExecutor {
+func newToolExecutor(ctx context.Context, id string, t *dao.Tool) ToolExecutor {
 	return func(args ...interface{}) (interface{}, error) {
-		return Call(context.Background(), t, args)
+		return Call(ctx, id, t, args)
 	}
 }
 
 /**
  * Update templates when prompts are refreshed
+ * @description
+ * - Prompts whose templates fail to compile are recorded in renderer.errors, see PromptError
  */
 func onRefreshPrompts() {
-	for key, content := range prompts.All() {
+	newTemplates := make(map[string]*template.Template)
+	newErrors := make(map[string]*RenderError)
+	for prompt_id, content := range prompts.All() {
 		if content.Prompt.Prompt != "" {
-			key = key + ".prompt"
-			t, err := template.New(key).Funcs(renderer.funcMap).Parse(content.Prompt.Prompt)
+			key := prompt_id + ".prompt"
+			t, err := compileTemplate(key, content.Prompt.Prompt, content.Strict)
 			if err != nil {
+				newErrors[prompt_id] = newRenderError(prompt_id, key, err)
+				logrus.Errorf("prompt %s is invalid: %v", prompt_id, newErrors[prompt_id])
 				continue
 			}
-			renderer.templates[key] = t
+			newTemplates[key] = t
 		} else if content.Messages != nil {
-			for i, _ := range content.Messages {
-				msgkey := fmt.Sprintf("%s.messages.%d", key, i)
-				t, err := template.New(msgkey).Funcs(renderer.funcMap).Parse(content.Messages[i].Content)
+			for i := range content.Messages {
+				msgkey := fmt.Sprintf("%s.messages.%d", prompt_id, i)
+				t, err := compileTemplate(msgkey, content.Messages[i].Content, content.Strict)
 				if err != nil {
-					continue
+					newErrors[prompt_id] = newRenderError(prompt_id, msgkey, err)
+					logrus.Errorf("prompt %s is invalid: %v", prompt_id, newErrors[prompt_id])
+					break
 				}
-				renderer.templates[msgkey] = t
+				newTemplates[msgkey] = t
 			}
-			continue
 		} else {
-			logrus.Errorf("prompt %s is invalid", key)
+			newErrors[prompt_id] = newRenderError(prompt_id, prompt_id, fmt.Errorf("prompt has neither prompt nor messages"))
+			logrus.Errorf("prompt %s is invalid", prompt_id)
 		}
 	}
+	renderer.templates = newTemplates
+	renderer.errors = newErrors
+}
+
+/**
+ * Get the compile error of a prompt
+ * @param prompt_id ID of the prompt
+ * @return error recorded when the prompt's templates were compiled, nil if the prompt is valid
+ */
+func PromptError(prompt_id string) *RenderError {
+	if err, ok := renderer.errors[prompt_id]; ok {
+		return err
+	}
+	return nil
 }
 
 /**
  * Execute template rendering with given arguments
+ * @param state render state shared with nested prompts
+ * @param prompt_id ID of the prompt the template belongs to
  * @param templateKey identifier for template to render
  * @param args input values for template
  * @return rendered template as string
- * @return error if template not found or execution fails
+ * @return error if template not found or execution fails; failures are reported as *RenderError
  */
-func renderTemplate(templateKey string, args map[string]interface{}) (string, error) {
+func renderTemplate(state *renderState, prompt_id, templateKey string, args map[string]interface{}) (string, error) {
 	t, ok := renderer.templates[templateKey]
 	if !ok {
+		if err := PromptError(prompt_id); err != nil {
+			return "", err
+		}
 		return "", utils.ErrBug
 	}
-	var buf bytes.Buffer
-	err := t.Execute(&buf, constructContextData(args))
+	t, err := t.Clone()
 	if err != nil {
 		return "", err
 	}
+	if state.funcs != nil {
+		t.Funcs(state.funcs)
+	}
+	t.Funcs(template.FuncMap{"prompt": state.renderInline})
+	var buf bytes.Buffer
+	err = t.Execute(&buf, constructContextData(state.envs, args))
+	if err != nil {
+		return "", newRenderError(prompt_id, templateKey, err)
+	}
 	return buf.String(), nil
 }
 
+/**
+ * Render another prompt inline, backing the {{prompt "prompt_id" .args}} template function
+ * @param s render state of the enclosing render
+ * @param prompt_id ID of the prompt to render
+ * @param args optional args map for the nested prompt
+ * @return rendered text; message prompts are joined by blank lines
+ * @return error on cycles, when nesting exceeds maxPromptDepth, or if rendering fails
+ */
+func (s *renderState) renderInline(prompt_id string, args ...interface{}) (string, error) {
+	for _, id := range s.stack {
+		if id == prompt_id {
+			return "", fmt.Errorf("prompt cycle detected: %s -> %s", strings.Join(s.stack, " -> "), prompt_id)
+		}
+	}
+	if len(s.stack) >= maxPromptDepth {
+		return "", fmt.Errorf("prompt nesting exceeds depth limit %d at %s", maxPromptDepth, prompt_id)
+	}
+	nestedArgs := map[string]interface{}{}
+	if len(args) > 1 {
+		return "", fmt.Errorf("prompt %s: too many arguments", prompt_id)
+	} else if len(args) == 1 && args[0] != nil {
+		m, ok := args[0].(map[string]interface{})
+		if !ok {
+			return "", fmt.Errorf("prompt %s: args must be a map, got %T", prompt_id, args[0])
+		}
+		nestedArgs = m
+	}
+
+	s.stack = append(s.stack, prompt_id)
+	defer func() { s.stack = s.stack[:len(s.stack)-1] }()
+
+	kind, data, err := renderPrompt(s, prompt_id, nestedArgs)
+	if err != nil {
+		return "", err
+	}
+	if kind == "prompt" {
+		return data.(string), nil
+	}
+	var parts []string
+	for _, m := range data.([]dao.Message) {
+		parts = append(parts, m.Content)
+	}
+	return strings.Join(parts, "\n\n"), nil
+}
+
 /**
  * Render all messages in conversation
+ * @param state render state shared with nested prompts
  * @param prompt_id ID of the message template set
  * @param messages message templates to render
  * @param args input values for template
  * @return fully rendered messages
  * @return error if rendering fails
  */
-func renderMessages(prompt_id string, messages []dao.Message, args map[string]interface{}) ([]dao.Message, error) {
+func renderMessages(state *renderState, prompt_id string, messages []dao.Message, args map[string]interface{}) ([]dao.Message, error) {
 	var results []dao.Message
 	for i, message := range messages {
-		content, err := renderTemplate(fmt.Sprintf("%s.messages.%d", prompt_id, i), args)
+		content, err := renderTemplate(state, prompt_id, fmt.Sprintf("%s.messages.%d", prompt_id, i), args)
 		if err != nil {
 			return []dao.Message{}, err
 		}
@@ -168,22 +361,50 @@ func renderMessages(prompt_id string, messages []dao.Message, args map[string]in
 
 /**
  * Render prompt with args
+ * @param ctx context of the render; tool calls are mocked, recorded or replayed if it carries a tool session,
+ *            shared variables are overridden by those of the scope it carries,
+ *            and prompts hidden by their ACL from the caller it carries are not found
  * @param prompt_id ID of prompt to render
  * @param args input args for template
  * @return type of rendered content ("prompt" or "messages")
  * @return rendered content or messages
  * @return error if rendering fails
  */
-func RenderPrompt(prompt_id string, args map[string]interface{}) (string, interface{}, error) {
+func RenderPrompt(ctx context.Context, prompt_id string, args map[string]interface{}) (string, interface{}, error) {
+	if !PromptVisible(ctx, prompt_id) {
+		return "", "", utils.ErrPromptNotFound
+	}
+	state := &renderState{ctx: ctx, stack: []string{prompt_id}, envs: environs.Resolve(EnvScopeFrom(ctx))}
+	if toolSessionFrom(ctx) != nil || guardRunFrom(ctx) != nil || toolCallCacheFrom(ctx) != nil {
+		state.funcs = toolFuncs(ctx)
+	}
+	return renderPrompt(state, prompt_id, args)
+}
+
+/**
+ * Render prompt with args as part of a (possibly nested) render
+ * @param state render state shared with nested prompts
+ * @param prompt_id ID of prompt to render
+ * @param args input args for template
+ * @return type of rendered content, rendered content and error, as RenderPrompt
+ */
+func renderPrompt(state *renderState, prompt_id string, args map[string]interface{}) (string, interface{}, error) {
 	prompt, origin := prompts.Get(prompt_id)
 	if origin == dao.PromptOrigin_Notexist {
 		return "", "", utils.ErrPromptNotFound
 	}
+	// Nested prompts are hidden by their ACL too, so they can't be read through one the caller may see
+	if !promptAllowed(prompt, auth.FromContext(state.ctx)) {
+		return "", "", utils.ErrPromptNotFound
+	}
+	if err := PromptError(prompt_id); err != nil {
+		return "", "", err
+	}
 	if prompt.Prompt != "" {
-		text, err := renderTemplate(prompt_id+".prompt", args)
+		text, err := renderTemplate(state, prompt_id, prompt_id+".prompt", args)
 		return "prompt", text, err
 	} else if prompt.Messages != nil {
-		messages, err := renderMessages(prompt_id, prompt.Messages, args)
+		messages, err := renderMessages(state, prompt_id, prompt.Messages, args)
 		return "messages", messages, err
 	}
 	return "", "", utils.ErrPromptInvalid
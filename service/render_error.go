@@ -0,0 +1,102 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+/**
+ * Error raised while compiling or executing a prompt template
+ * @description
+ * - TemplateKey identifies the failing template, e.g. "{prompt_id}.messages.1" or "{prompt_id}.prompt"
+ * - Line and Column locate the failure in the template text, Column is 0 for syntax errors
+ * - Tool is the ID of the tool whose call failed, if any
+ */
+type RenderError struct {
+	PromptID    string `json:"prompt_id"`
+	TemplateKey string `json:"template"`
+	Line        int    `json:"line,omitempty"`
+	Column      int    `json:"column,omitempty"`
+	Action      string `json:"action,omitempty"`
+	Tool        string `json:"tool,omitempty"`
+	Message     string `json:"message"`
+	status      int
+	err         error
+}
+
+func (e *RenderError) Error() string {
+	loc := e.TemplateKey
+	if e.Line > 0 {
+		loc = fmt.Sprintf("%s:%d", loc, e.Line)
+		if e.Column > 0 {
+			loc = fmt.Sprintf("%s:%d", loc, e.Column)
+		}
+	}
+	return fmt.Sprintf("%s: %s", loc, e.Message)
+}
+
+func (e *RenderError) Unwrap() error {
+	return e.err
+}
+
+/**
+ * Get HTTP status code matching the error
+ * @return 400 for missing args in strict mode or failed calls of built-in helpers, 502 for failed tool calls, 500 otherwise
+ */
+func (e *RenderError) Code() int {
+	return e.status
+}
+
+var (
+	// template: NAME:LINE:COL: executing "NAME" at <ACTION>: MESSAGE
+	reExecError = regexp.MustCompile(`(?s)^template: (.+?):(\d+):(\d+): executing "[^"]*" at <(.*?)>: (.*)$`)
+	// template: NAME:LINE: MESSAGE
+	reParseError = regexp.MustCompile(`(?s)^template: (.+?):(\d+): (.*)$`)
+	reCallError  = regexp.MustCompile(`^error calling ([A-Za-z_][A-Za-z0-9_]*): `)
+)
+
+/**
+ * Convert a text/template error into a RenderError
+ * @param prompt_id ID of the prompt being rendered
+ * @param templateKey key of the failing template
+ * @param err error returned by template Parse or Execute
+ * @return RenderError with location details extracted from err
+ * @description
+ * - For nested templates (partials, {{prompt}}), the location is the outermost call site and Message keeps the full chain
+ */
+func newRenderError(prompt_id, templateKey string, err error) *RenderError {
+	re := &RenderError{
+		PromptID:    prompt_id,
+		TemplateKey: templateKey,
+		Message:     err.Error(),
+		status:      http.StatusInternalServerError,
+		err:         err,
+	}
+	text := err.Error()
+	if m := reExecError.FindStringSubmatch(text); m != nil {
+		re.TemplateKey = m[1]
+		re.Line, _ = strconv.Atoi(m[2])
+		re.Column, _ = strconv.Atoi(m[3])
+		re.Action = m[4]
+		re.Message = m[5]
+	} else if m := reParseError.FindStringSubmatch(text); m != nil {
+		re.TemplateKey = m[1]
+		re.Line, _ = strconv.Atoi(m[2])
+		re.Message = m[3]
+	}
+	if m := reCallError.FindStringSubmatch(re.Message); m != nil && m[1] != "prompt" {
+		if id, ok := renderer.toolNames[m[1]]; ok {
+			re.Tool = id
+			re.status = http.StatusBadGateway
+		} else {
+			// A built-in helper rejected its arguments
+			re.status = http.StatusBadRequest
+		}
+	} else if strings.Contains(re.Message, "map has no entry for key") {
+		re.status = http.StatusBadRequest
+	}
+	return re
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+
+	"github.com/zgsm-ai/ai-prompt-shell/dao"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/config"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/logger"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/secrets"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/utils"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Encrypts secrets in storage, nil if no key is configured
+var secretBox *secrets.Box
+
+// Decrypted secrets by ID, only ever kept in memory
+var secretValues = map[string]string{}
+
+// Reference to a secret in tool headers
+var reSecretRef = regexp.MustCompile(`\$\{secret:([^}]+)\}`)
+
+/**
+ * Set up the secret key from configuration
+ * @return error if the key file can't be read
+ * @description
+ * - Without a key, secrets can't be set and stored secrets are ignored
+ */
+func initSecrets(c *config.Config) error {
+	key, err := secrets.LoadKey(c.Secrets.Key, c.Secrets.KeyFile)
+	if err != nil {
+		return err
+	}
+	if key == nil {
+		secretBox = nil
+		return nil
+	}
+	secretBox, err = secrets.NewBox(key)
+	return err
+}
+
+/**
+ * Load and decrypt secrets from storage
+ * @description
+ * - Secrets that can't be decrypted, e.g. sealed with another key, are skipped with a warning
+ * - Values of loaded secrets are masked in logs from now on
+ */
+func loadSecrets() error {
+	keys, err := dao.KeysByPrefix(dao.PREFIX_SECRETS)
+	if err != nil {
+		return err
+	}
+	if len(keys) > 0 && secretBox == nil {
+		logrus.Warnf("%d secrets are ignored: secrets.key is not configured", len(keys))
+		keys = nil
+	}
+	values := make(map[string]string, len(keys))
+	for _, key := range keys {
+		id := dao.KeyToID(key, dao.PREFIX_SECRETS)
+		var sealed string
+		if err := dao.GetJSON(key, &sealed); err != nil {
+			logrus.Warnf("Secret %s is ignored: %v", id, err)
+			continue
+		}
+		value, err := secretBox.Open(id, sealed)
+		if err != nil {
+			logrus.Warnf("Secret %s is ignored: %v", id, err)
+			continue
+		}
+		values[id] = value
+	}
+	redactions := make([]string, 0, len(values))
+	for _, v := range values {
+		redactions = append(redactions, v)
+	}
+	logger.SetRedactions(redactions)
+	secretValues = values
+	return nil
+}
+
+/**
+ * List the IDs of stored secrets, values are never returned
+ */
+func ListSecrets() ([]string, error) {
+	keys, err := dao.KeysByPrefix(dao.PREFIX_SECRETS)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(keys))
+	for _, key := range keys {
+		ids = append(ids, dao.KeyToID(key, dao.PREFIX_SECRETS))
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+/**
+ * Encrypt and store a secret, creating it if it doesn't exist
+ * @param ctx context of the change, naming its actor in the audit log
+ * @param secret_id ID of the secret
+ * @param value value of the secret
+ * @throws 400 error if no secret key is configured
+ */
+func SetSecret(ctx context.Context, secret_id, value string) error {
+	if secretBox == nil {
+		return utils.NewHttpError(http.StatusBadRequest, "secrets.key is not configured")
+	}
+	sealed, err := secretBox.Seal(secret_id, value)
+	if err != nil {
+		return err
+	}
+	key := dao.IDToKey(secret_id, dao.PREFIX_SECRETS)
+	existed, err := dao.Exists(key)
+	if err != nil {
+		return err
+	}
+	if err := dao.SetJSON(key, sealed, 0); err != nil {
+		return err
+	}
+	// Values aren't audited, sealed or not
+	var before any
+	if existed {
+		before = true
+	}
+	auditChange(ctx, "secrets", secret_id, before, true)
+	return loadSecrets()
+}
+
+/**
+ * Delete a secret
+ * @param ctx context of the change, naming its actor in the audit log
+ * @throws 404 error if the secret doesn't exist
+ */
+func DeleteSecret(ctx context.Context, secret_id string) error {
+	key := dao.IDToKey(secret_id, dao.PREFIX_SECRETS)
+	if ok, err := dao.Exists(key); err != nil {
+		return err
+	} else if !ok {
+		return utils.ErrSecretNotFound
+	}
+	if err := dao.Del(key); err != nil {
+		return err
+	}
+	auditChange(ctx, "secrets", secret_id, true, nil)
+	return loadSecrets()
+}
+
+/**
+ * Replace ${secret:secret_id} references with the values of the secrets
+ * @return text with secrets filled in, error if a secret doesn't exist or can't be decrypted
+ */
+func expandSecrets(text string) (string, error) {
+	var missing string
+	result := reSecretRef.ReplaceAllStringFunc(text, func(ref string) string {
+		id := reSecretRef.FindStringSubmatch(ref)[1]
+		value, ok := secretValues[id]
+		if !ok && missing == "" {
+			missing = id
+		}
+		return value
+	})
+	if missing != "" {
+		return "", fmt.Errorf("secret not found: %s", missing)
+	}
+	return result, nil
+}
+
+/**
+ * Fill in the secrets referred to by tool headers
+ * @return header name -> value
+ */
+func toolHeaders(headers map[string]string) (map[string]string, error) {
+	result := make(map[string]string, len(headers))
+	for name, value := range headers {
+		v, err := expandSecrets(value)
+		if err != nil {
+			return nil, fmt.Errorf("header %s: %v", name, err)
+		}
+		result[name] = v
+	}
+	return result, nil
+}
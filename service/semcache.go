@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/cache"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/config"
+)
+
+// Level of the response cache answering near-duplicates, in process
+const CacheSemantic = "semantic"
+
+var (
+	semanticIndex *cache.VectorIndex
+	embedClient   *LLMClient
+	embedModel    string
+
+	// Version of each prompt its entries were cached for
+	semanticVersions = map[string]string{}
+	semanticMu       sync.Mutex
+)
+
+/**
+ * Initialize the semantic cache from configuration
+ * @description
+ * - The embeddings API defaults to the LLM API
+ * - The cache is disabled without an embedding model or room for entries
+ */
+func initSemanticCache(c *config.Config) {
+	semanticIndex = nil
+	s := c.Cache.Semantic
+	if s.Model == "" || s.MaxEntries <= 0 {
+		return
+	}
+	apiBase, apiKey := s.ApiBase, s.ApiKey
+	if apiBase == "" {
+		apiBase = c.LLM.ApiBase
+	}
+	if apiKey == "" {
+		apiKey = c.LLM.ApiKey
+	}
+	embedClient = NewLLMClient(apiBase, apiKey)
+	embedModel = s.Model
+	semanticIndex = cache.NewVectorIndex(s.MaxEntries)
+}
+
+/**
+ * Lookup of the near-duplicates of a chat in the semantic cache
+ * @description
+ * - Chats are near-duplicates if their final user messages are similar, and all else is the same:
+ *   the prompt and its version, the model, the sampling parameters and the other messages
+ */
+type semanticQuery struct {
+	prompt    string
+	version   string
+	group     string // entries the chat may be answered by
+	text      string // final user message
+	threshold float64
+	vector    []float64 // embedding of the text, once computed
+	failed    bool      // the text couldn't be embedded
+	score     float64   // similarity of the entry found
+}
+
+/**
+ * Prepare the semantic lookup of an LLM request
+ * @return query, nil if the semantic cache is disabled, the prompt sets no similarity or there's no user message
+ */
+func newSemanticQuery(promptId string, req ChatRequest) *semanticQuery {
+	if semanticIndex == nil {
+		return nil
+	}
+	p, _ := prompts.Get(promptId)
+	if p.Cache == nil || p.Cache.Similarity <= 0 {
+		return nil
+	}
+	last := -1
+	for i := len(req.Messages) - 1; i >= 0; i-- {
+		if req.Messages[i].Role == "user" {
+			last = i
+			break
+		}
+	}
+	if last < 0 {
+		return nil
+	}
+	others := req
+	others.Messages = append(req.Messages[:last:last], req.Messages[last+1:]...)
+	// Edits not published by sync don't change the version as audited, the digest catches them
+	version := auditPromptVersion(promptId) + "+" + promptDigest(promptId)
+	return &semanticQuery{
+		prompt:    promptId,
+		version:   version,
+		group:     promptId + "@" + version + ":" + chatCacheKey(others),
+		text:      req.Messages[last].Content,
+		threshold: p.Cache.Similarity,
+	}
+}
+
+/**
+ * Drop the entries cached for other versions of the prompt
+ */
+func (q *semanticQuery) invalidate() {
+	semanticMu.Lock()
+	defer semanticMu.Unlock()
+	if v, ok := semanticVersions[q.prompt]; ok && v != q.version {
+		n := semanticIndex.RemoveTag(q.prompt)
+		logrus.Infof("Prompt %s changed, dropped %d semantic cache entries", q.prompt, n)
+	}
+	semanticVersions[q.prompt] = q.version
+}
+
+/**
+ * Embed the final user message, once
+ * @return false if the embeddings API failed, logged
+ */
+func (q *semanticQuery) embed(ctx context.Context) bool {
+	if q.vector == nil && !q.failed {
+		vectors, err := embedClient.Embeddings(ctx, embedModel, []string{q.text})
+		if err != nil {
+			logrus.Warnf("Embed chat of prompt %s failed: %v", q.prompt, err)
+			q.failed = true
+			return false
+		}
+		q.vector = vectors[0]
+	}
+	return q.vector != nil
+}
+
+/**
+ * Find the cached response of the most similar chat, above the threshold of the prompt
+ * @return response, nil if there's none
+ */
+func (q *semanticQuery) lookup(ctx context.Context) *cachedChat {
+	q.invalidate()
+	if !q.embed(ctx) {
+		return nil
+	}
+	v, score, ok := semanticIndex.Search(q.group, q.vector, q.threshold)
+	if !ok {
+		return nil
+	}
+	q.score = score
+	return v.(*cachedChat)
+}
+
+/**
+ * Keep a cached response for near-duplicates of the chat
+ */
+func (q *semanticQuery) store(ctx context.Context, entry *cachedChat) {
+	q.invalidate()
+	if !q.embed(ctx) {
+		return
+	}
+	semanticIndex.Add(q.prompt, q.group, q.vector, entry, entry.ExpiresAt)
+}
@@ -7,6 +7,8 @@ import (
 	"context"
 	"fmt"
 	"time"
+
+	"github.com/sirupsen/logrus"
 )
 
 var llmClient *LLMClient
@@ -17,28 +19,96 @@ var llmClient *LLMClient
  * @return error if initialization fails (e.g. redis connection)
  */
 func Init(c *config.Config) error {
-	if dao.Client == nil {
-		return utils.ErrRedisError
+	if !dao.Ready() {
+		return utils.ErrStorageError
 	}
 	llmClient = NewLLMClient(c.LLM.ApiBase, c.LLM.ApiKey)
+	initTokenizer(c)
+	initFixtures(c)
+	if err := initSecrets(c); err != nil {
+		return err
+	}
+	if err := initQuota(c); err != nil {
+		return err
+	}
+	if err := initEgress(c); err != nil {
+		return err
+	}
+	if err := initGuard(c); err != nil {
+		return err
+	}
+	if err := initRedact(c); err != nil {
+		return err
+	}
+	if err := initAudit(c); err != nil {
+		return err
+	}
+	initChatCache(c)
+	if err := initSessions(c); err != nil {
+		return err
+	}
 
-	extensions.LoadFromRedis(context.Background())
-	tools.LoadFromRedis(context.Background())
-	environs.LoadFromRedis(context.Background())
-	prompts.LoadFromRedis(context.Background())
+	extensions.Load(context.Background())
+	tools.Load(context.Background())
+	environs.Load(context.Background())
+	loadSecrets()
+	partials.Load(context.Background())
+	prompts.Load(context.Background())
 	onRefreshExtensions()
 	onRefreshTools()
+	onRefreshPartials()
 	onRefreshPrompts()
 
 	go startAutoRefreshTools(c.Refresh.Tool)
 	go startAutoRefreshPrompts(c.Refresh.Prompt)
 	go startAutoRefreshExtensions(c.Refresh.Extension)
 	go startAutoRefreshEnvirionments(c.Refresh.Environ)
+	go startAutoRefreshPartials(c.Refresh.Partial)
+	initSync(c)
+	if err := dao.Watch(reload); err != nil {
+		logrus.Errorf("Failed to watch storage, changes are picked up by periodic refresh only: %v", err)
+	}
 	return nil
 }
 
 /**
- * Start periodic refresh of tools from Redis
+ * Reload items of one kind from storage and rebuild what depends on them
+ * @param prefix key prefix of the item kind, one of dao.PREFIX_*
+ */
+func reload(prefix string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	switch prefix {
+	case dao.PREFIX_TOOLS:
+		tools.Load(ctx)
+		onRefreshTools()
+		onRefreshPartials()
+		onRefreshPrompts()
+	case dao.PREFIX_TEMPLATES:
+		prompts.Load(ctx)
+		onRefreshExtensions()
+		onRefreshPrompts()
+		loadSyncSnapshot()
+	case dao.PREFIX_EXTENSIONS:
+		extensions.Load(ctx)
+		onRefreshExtensions()
+		onRefreshPrompts()
+	case dao.PREFIX_ENVIRONS, dao.PREFIX_SCOPES:
+		environs.Load(ctx)
+	case dao.PREFIX_SECRETS:
+		loadSecrets()
+	case dao.PREFIX_PARTIALS:
+		partials.Load(ctx)
+		onRefreshPartials()
+		onRefreshPrompts()
+	case dao.PREFIX_SYNC:
+		loadSyncSnapshot()
+	}
+}
+
+/**
+ * Start periodic refresh of tools from storage
  * @param interval duration between refreshes
  */
 func startAutoRefreshTools(interval time.Duration) {
@@ -48,16 +118,13 @@ func startAutoRefreshTools(interval time.Duration) {
 	for {
 		select {
 		case <-ticker.C:
-			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-			tools.LoadFromRedis(ctx)
-			onRefreshTools()
-			cancel()
+			reload(dao.PREFIX_TOOLS)
 		}
 	}
 }
 
 /**
- * Start periodic refresh of prompts from Redis
+ * Start periodic refresh of prompts from storage
  * @param interval duration between refreshes
  */
 func startAutoRefreshPrompts(interval time.Duration) {
@@ -67,14 +134,13 @@ func startAutoRefreshPrompts(interval time.Duration) {
 	for {
 		select {
 		case <-ticker.C:
-			prompts.LoadFromRedis(context.Background())
-			onRefreshPrompts()
+			reload(dao.PREFIX_TEMPLATES)
 		}
 	}
 }
 
 /**
- * Start periodic refresh of extensions from Redis
+ * Start periodic refresh of extensions from storage
  * @param interval duration between refreshes
  */
 func startAutoRefreshExtensions(interval time.Duration) {
@@ -84,29 +150,37 @@ func startAutoRefreshExtensions(interval time.Duration) {
 	for {
 		select {
 		case <-ticker.C:
-			extensions.LoadFromRedis(context.Background())
-			onRefreshExtensions()
+			reload(dao.PREFIX_EXTENSIONS)
 		}
 	}
 }
 
 /**
- * Handle extension refresh by updating contributed prompts
+ * Handle extension refresh by recomputing contributed prompts
+ * @description
+ * - Only enabled extensions contribute prompts
+ * - Prompts of removed or disabled extensions are dropped from the prompt cache
  */
 func onRefreshExtensions() {
-	for _, ext := range extensions.All() {
+	contributed := make(map[string]dao.PromptLoaded)
+	for extension_id, ext := range extensions.All() {
+		if !ext.IsEnabled() {
+			continue
+		}
 		for _, p := range ext.Contributes.Prompts {
 			prompt_id := fmt.Sprintf("%s.%s", ext.Name, p.Name)
-			_, origin := prompts.Get(prompt_id)
-			if origin != dao.PromptOrigin_Direct {
-				prompts.Set(prompt_id, p, dao.PromptOrigin_Extension)
+			contributed[prompt_id] = dao.PromptLoaded{
+				Prompt:    p,
+				Origin:    dao.PromptOrigin_Extension,
+				Extension: extension_id,
 			}
 		}
 	}
+	prompts.ReplaceExtensionPrompts(contributed)
 }
 
 /**
- * Start periodic refresh of environments from Redis
+ * Start periodic refresh of environments from storage
  * @param interval duration between refreshes
  */
 func startAutoRefreshEnvirionments(interval time.Duration) {
@@ -116,7 +190,24 @@ func startAutoRefreshEnvirionments(interval time.Duration) {
 	for {
 		select {
 		case <-ticker.C:
-			environs.LoadFromRedis(context.Background())
+			reload(dao.PREFIX_ENVIRONS)
+			reload(dao.PREFIX_SECRETS)
+		}
+	}
+}
+
+/**
+ * Start periodic refresh of partials from storage
+ * @param interval duration between refreshes
+ */
+func startAutoRefreshPartials(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			reload(dao.PREFIX_PARTIALS)
 		}
 	}
 }
@@ -0,0 +1,490 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/zgsm-ai/ai-prompt-shell/dao"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/auth"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/config"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/utils"
+)
+
+// How histories outgrowing the model context are shortened
+const (
+	SessionTrim      = "trim"      // drop the oldest turns
+	SessionSummarize = "summarize" // drop the oldest turns, and keep a summary of them
+)
+
+// How long a turn holds its session while the LLM answers, in case its instance goes away meanwhile
+const sessionTurnLease = 2 * time.Minute
+
+// Instruction given to the LLM summarizing the turns dropped from a history
+const sessionSummaryInstruction = "Summarize the conversation below for the assistant continuing it. " +
+	"Keep the facts, decisions, open questions and the user's requests; drop pleasantries. " +
+	"If a summary so far is given, merge it into yours. Answer with the summary only."
+
+var (
+	sessionTTL           time.Duration
+	sessionOverflow      string
+	sessionSummaryModel  string
+	sessionSummaryTokens int
+)
+
+/**
+ * Model and sampling parameters of the chats of a session, given when it's created
+ */
+type SessionParams struct {
+	Model            string   `json:"model"`
+	Temperature      float64  `json:"temperature,omitempty"`
+	MaxTokens        int      `json:"max_tokens,omitempty"`
+	TopP             float64  `json:"top_p,omitempty"`
+	FrequencyPenalty float64  `json:"frequency_penalty,omitempty"`
+	PresencePenalty  float64  `json:"presence_penalty,omitempty"`
+	Stop             []string `json:"stop,omitempty"`
+	User             string   `json:"user,omitempty"`
+}
+
+/**
+ * Request creating a session from a prompt
+ */
+type CreateSessionRequest struct {
+	PromptID string                 `json:"prompt_id" binding:"required"`
+	Args     map[string]interface{} `json:"args"`
+	Scope    dao.EnvScope           `json:"scope"`
+	SessionParams
+}
+
+/**
+ * Request adding a user turn to a session
+ * @description
+ * - Content may be empty if the history ends with a user message, such as that of the prompt
+ *   the session was created from, to get it answered
+ */
+type SessionMessageRequest struct {
+	Content string `json:"content"`
+}
+
+/**
+ * Multi-turn chat on top of a prompt
+ * @description
+ * - Messages start with the Pinned messages rendered from the prompt, never dropped, followed by the turns
+ * - Turns outgrowing the model context are dropped oldest first, Dropped counting them;
+ *   Summary sums them up when sessions are configured to summarize
+ * - Owner is the caller who created the session; other callers but admins don't find it
+ * - BusyUntil is set while a turn is being answered, other turns are refused until then
+ */
+type ChatSession struct {
+	ID       string `json:"id"`
+	PromptID string `json:"prompt_id"`
+	Version  string `json:"version"` // of the prompt when the session was created, as audited
+	Owner    string `json:"owner"`
+	SessionParams
+	Pinned      int           `json:"pinned"`
+	Messages    []dao.Message `json:"messages"`
+	Summary     string        `json:"summary,omitempty"`
+	Dropped     int           `json:"dropped,omitempty"`
+	Turns       int           `json:"turns"`
+	TotalTokens int           `json:"total_tokens"`
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+	ExpiresAt   time.Time     `json:"expires_at"`
+	BusyUntil   *time.Time    `json:"busy_until,omitempty"`
+	Guard       *GuardVerdict `json:"guard,omitempty"` // of the rendered prompt, reported when the session is created
+}
+
+/**
+ * State of a session after a turn, reported with its answer
+ */
+type SessionTurn struct {
+	ID         string    `json:"id"`
+	Turns      int       `json:"turns"`
+	Dropped    int       `json:"dropped,omitempty"`    // messages dropped from the history so far
+	Summarized bool      `json:"summarized,omitempty"` // the history was summarized by this turn
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+type sessionIDKey struct{}
+
+/**
+ * Initialize sessions from configuration
+ * @throws error if the overflow handling is unknown
+ */
+func initSessions(c *config.Config) error {
+	switch c.Sessions.Overflow {
+	case SessionTrim, SessionSummarize:
+	default:
+		return fmt.Errorf("sessions.overflow: unknown handling %s", c.Sessions.Overflow)
+	}
+	sessionTTL = c.Sessions.TTL
+	sessionOverflow = c.Sessions.Overflow
+	sessionSummaryModel = c.Sessions.SummaryModel
+	sessionSummaryTokens = c.Sessions.SummaryTokens
+	return nil
+}
+
+/**
+ * Create a session, its history being the messages rendered from a prompt
+ * @param ctx context of the render, as ChatWithPrompt
+ * @return session created, with the guard verdict of the prompt if it's guarded
+ * @throws
+ *      - 400 error if max_tokens leaves no room in the model context
+ *      - 500 error if no session ID can be generated
+ *      - render failure, as RenderPromptWithBudget
+ *      - untrusted content flagged by the guard of a prompt blocking it (*GuardError, 422)
+ */
+func CreateSession(ctx context.Context, req CreateSessionRequest) (*ChatSession, error) {
+	p, _ := prompts.Get(req.PromptID)
+	if _, err := inputLimit(p, req.Model, req.MaxTokens); err != nil {
+		return nil, err
+	}
+	run := newGuardRun(req.PromptID)
+	if run != nil {
+		if args, ok := run.checkValue(ctx, req.Args, "args").(map[string]interface{}); ok {
+			req.Args = args
+		}
+		ctx = context.WithValue(ctx, guardRunKey{}, run)
+	}
+	kind, data, err := RenderPromptWithBudget(ctx, req.PromptID, req.Args, req.Model, req.MaxTokens)
+	if err != nil {
+		return nil, err
+	}
+	var verdict *GuardVerdict
+	if run != nil {
+		if verdict, err = run.finish(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Sessions are addressed by their ID alone, it must not be guessable
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, utils.RethrowError(http.StatusInternalServerError, fmt.Errorf("failed to generate session ID: %v", err))
+	}
+	now := time.Now().UTC()
+	messages := toChatMessages(kind, data)
+	s := &ChatSession{
+		ID:            hex.EncodeToString(b),
+		PromptID:      req.PromptID,
+		Version:       auditPromptVersion(req.PromptID),
+		Owner:         auditActor(ctx),
+		SessionParams: req.SessionParams,
+		Pinned:        len(messages),
+		Messages:      messages,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		ExpiresAt:     now.Add(sessionTTL),
+	}
+	stored, _ := json.Marshal(s)
+	if err := dao.SetSession(s.ID, stored, sessionTTL); err != nil {
+		return nil, err
+	}
+	s.Guard = verdict
+	return s, nil
+}
+
+/**
+ * Get a session
+ * @throws 404 error if it doesn't exist, expired, or belongs to another caller
+ */
+func GetSession(ctx context.Context, id string) (*ChatSession, error) {
+	data, err := dao.GetSession(id)
+	if err != nil {
+		return nil, sessionError(id, err)
+	}
+	var s ChatSession
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	if !s.accessibleBy(ctx) {
+		return nil, sessionError(id, dao.ErrNotFound)
+	}
+	return &s, nil
+}
+
+/**
+ * Delete a session
+ * @throws 404 error if it doesn't exist, expired, or belongs to another caller
+ */
+func DeleteSession(ctx context.Context, id string) error {
+	if _, err := GetSession(ctx, id); err != nil {
+		return err
+	}
+	return dao.DelSession(id)
+}
+
+/**
+ * Add a user turn to a session and get it answered by the LLM
+ * @param ctx context of the chat, as ChatWithPrompt
+ * @return answer, with the state of the session
+ * @throws
+ *      - 404 error if the session doesn't exist, expired, or belongs to another caller
+ *      - 400 error if there's no content and the history doesn't end with a user message
+ *      - 409 error if the session is answering another turn, or was changed by one meanwhile
+ *      - 413 error if the pinned messages and the latest turn don't fit the model context
+ *      - exhausted request or token quota (429), and flagged content (*GuardError, 422), as ChatWithPrompt
+ *      - LLM service call failure
+ * @description
+ * - The content is checked by the guard of the prompt, and the messages sent are redacted as its policy says
+ * - The turn isn't kept unless answered; the session TTL is renewed by it
+ * - The session is held while the LLM answers, so a concurrent turn is refused before anything is paid for;
+ *   the answer is then stored by compare-and-swap, and the session released as it was if the turn fails
+ */
+func SendSessionMessage(ctx context.Context, id string, req SessionMessageRequest) (resp ChatResponse, err error) {
+	start := time.Now()
+	var s ChatSession
+	var found bool
+	var sent []dao.Message
+	var answered *ChatResponse
+	defer func() {
+		if found {
+			auditChat(context.WithValue(ctx, sessionIDKey{}, id), s.PromptID, s.chatRequest(), sent, answered, err, start)
+		}
+	}()
+
+	data, err := dao.GetSession(id)
+	if err != nil {
+		return resp, sessionError(id, err)
+	}
+	if err = json.Unmarshal(data, &s); err != nil {
+		return resp, err
+	}
+	if !s.accessibleBy(ctx) {
+		return resp, sessionError(id, dao.ErrNotFound)
+	}
+	found = true
+	now := time.Now().UTC()
+	if s.BusyUntil != nil && now.Before(*s.BusyUntil) {
+		return resp, sessionError(id, dao.ErrConflict)
+	}
+	until := now.Add(sessionTurnLease)
+	s.BusyUntil = &until
+	held, _ := json.Marshal(s)
+	if err = dao.SwapSession(id, data, held, sessionTTL); err != nil {
+		return resp, sessionError(id, err)
+	}
+	s.BusyUntil = nil
+
+	if resp, sent, answered, err = s.reply(ctx, req.Content); err != nil {
+		if e := dao.SwapSession(id, held, data, sessionTTL); e != nil {
+			logrus.Warnf("Release session %s failed: %v", id, e)
+		}
+		return resp, err
+	}
+	now = time.Now().UTC()
+	s.UpdatedAt = now
+	s.ExpiresAt = now.Add(sessionTTL)
+	resp.Session.ExpiresAt = s.ExpiresAt
+	stored, _ := json.Marshal(s)
+	if err = dao.SwapSession(id, held, stored, sessionTTL); err != nil {
+		return resp, sessionError(id, err)
+	}
+	return resp, nil
+}
+
+/**
+ * Get the answer to a user turn, adding both to the history
+ * @return answer; messages sent to the LLM and its raw response, for the audit
+ */
+func (s *ChatSession) reply(ctx context.Context, content string) (ChatResponse, []dao.Message, *ChatResponse, error) {
+	if content == "" && (len(s.Messages) == 0 || s.Messages[len(s.Messages)-1].Role != "user") {
+		return ChatResponse{}, nil, nil, utils.NewHttpError(http.StatusBadRequest, "content is required")
+	}
+	quotas, err := checkQuota(ctx, s.PromptID, s.chatRequest())
+	if err != nil {
+		return ChatResponse{}, nil, nil, err
+	}
+	var verdict *GuardVerdict
+	if content != "" {
+		if run := newGuardRun(s.PromptID); run != nil {
+			content = run.check(ctx, content, "content")
+			if verdict, err = run.finish(); err != nil {
+				return ChatResponse{}, nil, nil, err
+			}
+		}
+		s.Messages = append(s.Messages, dao.Message{Role: "user", Content: content})
+	}
+
+	redaction := newRedactRun(ctx, s.PromptID)
+	summarized, err := s.fit(redaction, quotas)
+	if err != nil {
+		return ChatResponse{}, nil, nil, err
+	}
+	sent := s.context()
+	if redaction != nil {
+		sent = redaction.messages(sent)
+	}
+	resp, used, err := completeChat(llmRequest(s.chatRequest(), sent), quotas)
+	if err != nil {
+		return resp, sent, nil, err
+	}
+	raw := restoreAnswer(&resp, redaction)
+	if redaction != nil {
+		resp.Redaction = redaction.report()
+	}
+	if len(resp.Choices) > 0 {
+		s.Messages = append(s.Messages, dao.Message{Role: "assistant", Content: resp.Choices[0].Message.Content})
+	}
+	s.Turns++
+	s.TotalTokens += used
+	resp.Guard = verdict
+	resp.Session = &SessionTurn{ID: s.ID, Turns: s.Turns, Dropped: s.Dropped, Summarized: summarized}
+	return resp, sent, raw, nil
+}
+
+/**
+ * Drop the oldest turns until the history fits the model context, summarizing them if configured to
+ * @return true if the history was summarized
+ * @description
+ * - The input limit is the model context size minus the tokens of the completion,
+ *   further capped by the budget of the prompt, as RenderPromptWithBudget
+ * - Turns are dropped from a user message to the next one, the latest message is always kept
+ * - Summaries are given up to the summary tokens configured; failures are logged and the turns just dropped
+ * @throws
+ *      - 400 error if the tokens of the completion leave no room in the model context
+ *      - 413 error if the pinned messages and the latest message don't fit
+ */
+func (s *ChatSession) fit(redaction *redactRun, quotas []quotaCounter) (bool, error) {
+	p, _ := prompts.Get(s.PromptID)
+	limit, err := inputLimit(p, s.Model, s.MaxTokens)
+	if err != nil {
+		return false, err
+	}
+	if CountMessages(s.context()).Total <= limit {
+		return false, nil
+	}
+	reserve := 0
+	if sessionOverflow == SessionSummarize {
+		reserve = sessionSummaryTokens
+	}
+	pinned, turns := s.Messages[:s.Pinned], s.Messages[s.Pinned:]
+	count := func() int {
+		return CountMessages(append(pinned[:len(pinned):len(pinned)], turns...)).Total + reserve
+	}
+	var dropped []dao.Message
+	for count() > limit && len(turns) > 1 {
+		n := 1
+		for n < len(turns)-1 && turns[n].Role != "user" {
+			n++
+		}
+		dropped = append(dropped, turns[:n]...)
+		turns = turns[n:]
+	}
+	if count() > limit && reserve > 0 {
+		// No room left for a summary
+		reserve = 0
+	}
+	if n := count(); n > limit {
+		return false, utils.RethrowError(http.StatusRequestEntityTooLarge,
+			fmt.Errorf("session %s needs %d tokens, exceeding the budget of %d tokens", s.ID, n, limit))
+	}
+	s.Messages = append(pinned[:len(pinned):len(pinned)], turns...)
+	s.Dropped += len(dropped)
+	if reserve == 0 {
+		s.Summary = ""
+		return false, nil
+	}
+	summary, err := s.summarize(dropped, redaction, quotas)
+	if err != nil {
+		logrus.Warnf("Summarize session %s failed, dropping %d messages: %v", s.ID, len(dropped), err)
+		return false, nil
+	}
+	s.Summary = summary
+	return true, nil
+}
+
+/**
+ * Sum up the turns dropped from the history, and the summary of those dropped before
+ * @return summary, with placeholders restored
+ * @description
+ * - The tokens used are charged to the quotas of the turn, as its answer
+ */
+func (s *ChatSession) summarize(dropped []dao.Message, redaction *redactRun, quotas []quotaCounter) (string, error) {
+	var b strings.Builder
+	if s.Summary != "" {
+		fmt.Fprintf(&b, "Summary so far:\n%s\n\n", s.Summary)
+	}
+	b.WriteString("Conversation:\n")
+	for _, m := range dropped {
+		fmt.Fprintf(&b, "%s: %s\n\n", m.Role, m.Content)
+	}
+	messages := []dao.Message{
+		{Role: "system", Content: sessionSummaryInstruction},
+		{Role: "user", Content: b.String()},
+	}
+	if redaction != nil {
+		messages = redaction.messages(messages)
+	}
+	model := sessionSummaryModel
+	if model == "" {
+		model = s.Model
+	}
+	resp, _, err := completeChat(ChatRequest{Model: model, MaxTokens: sessionSummaryTokens, Messages: messages}, quotas)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Choices) == 0 {
+		return "", errors.New("no summary returned")
+	}
+	restoreAnswer(&resp, redaction)
+	return resp.Choices[0].Message.Content, nil
+}
+
+/**
+ * Get the messages sent to the LLM: the pinned messages, the summary of the turns dropped, and the turns kept
+ */
+func (s *ChatSession) context() []dao.Message {
+	messages := make([]dao.Message, 0, len(s.Messages)+1)
+	messages = append(messages, s.Messages[:s.Pinned]...)
+	if s.Summary != "" {
+		messages = append(messages, dao.Message{Role: "system", Content: "Summary of the earlier conversation:\n" + s.Summary})
+	}
+	return append(messages, s.Messages[s.Pinned:]...)
+}
+
+/**
+ * Get the chat request the turns of a session are sent, accounted and audited as
+ */
+func (s *ChatSession) chatRequest() ChatPromptRequest {
+	return ChatPromptRequest{
+		Model:            s.Model,
+		Temperature:      s.Temperature,
+		MaxTokens:        s.MaxTokens,
+		TopP:             s.TopP,
+		FrequencyPenalty: s.FrequencyPenalty,
+		PresencePenalty:  s.PresencePenalty,
+		Stop:             s.Stop,
+		User:             s.User,
+	}
+}
+
+/**
+ * Check whether the caller may use a session: its owner, or an admin
+ */
+func (s *ChatSession) accessibleBy(ctx context.Context) bool {
+	if id := auth.FromContext(ctx); id != nil && id.HasRole(auth.RoleAdmin) {
+		return true
+	}
+	return s.Owner == auditActor(ctx)
+}
+
+/**
+ * Map storage errors of a session to HTTP errors
+ */
+func sessionError(id string, err error) error {
+	switch {
+	case errors.Is(err, dao.ErrNotFound):
+		return utils.NewHttpError(http.StatusNotFound, fmt.Sprintf("session %s not found", id))
+	case errors.Is(err, dao.ErrConflict):
+		return utils.NewHttpError(http.StatusConflict, fmt.Sprintf("session %s is busy with another message, retry", id))
+	}
+	return err
+}
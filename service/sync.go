@@ -0,0 +1,539 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/zgsm-ai/ai-prompt-shell/dao"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/config"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/gitsync"
+)
+
+// Directories of the repository layout, as in examples/
+const (
+	syncPromptDir    = "prompt"
+	syncToolDir      = "tool"
+	syncPartialDir   = "partial"
+	syncExtensionDir = "extension"
+)
+
+// Key of the snapshot record of the last published commit
+var syncSnapshotKey = dao.PREFIX_SYNC + "snapshot"
+
+// Returned by SyncNow if sync is not configured
+var ErrSyncDisabled = errors.New("sync is not configured")
+
+/**
+ * Record of the commit published by sync, and of the items it published
+ * @description
+ * - Items are listed so that those removed from the repository are removed from storage by the next sync.
+ *   Items created through the API are left alone unless the repository has an item with the same ID
+ */
+type SyncSnapshot struct {
+	Version     string    `json:"version"`
+	Repo        string    `json:"repo"`
+	Branch      string    `json:"branch,omitempty"`
+	PublishedAt time.Time `json:"published_at"`
+	Prompts     []string  `json:"prompts"`
+	Tools       []string  `json:"tools"`
+	Partials    []string  `json:"partials"`
+	Extensions  []string  `json:"extensions"`
+}
+
+/**
+ * Problem found in a file of the repository
+ */
+type SyncIssue struct {
+	File string `json:"file"`
+	ValidationIssue
+}
+
+/**
+ * State of the sync subsystem
+ * @description
+ * - Snapshot is the live, published commit; LastCommit is the last one fetched, which differs if it was refused
+ * - Error and Issues explain why LastCommit was refused, or why the last sync failed
+ */
+type SyncStatus struct {
+	Enabled    bool          `json:"enabled"`
+	Repo       string        `json:"repo,omitempty"`
+	Branch     string        `json:"branch,omitempty"`
+	Snapshot   *SyncSnapshot `json:"snapshot,omitempty"`
+	LastCommit string        `json:"last_commit,omitempty"`
+	LastSyncAt *time.Time    `json:"last_sync_at,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	Issues     []SyncIssue   `json:"issues,omitempty"`
+}
+
+/**
+ * Items parsed from a commit
+ */
+type syncItems struct {
+	prompts    map[string]dao.Prompt
+	tools      map[string]dao.Tool
+	partials   map[string]dao.Partial
+	extensions map[string]dao.PromptExtension
+}
+
+var (
+	syncConfig   config.SyncConfig
+	syncCheckout *gitsync.Checkout
+	syncRunMu    sync.Mutex // Serializes syncs
+	syncMu       sync.RWMutex
+	syncSnapshot *SyncSnapshot
+	syncStatus   SyncStatus
+)
+
+/**
+ * Start syncing the configured repository
+ * @description
+ * - Sync is disabled if sync.repo is empty
+ * - The repository is synced once immediately, then every sync.interval
+ */
+func initSync(c *config.Config) {
+	loadSyncSnapshot()
+	syncConfig = c.Sync
+	if syncConfig.Repo == "" {
+		return
+	}
+	syncCheckout = &gitsync.Checkout{
+		Repo:   syncConfig.Repo,
+		Branch: syncConfig.Branch,
+		Dir:    syncConfig.Dir,
+	}
+	go startAutoSync(syncConfig.Interval)
+}
+
+/**
+ * Sync on start, then periodically
+ * @param interval duration between syncs
+ */
+func startAutoSync(interval time.Duration) {
+	ctx := withAuditActor(context.Background(), "sync")
+	SyncNow(ctx, false)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			SyncNow(ctx, false)
+		}
+	}
+}
+
+/**
+ * Load the snapshot record of the published commit from storage
+ */
+func loadSyncSnapshot() {
+	var snapshot SyncSnapshot
+	if err := dao.GetJSON(syncSnapshotKey, &snapshot); err != nil {
+		logrus.Errorf("Failed to load sync snapshot: %v", err)
+		return
+	}
+	syncMu.Lock()
+	defer syncMu.Unlock()
+	if snapshot.Version == "" {
+		syncSnapshot = nil
+	} else {
+		syncSnapshot = &snapshot
+	}
+}
+
+/**
+ * Get the state of the sync subsystem
+ */
+func GetSyncStatus() SyncStatus {
+	syncMu.RLock()
+	defer syncMu.RUnlock()
+	status := syncStatus
+	status.Enabled = syncCheckout != nil
+	status.Repo = syncConfig.Repo
+	status.Branch = syncConfig.Branch
+	status.Snapshot = syncSnapshot
+	return status
+}
+
+/**
+ * Get the commit a prompt was published from
+ * @param prompt_id ID of the prompt
+ * @return commit SHA, empty if the prompt wasn't published by sync
+ */
+func PromptVersion(prompt_id string) string {
+	syncMu.RLock()
+	defer syncMu.RUnlock()
+	if syncSnapshot == nil {
+		return ""
+	}
+	p, ok := prompts.Loaded(prompt_id)
+	if !ok {
+		return ""
+	}
+	if p.Origin == dao.PromptOrigin_Direct && slices.Contains(syncSnapshot.Prompts, prompt_id) {
+		return syncSnapshot.Version
+	}
+	if p.Origin == dao.PromptOrigin_Extension && slices.Contains(syncSnapshot.Extensions, p.Extension) {
+		return syncSnapshot.Version
+	}
+	return ""
+}
+
+/**
+ * Fetch the latest commit, validate it and publish it if valid
+ * @param ctx context bounding git commands
+ * @param force validate and publish even if the commit was already published or refused
+ * @return sync state after the attempt
+ * @return error if the commit can't be fetched or is refused; the live items are left untouched
+ */
+func SyncNow(ctx context.Context, force bool) (SyncStatus, error) {
+	if syncCheckout == nil {
+		return GetSyncStatus(), ErrSyncDisabled
+	}
+	syncRunMu.Lock()
+	defer syncRunMu.Unlock()
+
+	err := syncCommit(ctx, force)
+	if err != nil {
+		logrus.Errorf("Sync of %s failed: %v", syncConfig.Repo, err)
+	}
+	return GetSyncStatus(), err
+}
+
+func syncCommit(ctx context.Context, force bool) error {
+	now := time.Now()
+	sha, err := syncCheckout.Update(ctx)
+	if err != nil {
+		setSyncResult(&now, "", err, nil)
+		return err
+	}
+
+	status := GetSyncStatus()
+	if !force {
+		if status.Snapshot != nil && status.Snapshot.Version == sha {
+			setSyncResult(&now, sha, nil, nil)
+			return nil
+		}
+		if status.LastCommit == sha && status.Error != "" {
+			// Already refused, keep reporting why without logging it again
+			return nil
+		}
+	}
+
+	items, issues := parseSyncItems(filepath.Join(syncConfig.Dir, syncConfig.Path))
+	issues = append(issues, validateSyncItems(items, status.Snapshot)...)
+	if len(issues) > 0 {
+		err := fmt.Errorf("commit %s refused: %d problems found", shortSHA(sha), len(issues))
+		setSyncResult(&now, sha, err, issues)
+		return err
+	}
+	if len(items.prompts)+len(items.tools)+len(items.extensions) == 0 {
+		err := fmt.Errorf("commit %s refused: no prompts, tools or extensions found under %q", shortSHA(sha), syncConfig.Path)
+		setSyncResult(&now, sha, err, nil)
+		return err
+	}
+
+	snapshot := SyncSnapshot{
+		Version:     sha,
+		Repo:        syncConfig.Repo,
+		Branch:      syncConfig.Branch,
+		PublishedAt: now,
+	}
+	if err := publishSyncItems(ctx, items, &snapshot, status.Snapshot); err != nil {
+		setSyncResult(&now, sha, err, nil)
+		return err
+	}
+	logrus.Infof("Published commit %s of %s: %d prompts, %d tools, %d partials, %d extensions", shortSHA(sha), syncConfig.Repo,
+		len(snapshot.Prompts), len(snapshot.Tools), len(snapshot.Partials), len(snapshot.Extensions))
+	setSyncResult(&now, sha, nil, nil)
+	return nil
+}
+
+func setSyncResult(at *time.Time, sha string, err error, issues []SyncIssue) {
+	syncMu.Lock()
+	defer syncMu.Unlock()
+	syncStatus.LastSyncAt = at
+	if sha != "" {
+		syncStatus.LastCommit = sha
+	}
+	syncStatus.Error = ""
+	if err != nil {
+		syncStatus.Error = err.Error()
+	}
+	syncStatus.Issues = issues
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 12 {
+		return sha[:12]
+	}
+	return sha
+}
+
+/**
+ * Parse the items of a checkout in the examples/ layout
+ * @param root directory holding prompt/, tool/, partial/ and extension/
+ * @return parsed items, and problems with files that can't be parsed
+ * @description
+ * - prompt/, tool/ and partial/ hold one JSON file per item, IDs are paths without .json, with "/" replaced by "."
+ * - extension/ holds one directory per extension, named by its ID, with the extension's package.json
+ * - Other files are ignored
+ */
+func parseSyncItems(root string) (syncItems, []SyncIssue) {
+	items := syncItems{
+		prompts:    map[string]dao.Prompt{},
+		tools:      map[string]dao.Tool{},
+		partials:   map[string]dao.Partial{},
+		extensions: map[string]dao.PromptExtension{},
+	}
+	var issues []SyncIssue
+	walk := func(dir string, parse func(id, file string, data []byte) error) {
+		base := filepath.Join(root, dir)
+		err := filepath.WalkDir(base, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || !strings.HasSuffix(path, ".json") {
+				return nil
+			}
+			rel, _ := filepath.Rel(root, path)
+			rel = filepath.ToSlash(rel)
+			id, _ := filepath.Rel(base, path)
+			id = strings.ReplaceAll(strings.TrimSuffix(filepath.ToSlash(id), ".json"), "/", ".")
+			data, err := os.ReadFile(path)
+			if err == nil {
+				err = parse(id, rel, data)
+			}
+			if err != nil {
+				issues = append(issues, SyncIssue{File: rel, ValidationIssue: ValidationIssue{Kind: IssueSyntax, Message: err.Error()}})
+			}
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			issues = append(issues, SyncIssue{File: dir, ValidationIssue: ValidationIssue{Kind: IssueSyntax, Message: err.Error()}})
+		}
+	}
+
+	walk(syncPromptDir, func(id, file string, data []byte) error {
+		var p dao.Prompt
+		if err := json.Unmarshal(data, &p); err != nil {
+			return err
+		}
+		items.prompts[id] = p
+		return nil
+	})
+	walk(syncToolDir, func(id, file string, data []byte) error {
+		var t dao.Tool
+		if err := json.Unmarshal(data, &t); err != nil {
+			return err
+		}
+		items.tools[id] = t
+		return nil
+	})
+	walk(syncPartialDir, func(id, file string, data []byte) error {
+		var p dao.Partial
+		if err := json.Unmarshal(data, &p); err != nil {
+			return err
+		}
+		items.partials[id] = p
+		return nil
+	})
+	walk(syncExtensionDir, func(id, file string, data []byte) error {
+		ext_id, ok := strings.CutSuffix(id, ".package")
+		if !ok || strings.Contains(ext_id, ".") {
+			return nil
+		}
+		var ext dao.PromptExtension
+		if err := json.Unmarshal(data, &ext); err != nil {
+			return err
+		}
+		items.extensions[ext_id] = ext
+		return nil
+	})
+	return items, issues
+}
+
+/**
+ * Validate parsed items as a whole before publishing them
+ * @param items items parsed from the commit
+ * @param previous snapshot of the published commit, nil if none
+ * @return problems found
+ * @description
+ * - Templates are checked against the tools of the commit, plus the tools in storage that sync doesn't manage
+ */
+func validateSyncItems(items syncItems, previous *SyncSnapshot) []SyncIssue {
+	var issues []SyncIssue
+	add := func(file string, found []ValidationIssue) {
+		for _, issue := range found {
+			issues = append(issues, SyncIssue{File: file, ValidationIssue: issue})
+		}
+	}
+
+	toolFuncs := map[string]bool{}
+	for id := range tools.All() {
+		if previous == nil || !slices.Contains(previous.Tools, id) {
+			toolFuncs[idToVariable(id)] = true
+		}
+	}
+	for _, id := range sortedKeys(items.tools) {
+		t := items.tools[id]
+		file := syncFile(syncToolDir, id)
+		toolFuncs[idToVariable(id)] = true
+		if name := idToVariable(id); reservedFuncName(name) {
+			add(file, []ValidationIssue{{Kind: IssueSchema,
+				Message: fmt.Sprintf("function name %s of the tool is reserved by a built-in helper", name)}})
+		}
+		if !slices.Contains(dao.ValidToolTypes, t.Type) {
+			add(file, []ValidationIssue{{Kind: IssueSchema, Field: "type",
+				Message: fmt.Sprintf("unknown tool type %q, must be one of %s", t.Type, strings.Join(dao.ValidToolTypes, ", "))}})
+		}
+		if t.Type == "restful" && (t.Restful == nil || t.Restful.Url == "") {
+			add(file, []ValidationIssue{{Kind: IssueSchema, Field: "restful.url", Message: "restful tool has no url"}})
+		}
+		if t.Type == "grpc" && (t.Grpc == nil || t.Grpc.Url == "") {
+			add(file, []ValidationIssue{{Kind: IssueSchema, Field: "grpc.url", Message: "grpc tool has no url"}})
+		}
+		add(file, checkSchema("parameters", t.Parameters))
+		add(file, checkSchema("returns", t.Returns))
+	}
+
+	for _, id := range sortedKeys(items.partials) {
+		if _, err := parseTemplate(id, items.partials[id].Content); err != nil {
+			re := newRenderError(id, "content", err)
+			add(syncFile(syncPartialDir, id), []ValidationIssue{{Kind: IssueSyntax, Field: "content", Line: re.Line, Message: re.Message}})
+		}
+	}
+	for _, id := range sortedKeys(items.prompts) {
+		result := validatePrompt(context.Background(), ValidatePromptRequest{Prompt: items.prompts[id]}, toolFuncs)
+		add(syncFile(syncPromptDir, id), result.Issues)
+	}
+	for _, id := range sortedKeys(items.extensions) {
+		ext := items.extensions[id]
+		file := syncExtensionDir + "/" + id + "/package.json"
+		if ext.Name == "" {
+			add(file, []ValidationIssue{{Kind: IssueSchema, Field: "name", Message: "extension has no name"}})
+		}
+		for i, p := range ext.Contributes.Prompts {
+			result := validatePrompt(context.Background(), ValidatePromptRequest{Prompt: p}, toolFuncs)
+			for _, issue := range result.Issues {
+				issue.Field = fmt.Sprintf("contributes.prompts.%d.%s", i, issue.Field)
+				add(file, []ValidationIssue{issue})
+			}
+		}
+	}
+	return issues
+}
+
+func syncFile(dir, id string) string {
+	return dir + "/" + strings.ReplaceAll(id, ".", "/") + ".json"
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+/**
+ * Write the items of a commit and its snapshot record to storage at once, then reload the caches
+ * @param ctx context of the sync, naming its actor in the audit log
+ * @param items validated items of the commit
+ * @param snapshot snapshot record of the commit, its item lists are filled in
+ * @param previous snapshot of the published commit, whose items missing from this commit are deleted
+ */
+func publishSyncItems(ctx context.Context, items syncItems, snapshot *SyncSnapshot, previous *SyncSnapshot) error {
+	values := map[string]any{}
+	var dels []string
+	type change struct {
+		kind, id      string
+		before, after any
+	}
+	var changes []change
+	publish := func(kind, prefix string, ids []string, old []string, value func(id string) any, current func(id string) any) []string {
+		for _, id := range ids {
+			values[dao.IDToKey(id, prefix)] = value(id)
+			changes = append(changes, change{kind, id, current(id), value(id)})
+		}
+		for _, id := range old {
+			if !slices.Contains(ids, id) {
+				dels = append(dels, dao.IDToKey(id, prefix))
+				changes = append(changes, change{kind, id, current(id), nil})
+			}
+		}
+		return ids
+	}
+	var old SyncSnapshot
+	if previous != nil {
+		old = *previous
+	}
+	currentPrompts := prompts.All()
+	snapshot.Prompts = publish("prompts", dao.PREFIX_TEMPLATES, sortedKeys(items.prompts), old.Prompts,
+		func(id string) any { return items.prompts[id] },
+		func(id string) any {
+			if p, ok := currentPrompts[id]; ok && p.Origin == dao.PromptOrigin_Direct {
+				return p.Prompt
+			}
+			return nil
+		})
+	snapshot.Tools = publish("tools", dao.PREFIX_TOOLS, sortedKeys(items.tools), old.Tools,
+		func(id string) any { return items.tools[id] },
+		func(id string) any {
+			if t, ok := tools.Get(id); ok {
+				return t
+			}
+			return nil
+		})
+	snapshot.Partials = publish("partials", dao.PREFIX_PARTIALS, sortedKeys(items.partials), old.Partials,
+		func(id string) any { return items.partials[id] },
+		func(id string) any {
+			if p, ok := partials.Get(id); ok {
+				return p
+			}
+			return nil
+		})
+	// The enabled state set through the extension API survives commits that don't set it
+	for id, ext := range items.extensions {
+		if cur, ok := extensions.Get(id); ok && ext.Enabled == nil && cur.Enabled != nil {
+			ext.Enabled = cur.Enabled
+			items.extensions[id] = ext
+		}
+	}
+	snapshot.Extensions = publish("extensions", dao.PREFIX_EXTENSIONS, sortedKeys(items.extensions), old.Extensions,
+		func(id string) any { return items.extensions[id] },
+		func(id string) any {
+			if ext, ok := extensions.Get(id); ok {
+				return ext
+			}
+			return nil
+		})
+	values[syncSnapshotKey] = snapshot
+
+	if err := dao.Apply(values, dels); err != nil {
+		return err
+	}
+	syncMu.Lock()
+	syncSnapshot = snapshot
+	syncMu.Unlock()
+	for _, c := range changes {
+		auditChange(ctx, c.kind, c.id, c.before, c.after)
+	}
+
+	reload(dao.PREFIX_EXTENSIONS)
+	reload(dao.PREFIX_TOOLS)
+	reload(dao.PREFIX_PARTIALS)
+	reload(dao.PREFIX_TEMPLATES)
+	return nil
+}
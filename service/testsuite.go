@@ -0,0 +1,271 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/zgsm-ai/ai-prompt-shell/dao"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/utils"
+	"gopkg.in/yaml.v3"
+)
+
+/**
+ * Regression test suite of a prompt
+ * @description
+ * - Stored as YAML or JSON next to the prompt, e.g. code_review.test.yaml beside code_review.json
+ * - Prompt defaults to the file name without the .test.yaml/.test.json suffix
+ */
+type TestSuite struct {
+	Prompt string     `json:"prompt,omitempty" yaml:"prompt,omitempty"`
+	Cases  []TestCase `json:"cases" yaml:"cases"`
+}
+
+/**
+ * One rendering of the prompt and what it is expected to produce
+ * @description
+ * - Mocks maps tool IDs to canned responses; if Fixture is set, other tool calls are replayed from it, see ToolOptions
+ */
+type TestCase struct {
+	Name    string                 `json:"name" yaml:"name"`
+	Args    map[string]interface{} `json:"args" yaml:"args"`
+	Mocks   map[string]interface{} `json:"mocks,omitempty" yaml:"mocks,omitempty"`
+	Fixture string                 `json:"fixture,omitempty" yaml:"fixture,omitempty"`
+	Expect  TestExpect             `json:"expect" yaml:"expect"`
+}
+
+/**
+ * Expected rendering result, all given fields must hold
+ * @description
+ * - Prompt/Messages are the exact (golden) output, compared with a line diff
+ * - Contains, NotContains and Regex apply to the rendered text; message contents are joined by blank lines
+ * - TokenLimit is the maximum token count of the rendered result, as reported by render
+ */
+type TestExpect struct {
+	Prompt      *string       `json:"prompt,omitempty" yaml:"prompt,omitempty"`
+	Messages    []dao.Message `json:"messages,omitempty" yaml:"messages,omitempty"`
+	Contains    []string      `json:"contains,omitempty" yaml:"contains,omitempty"`
+	NotContains []string      `json:"not_contains,omitempty" yaml:"not_contains,omitempty"`
+	Regex       []string      `json:"regex,omitempty" yaml:"regex,omitempty"`
+	TokenLimit  int           `json:"token_limit,omitempty" yaml:"token_limit,omitempty"`
+}
+
+type TestCaseResult struct {
+	Name     string   `json:"name"`
+	Passed   bool     `json:"passed"`
+	Failures []string `json:"failures,omitempty"`
+	Diff     string   `json:"diff,omitempty"`
+	Tokens   int      `json:"tokens"`
+}
+
+type TestReport struct {
+	Prompt string           `json:"prompt"`
+	Passed int              `json:"passed"`
+	Failed int              `json:"failed"`
+	Cases  []TestCaseResult `json:"cases"`
+}
+
+/**
+ * Load a test suite file
+ * @param path path of a .yaml, .yml or .json file
+ * @return test suite, with Prompt defaulted from the file name
+ */
+func LoadTestSuite(path string) (TestSuite, error) {
+	var suite TestSuite
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return suite, err
+	}
+	switch filepath.Ext(path) {
+	case ".json":
+		err = json.Unmarshal(data, &suite)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &suite)
+	default:
+		return suite, fmt.Errorf("unsupported test suite format: %s", path)
+	}
+	if err != nil {
+		return suite, fmt.Errorf("invalid test suite %s: %v", path, err)
+	}
+	if suite.Prompt == "" {
+		name := filepath.Base(path)
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+		suite.Prompt = strings.TrimSuffix(name, ".test")
+	}
+	return suite, nil
+}
+
+/**
+ * Write a test suite file in the format given by its extension
+ * @param path path of a .yaml, .yml or .json file
+ * @param suite test suite to write
+ */
+func SaveTestSuite(path string, suite TestSuite) error {
+	var data []byte
+	var err error
+	switch filepath.Ext(path) {
+	case ".json":
+		data, err = json.MarshalIndent(suite, "", "  ")
+	case ".yaml", ".yml":
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		err = enc.Encode(suite)
+		data = buf.Bytes()
+	default:
+		return fmt.Errorf("unsupported test suite format: %s", path)
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+/**
+ * Run a test suite against a prompt
+ * @param ctx context of the renders
+ * @param prompt_id ID of the prompt to test
+ * @param suite test cases to run
+ * @return report of all cases
+ * @throws
+ * - 404 error if the prompt doesn't exist
+ */
+func RunTestSuite(ctx context.Context, prompt_id string, suite TestSuite) (TestReport, error) {
+	report := TestReport{Prompt: prompt_id, Cases: []TestCaseResult{}}
+	if !PromptVisible(ctx, prompt_id) {
+		return report, utils.ErrPromptNotFound
+	}
+	for i, tc := range suite.Cases {
+		result := runTestCase(ctx, prompt_id, tc)
+		if result.Name == "" {
+			result.Name = fmt.Sprintf("case %d", i+1)
+		}
+		if result.Passed {
+			report.Passed++
+		} else {
+			report.Failed++
+		}
+		report.Cases = append(report.Cases, result)
+	}
+	return report, nil
+}
+
+/**
+ * Render a prompt as a test case does, to record the golden output of the case
+ * @param ctx context of the render
+ * @param prompt_id ID of the prompt
+ * @param tc test case giving args, mocks and fixture
+ * @return expectation holding the actual output, other assertions copied from tc
+ */
+func GoldenExpect(ctx context.Context, prompt_id string, tc TestCase) (TestExpect, error) {
+	expect := tc.Expect
+	kind, data, err := renderTestCase(ctx, prompt_id, tc)
+	if err != nil {
+		return expect, err
+	}
+	expect.Prompt, expect.Messages = nil, nil
+	if kind == "prompt" {
+		text := data.(string)
+		expect.Prompt = &text
+	} else {
+		expect.Messages = data.([]dao.Message)
+	}
+	return expect, nil
+}
+
+func renderTestCase(ctx context.Context, prompt_id string, tc TestCase) (string, interface{}, error) {
+	opts := ToolOptions{Mocks: tc.Mocks}
+	if tc.Fixture != "" {
+		opts.Mode, opts.Fixture = ToolModeReplay, tc.Fixture
+	}
+	ctx, _, err := WithToolOptions(ctx, opts)
+	if err != nil {
+		return "", nil, err
+	}
+	args := tc.Args
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+	return RenderPrompt(ctx, prompt_id, args)
+}
+
+func runTestCase(ctx context.Context, prompt_id string, tc TestCase) TestCaseResult {
+	result := TestCaseResult{Name: tc.Name}
+	kind, data, err := renderTestCase(ctx, prompt_id, tc)
+	if err != nil {
+		result.Failures = append(result.Failures, fmt.Sprintf("render failed: %v", err))
+		return result
+	}
+	result.Tokens = CountRendered(kind, data).Total
+
+	var text string
+	var diffs []string
+	if kind == "prompt" {
+		text = data.(string)
+		if tc.Expect.Prompt != nil {
+			if diff := utils.LineDiff(*tc.Expect.Prompt, text); diff != "" {
+				result.Failures = append(result.Failures, "rendered prompt differs from expected")
+				diffs = append(diffs, diff)
+			}
+		} else if tc.Expect.Messages != nil {
+			result.Failures = append(result.Failures, "expected messages, rendered a prompt")
+		}
+	} else {
+		messages := data.([]dao.Message)
+		var parts []string
+		for _, m := range messages {
+			parts = append(parts, m.Content)
+		}
+		text = strings.Join(parts, "\n\n")
+		if tc.Expect.Messages != nil {
+			if diff := utils.LineDiff(formatMessages(tc.Expect.Messages), formatMessages(messages)); diff != "" {
+				result.Failures = append(result.Failures, "rendered messages differ from expected")
+				diffs = append(diffs, diff)
+			}
+		} else if tc.Expect.Prompt != nil {
+			result.Failures = append(result.Failures, "expected a prompt, rendered messages")
+		}
+	}
+
+	for _, s := range tc.Expect.Contains {
+		if !strings.Contains(text, s) {
+			result.Failures = append(result.Failures, fmt.Sprintf("output does not contain %q", s))
+		}
+	}
+	for _, s := range tc.Expect.NotContains {
+		if strings.Contains(text, s) {
+			result.Failures = append(result.Failures, fmt.Sprintf("output contains %q", s))
+		}
+	}
+	for _, pattern := range tc.Expect.Regex {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			result.Failures = append(result.Failures, fmt.Sprintf("invalid regex %q: %v", pattern, err))
+		} else if !re.MatchString(text) {
+			result.Failures = append(result.Failures, fmt.Sprintf("output does not match regex %q", pattern))
+		}
+	}
+	if tc.Expect.TokenLimit > 0 && result.Tokens > tc.Expect.TokenLimit {
+		result.Failures = append(result.Failures, fmt.Sprintf("output has %d tokens, over the limit of %d", result.Tokens, tc.Expect.TokenLimit))
+	}
+
+	result.Diff = strings.Join(diffs, "")
+	result.Passed = len(result.Failures) == 0
+	return result
+}
+
+/**
+ * Format messages as text for diffing, one "[role]" header line per message
+ */
+func formatMessages(messages []dao.Message) string {
+	var sb strings.Builder
+	for _, m := range messages {
+		fmt.Fprintf(&sb, "[%s]\n%s\n", m.Role, m.Content)
+	}
+	return sb.String()
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"github.com/zgsm-ai/ai-prompt-shell/dao"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/config"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/tokenizer"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Chat format overhead, following the OpenAI accounting for cl100k models
+const (
+	tokensPerMessage = 4
+	tokensPerReply   = 3
+)
+
+type TokenCounts struct {
+	Total    int   `json:"total"`
+	Messages []int `json:"messages,omitempty"`
+}
+
+var contextSizes = map[string]int{}
+var defaultContextSize = 8192
+
+/**
+ * Initialize tokenizer and model context sizes from configuration
+ * @param c configuration containing tokenizer and LLM model settings
+ * @description
+ * - Falls back to the approximate tokenizer if no vocabulary file is configured or it fails to load
+ */
+func initTokenizer(c *config.Config) {
+	if c.Tokenizer.VocabFile != "" {
+		bpe, err := tokenizer.LoadBPE(c.Tokenizer.VocabFile, c.Tokenizer.Encoding)
+		if err != nil {
+			logrus.Errorf("Failed to load tokenizer vocabulary %s, using approximate token counts: %v", c.Tokenizer.VocabFile, err)
+		} else {
+			tokenizer.SetDefault(bpe)
+		}
+	}
+	if c.LLM.DefaultContextSize > 0 {
+		defaultContextSize = c.LLM.DefaultContextSize
+	}
+	sizes := make(map[string]int)
+	for _, m := range c.LLM.Models {
+		if m.Name != "" && m.ContextSize > 0 {
+			sizes[m.Name] = m.ContextSize
+		}
+	}
+	contextSizes = sizes
+}
+
+/**
+ * Get context window size of a model
+ * @param model model name
+ * @return configured context size, or the default context size for unknown models
+ */
+func ContextSize(model string) int {
+	if size, ok := contextSizes[model]; ok {
+		return size
+	}
+	return defaultContextSize
+}
+
+/**
+ * Count tokens in text
+ * @param text text to count
+ * @return number of tokens
+ */
+func CountTokens(text string) int {
+	return tokenizer.Default().Count(text)
+}
+
+/**
+ * Count tokens of chat messages as sent to the LLM
+ * @param messages chat messages
+ * @return total tokens including chat format overhead, and tokens of each message
+ */
+func CountMessages(messages []dao.Message) TokenCounts {
+	counts := TokenCounts{
+		Total:    tokensPerReply,
+		Messages: make([]int, len(messages)),
+	}
+	for i, m := range messages {
+		n := tokensPerMessage + CountTokens(m.Role) + CountTokens(m.Content)
+		counts.Messages[i] = n
+		counts.Total += n
+	}
+	return counts
+}
+
+/**
+ * Count tokens of a render result
+ * @param kind kind of rendered content ("prompt" or "messages")
+ * @param data rendered text or messages
+ * @return token counts; a "prompt" result has no per-message counts
+ */
+func CountRendered(kind string, data interface{}) TokenCounts {
+	if kind == "prompt" {
+		return TokenCounts{Total: CountTokens(data.(string))}
+	}
+	return CountMessages(data.([]dao.Message))
+}
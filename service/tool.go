@@ -1,6 +1,8 @@
 package service
 
 import (
+	"context"
+	"fmt"
 	"github.com/zgsm-ai/ai-prompt-shell/dao"
 	"github.com/zgsm-ai/ai-prompt-shell/internal/utils"
 	"net/http"
@@ -34,3 +36,53 @@ func ToolIDs() ([]string, error) {
 	}
 	return results, nil
 }
+
+/**
+ * Register or update a tool
+ * @param ctx context of the change, naming its actor in the audit log
+ * @param toolId ID of the tool
+ * @param t tool definition
+ * @return error if storage write fails
+ * @throws 400 error if the function name of the tool is reserved by a built-in helper
+ */
+func SaveTool(ctx context.Context, toolId string, t dao.Tool) error {
+	if name := idToVariable(toolId); reservedFuncName(name) {
+		return utils.NewHttpError(http.StatusBadRequest,
+			fmt.Sprintf("tool %s can't be called from templates: function name %s is reserved by a built-in helper", toolId, name))
+	}
+	var before any
+	if old, ok := tools.Get(toolId); ok {
+		before = old
+	}
+	if err := dao.SetJSON(dao.IDToKey(toolId, dao.PREFIX_TOOLS), t, 0); err != nil {
+		return err
+	}
+	auditChange(ctx, "tools", toolId, before, t)
+	tools.Register(toolId, t)
+	onRefreshTools()
+	onRefreshPartials()
+	onRefreshPrompts()
+	return nil
+}
+
+/**
+ * Call a tool directly, outside of any template
+ * @param ctx context of the call, see WithToolOptions
+ * @param toolId ID of the tool
+ * @param args arguments of the call
+ * @return tool result
+ * @throws
+ * - 404 error if the tool doesn't exist
+ * - 502 error if the tool call fails
+ */
+func CallTool(ctx context.Context, toolId string, args []interface{}) (interface{}, error) {
+	t, exists := tools.Get(toolId)
+	if !exists {
+		return nil, utils.ErrToolNotFound
+	}
+	result, err := Call(ctx, toolId, &t, args)
+	if err != nil {
+		return nil, utils.RethrowError(http.StatusBadGateway, err)
+	}
+	return result, nil
+}
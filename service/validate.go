@@ -0,0 +1,509 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/xeipuuv/gojsonschema"
+	"github.com/zgsm-ai/ai-prompt-shell/dao"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/funcs"
+	"github.com/zgsm-ai/ai-prompt-shell/internal/utils"
+)
+
+// Kinds of problems reported by ValidatePrompt
+const (
+	IssueSyntax          = "syntax"
+	IssueUnknownFunction = "unknown_function"
+	IssueUnknownRole     = "unknown_role"
+	IssueSchema          = "schema"
+	IssueUndeclaredArg   = "undeclared_arg"
+	IssueSampleArgs      = "sample_args"
+	IssueRender          = "render"
+)
+
+/**
+ * Problem found in a prompt by ValidatePrompt
+ * @description
+ * - Field locates the problem in the prompt, e.g. "prompt", "messages.1", "parameters"
+ * - Line and Column locate template problems in the template text
+ */
+type ValidationIssue struct {
+	Kind    string `json:"kind"`
+	Field   string `json:"field"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+/**
+ * Request to validate a prompt that has not been saved
+ * @description
+ * - The body is a full dao.Prompt, optionally with sample_args to render it with
+ * - Scope selects the overrides of shared variables the sample is rendered with, as in a render request
+ */
+type ValidatePromptRequest struct {
+	dao.Prompt
+	SampleArgs map[string]interface{} `json:"sample_args,omitempty"`
+	Scope      dao.EnvScope           `json:"scope"`
+}
+
+/**
+ * Result of validating a prompt
+ * @description
+ * - Kind, Prompt and Messages hold the sample rendering, present only if sample_args was given and rendering succeeded
+ */
+type ValidatePromptResult struct {
+	Valid    bool              `json:"valid"`
+	Issues   []ValidationIssue `json:"issues"`
+	Kind     string            `json:"kind,omitempty"`
+	Prompt   string            `json:"prompt,omitempty"`
+	Messages []dao.Message     `json:"messages,omitempty"`
+}
+
+// Functions predefined by text/template
+var templateBuiltins = []string{
+	"and", "call", "html", "index", "slice", "js", "len", "not", "or",
+	"print", "printf", "println", "urlquery",
+	"eq", "ge", "gt", "le", "lt", "ne",
+}
+
+// Message roles accepted in prompt messages
+var validRoles = []string{
+	dao.MessageRoleSystem,
+	dao.MessageRoleUser,
+	dao.MessageRoleAssistant,
+	dao.MessageRoleTool,
+}
+
+// NAME:LINE:COL as returned by parse.Tree.ErrorContext
+var reNodeLocation = regexp.MustCompile(`:(\d+):(\d+)$`)
+
+/**
+ * Validate a prompt without saving it
+ * @param ctx context of the request; prompts rendered inline are hidden by their ACL from the caller it carries
+ * @param req prompt to validate, with optional sample args
+ * @return validation result listing all problems found
+ * @description
+ * - Templates are parsed against the live template functions; calls to functions that don't exist are reported
+ * - Message roles must be system, user, assistant or tool
+ * - Parameters and Returns must be valid JSON Schemas
+ * - References to .args.X must name parameters declared in Parameters
+ * - If sample args are given and the templates compile, the prompt is rendered with every tool call mocked
+ */
+func ValidatePrompt(ctx context.Context, req ValidatePromptRequest) ValidatePromptResult {
+	return validatePrompt(ctx, req, nil)
+}
+
+/**
+ * Validate a prompt against a given set of tool functions
+ * @param ctx context of the request, as ValidatePrompt
+ * @param req prompt to validate, with optional sample args
+ * @param toolFuncs names of the template functions of the tools to check against, nil for the live tools
+ */
+func validatePrompt(ctx context.Context, req ValidatePromptRequest, toolFuncs map[string]bool) ValidatePromptResult {
+	p := req.Prompt
+	var issues []ValidationIssue
+
+	templates := map[string]string{}
+	if p.Prompt != "" && p.Messages != nil {
+		issues = append(issues, ValidationIssue{Kind: IssueSyntax, Field: "prompt",
+			Message: "prompt and messages are mutually exclusive"})
+	}
+	if p.Prompt != "" {
+		templates["prompt"] = p.Prompt
+	} else if p.Messages != nil {
+		for i, m := range p.Messages {
+			field := fmt.Sprintf("messages.%d", i)
+			if !slices.Contains(validRoles, m.Role) {
+				issues = append(issues, ValidationIssue{Kind: IssueUnknownRole, Field: field + ".role",
+					Message: fmt.Sprintf("unknown role %q, must be one of %s", m.Role, strings.Join(validRoles, ", "))})
+			}
+			templates[field] = m.Content
+		}
+	} else {
+		issues = append(issues, ValidationIssue{Kind: IssueSyntax, Field: "prompt",
+			Message: "prompt has neither prompt nor messages"})
+	}
+
+	issues = append(issues, checkSchema("parameters", p.Parameters)...)
+	issues = append(issues, checkSchema("returns", p.Returns)...)
+	declared, open := declaredArgs(p.Parameters)
+
+	fields := make([]string, 0, len(templates))
+	for field := range templates {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	renderable := true
+	for _, field := range fields {
+		trees, err := parseTemplate(field, templates[field])
+		if err != nil {
+			re := newRenderError(p.Name, field, err)
+			issues = append(issues, ValidationIssue{Kind: IssueSyntax, Field: field, Line: re.Line, Message: re.Message})
+			renderable = false
+			continue
+		}
+		v := &templateLinter{field: field, declared: declared, open: open, toolFuncs: toolFuncs}
+		for _, tree := range trees {
+			v.tree = tree
+			v.walk(tree.Root, tree.Name == field)
+		}
+		if v.unknownFuncs {
+			renderable = false
+		}
+		issues = append(issues, v.issues...)
+	}
+
+	result := ValidatePromptResult{}
+	if req.SampleArgs != nil && renderable {
+		if schema := argsSchema(p.Parameters); schema != nil {
+			if err := utils.ValidateVariables(req.SampleArgs, schema); err != nil {
+				issues = append(issues, ValidationIssue{Kind: IssueSampleArgs, Field: "sample_args", Message: err.Error()})
+			}
+		}
+		kind, data, err := renderSample(ctx, p, req.SampleArgs)
+		if err != nil {
+			issue := ValidationIssue{Kind: IssueRender, Message: err.Error()}
+			if re, ok := err.(*RenderError); ok {
+				issue.Field = strings.TrimPrefix(re.TemplateKey, p.Name+".")
+				issue.Line, issue.Column, issue.Message = re.Line, re.Column, re.Message
+			}
+			issues = append(issues, issue)
+		} else if kind == "prompt" {
+			result.Kind, result.Prompt = kind, data.(string)
+		} else {
+			result.Kind, result.Messages = kind, data.([]dao.Message)
+		}
+	}
+
+	if issues == nil {
+		issues = []ValidationIssue{}
+	}
+	result.Issues = issues
+	result.Valid = len(issues) == 0
+	return result
+}
+
+/**
+ * Parse template text without failing on unknown functions
+ * @param name template name
+ * @param text template text
+ * @return parsed trees, including those of {{define}} blocks
+ * @return syntax error, if any
+ */
+func parseTemplate(name, text string) (map[string]*parse.Tree, error) {
+	tree := parse.New(name)
+	tree.Mode = parse.SkipFuncCheck
+	trees := map[string]*parse.Tree{}
+	if _, err := tree.Parse(text, "", "", trees); err != nil {
+		return nil, err
+	}
+	return trees, nil
+}
+
+/**
+ * Check that a Parameters/Returns definition is a valid JSON Schema
+ * @param field name of the field being checked
+ * @param schema JSON Schema, or a map of parameter names to JSON Schemas
+ * @return issues found
+ */
+func checkSchema(field string, schema map[string]interface{}) []ValidationIssue {
+	if len(schema) == 0 {
+		return nil
+	}
+	var issues []ValidationIssue
+	check := func(name string, s interface{}) {
+		if _, err := gojsonschema.NewSchema(gojsonschema.NewGoLoader(s)); err != nil {
+			issues = append(issues, ValidationIssue{Kind: IssueSchema, Field: name, Message: err.Error()})
+		}
+	}
+	if isJSONSchema(schema) {
+		check(field, schema)
+		return issues
+	}
+	names := make([]string, 0, len(schema))
+	for name := range schema {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := schema[name].(map[string]interface{}); !ok {
+			issues = append(issues, ValidationIssue{Kind: IssueSchema, Field: field + "." + name,
+				Message: fmt.Sprintf("parameter %s must be a JSON Schema object", name)})
+			continue
+		}
+		check(field+"."+name, schema[name])
+	}
+	return issues
+}
+
+/**
+ * Tell whether a Parameters definition is a JSON Schema rather than a map of parameter names to schemas
+ */
+func isJSONSchema(schema map[string]interface{}) bool {
+	for _, k := range []string{"type", "properties", "$ref", "$schema", "allOf", "anyOf", "oneOf"} {
+		if _, ok := schema[k]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ * Get the names of the args declared by Parameters
+ * @param params Parameters of the prompt
+ * @return declared arg names
+ * @return true if any arg name is accepted, e.g. for {"type": "object"} without properties
+ */
+func declaredArgs(params map[string]interface{}) (map[string]bool, bool) {
+	names := map[string]bool{}
+	if len(params) == 0 {
+		return names, true
+	}
+	if !isJSONSchema(params) {
+		for name := range params {
+			names[name] = true
+		}
+		return names, false
+	}
+	props, ok := params["properties"].(map[string]interface{})
+	if !ok {
+		return names, true
+	}
+	for name := range props {
+		names[name] = true
+	}
+	if extra, ok := params["additionalProperties"]; ok && extra != false {
+		return names, true
+	}
+	return names, false
+}
+
+/**
+ * Get a JSON Schema for the args object from Parameters
+ * @return schema, or nil if no parameters are declared
+ */
+func argsSchema(params map[string]interface{}) map[string]interface{} {
+	if len(params) == 0 {
+		return nil
+	}
+	if isJSONSchema(params) {
+		return params
+	}
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": params,
+	}
+}
+
+/**
+ * Collects problems found while walking a parsed template
+ */
+type templateLinter struct {
+	field    string
+	tree     *parse.Tree
+	declared map[string]bool
+	open     bool
+	issues   []ValidationIssue
+
+	toolFuncs map[string]bool // Tool functions to check against, nil for the live ones
+
+	unknownFuncs bool
+}
+
+/**
+ * Walk a template node
+ * @param node node to walk
+ * @param rootDot whether dot is the top-level context data at this node, so .args refers to the prompt args
+ */
+func (v *templateLinter) walk(node parse.Node, rootDot bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			v.walk(c, rootDot)
+		}
+	case *parse.ActionNode:
+		v.walk(n.Pipe, rootDot)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Cmds {
+			v.walk(c, rootDot)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			v.walk(arg, rootDot)
+		}
+	case *parse.ChainNode:
+		v.walk(n.Node, rootDot)
+	case *parse.IfNode:
+		v.walkBranch(&n.BranchNode, rootDot, rootDot)
+	case *parse.RangeNode:
+		v.walkBranch(&n.BranchNode, rootDot, false)
+	case *parse.WithNode:
+		v.walkBranch(&n.BranchNode, rootDot, false)
+	case *parse.TemplateNode:
+		v.walk(n.Pipe, rootDot)
+	case *parse.IdentifierNode:
+		if !v.knownFunction(n.Ident) {
+			v.unknownFuncs = true
+			v.report(n, IssueUnknownFunction, fmt.Sprintf("function %q is not defined, no built-in helper or tool has this name", n.Ident))
+		}
+	case *parse.FieldNode:
+		if rootDot {
+			v.checkArg(n, n.Ident)
+		}
+	case *parse.VariableNode:
+		if len(n.Ident) > 0 && n.Ident[0] == "$" {
+			v.checkArg(n, n.Ident[1:])
+		}
+	}
+}
+
+/**
+ * Walk an if/range/with node
+ * @param rootDot whether dot is the top-level context data before the node
+ * @param rootDotInside whether dot is still the top-level context data inside the node's body
+ */
+func (v *templateLinter) walkBranch(n *parse.BranchNode, rootDot, rootDotInside bool) {
+	v.walk(n.Pipe, rootDot)
+	v.walk(n.List, rootDotInside)
+	if n.ElseList != nil {
+		v.walk(n.ElseList, rootDot)
+	}
+}
+
+func (v *templateLinter) knownFunction(name string) bool {
+	if v.toolFuncs != nil {
+		if _, ok := funcs.Builtins()[name]; ok || v.toolFuncs[name] {
+			return true
+		}
+	} else if _, ok := renderer.funcMap[name]; ok {
+		return true
+	}
+	return name == "prompt" || slices.Contains(templateBuiltins, name)
+}
+
+/**
+ * Report reference to .args.X if X is not a declared parameter
+ * @param ident field chain relative to the top-level context data
+ */
+func (v *templateLinter) checkArg(node parse.Node, ident []string) {
+	if v.open || len(ident) < 2 || ident[0] != "args" || v.declared[ident[1]] {
+		return
+	}
+	v.report(node, IssueUndeclaredArg, fmt.Sprintf("args.%s is not declared in parameters", ident[1]))
+}
+
+func (v *templateLinter) report(node parse.Node, kind, message string) {
+	issue := ValidationIssue{Kind: kind, Field: v.field, Message: message}
+	if v.tree.Name != v.field {
+		issue.Message = fmt.Sprintf("%s (in template %q)", message, v.tree.Name)
+	}
+	location, _ := v.tree.ErrorContext(node)
+	if m := reNodeLocation.FindStringSubmatch(location); m != nil {
+		issue.Line, _ = strconv.Atoi(m[1])
+		issue.Column, _ = strconv.Atoi(m[2])
+	}
+	v.issues = append(v.issues, issue)
+}
+
+/**
+ * Render a prompt that has not been saved, with every tool call mocked
+ * @param ctx context of the request, as ValidatePrompt
+ * @param p prompt to render
+ * @param args sample args
+ * @return type of rendered content, rendered content and error, as RenderPrompt
+ * @description
+ * - A mocked tool returns a value shaped like its Returns schema, strings hold the placeholder "<tool_id>"
+ * - Shared variables are resolved in the scope of ctx, as RenderPrompt
+ * - Prompts rendered inline with {{prompt}} are the saved ones, also with tools mocked
+ */
+func renderSample(ctx context.Context, p dao.Prompt, args map[string]interface{}) (string, interface{}, error) {
+	id := p.Name
+	if id == "" {
+		id = "validate"
+	}
+	state := &renderState{ctx: ctx, stack: []string{id}, funcs: mockToolFuncs(), envs: environs.Resolve(EnvScopeFrom(ctx))}
+	execute := func(key, text string) (string, error) {
+		t, err := compileTemplate(key, text, p.Strict)
+		if err != nil {
+			return "", newRenderError(id, key, err)
+		}
+		t.Funcs(state.funcs)
+		t.Funcs(template.FuncMap{"prompt": state.renderInline})
+		var buf strings.Builder
+		if err := t.Execute(&buf, constructContextData(state.envs, args)); err != nil {
+			return "", newRenderError(id, key, err)
+		}
+		return buf.String(), nil
+	}
+	if p.Prompt != "" {
+		text, err := execute(id+".prompt", p.Prompt)
+		return "prompt", text, err
+	}
+	var results []dao.Message
+	for i, m := range p.Messages {
+		content, err := execute(fmt.Sprintf("%s.messages.%d", id, i), m.Content)
+		if err != nil {
+			return "messages", nil, err
+		}
+		results = append(results, dao.Message{Role: m.Role, Content: content})
+	}
+	return "messages", results, nil
+}
+
+/**
+ * Build template functions that stand in for every tool without calling it
+ */
+func mockToolFuncs() template.FuncMap {
+	mocks := template.FuncMap{}
+	for id, t := range tools.All() {
+		name := idToVariable(id)
+		if _, ok := renderer.funcMap[name]; !ok {
+			continue
+		}
+		value := sampleValue(t.Returns, "<"+id+">")
+		mocks[name] = func(args ...interface{}) (interface{}, error) {
+			return value, nil
+		}
+	}
+	return mocks
+}
+
+/**
+ * Generate a value matching a JSON Schema
+ * @param schema JSON Schema of the value
+ * @param placeholder value used for strings
+ * @return sample value; objects get a sample for each declared property
+ */
+func sampleValue(schema map[string]interface{}, placeholder string) interface{} {
+	switch schema["type"] {
+	case "object":
+		obj := map[string]interface{}{}
+		props, _ := schema["properties"].(map[string]interface{})
+		for k, v := range props {
+			s, _ := v.(map[string]interface{})
+			obj[k] = sampleValue(s, placeholder)
+		}
+		return obj
+	case "array":
+		return []interface{}{}
+	case "integer", "number":
+		return 0
+	case "boolean":
+		return false
+	}
+	return placeholder
+}